@@ -11,8 +11,9 @@ import (
 )
 
 type config struct {
-	name string
-	addr string
+	name   string
+	addr   string
+	record bool
 }
 
 func main() {
@@ -22,12 +23,13 @@ func main() {
 	// Parse command line options
 	var (
 		cfg config
-		s   scale.Scale
+		s   *felicita.Felicita
 		err error
 	)
 
 	flag.StringVar(&cfg.name, "name", "FELICITA", "name of remote peripheral")
 	flag.StringVar(&cfg.addr, "addr", "", "address of remote peripheral (MAC on Linux, UUID on OS X)")
+	flag.BoolVar(&cfg.record, "record", false, "automatically record brew sessions (starting / stopping the scale timer)")
 	flag.Parse()
 
 	s, err = felicita.New()
@@ -39,19 +41,28 @@ func main() {
 		logger.Warnf("read DATA from Handler: %v, %v, %v, %v, %v", data, s.ConnectionStatus(), s.BatteryLevel(), s.IsBuzzingOnTouch(), s.ElapsedTime())
 	})
 
-	dataChan := make(chan scale.DataPoint, 256)
-	s.SetDataChannel(dataChan)
+	sub := s.Subscribe(scale.WithBufferSize(256))
+	defer sub.Unsubscribe()
 
-	stateChan := make(chan scale.ConnectionStatus)
-	s.SetStateChangeChannel(stateChan)
+	if cfg.record {
+		recorder := scale.Recorder{
+			Timer:  s,
+			Logger: logger,
+		}
+		recorder.SetHandler(func(session scale.Session) {
+			logger.Infof("recorded session: dose %.1f%s, yield %.1f%s, duration %v (%d data points)",
+				session.Dose, session.Unit, session.Yield, session.Unit, session.Duration, len(session.DataPoints))
+		})
+		go recorder.Run(s.Subscribe(scale.WithBufferSize(256)))
+	}
 
 	go func() {
-		for st := range stateChan {
+		for st := range sub.States() {
 			logger.Warnf("state change: %v", st)
 		}
 	}()
 
-	sigChan := make(chan os.Signal)
+	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM)
 	signal.Notify(sigChan, os.Interrupt)
 	go func() {
@@ -63,7 +74,9 @@ func main() {
 		os.Exit(0)
 	}()
 
-	for v := range dataChan {
-		logger.Warnf("read DATA from Channel: %v, %v, %v, %v, %v", v, s.ConnectionStatus(), s.BatteryLevel(), s.IsBuzzingOnTouch(), s.ElapsedTime())
+	var flow scale.FlowProcessor
+	for v := range sub.Data() {
+		rate := flow.Process(v)
+		logger.Warnf("read DATA from Channel: %v, %v, %v, %v, %v, flow rate: %.2f %s/s", v, s.ConnectionStatus(), s.BatteryLevel(), s.IsBuzzingOnTouch(), s.ElapsedTime(), rate.Rate, rate.Unit)
 	}
 }
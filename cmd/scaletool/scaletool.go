@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	_ "github.com/fako1024/btscale/pkg/drivers"
 	"github.com/fako1024/btscale/pkg/felicita"
 	"github.com/fako1024/btscale/pkg/scale"
 )
 
 type config struct {
-	name string
+	name    string
+	timeout time.Duration
+	scan    bool
 
 	togglePrecision bool
 	toggleBuzzer    bool
@@ -28,16 +35,25 @@ func run() (err error) {
 	// Parse command line options
 	var (
 		cfg config
-		s   scale.Scale
+		s   *felicita.Felicita
 	)
 
 	flag.StringVar(&cfg.name, "name", "FELICITA", "Name of remote peripheral")
+	flag.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "Maximum time to wait for a connection to the scale")
+	flag.BoolVar(&cfg.scan, "scan", false, "Scan for peripherals (until timeout) and list them instead of connecting")
 
 	flag.BoolVar(&cfg.togglePrecision, "p", false, "Toggle the scale precision")
 	flag.BoolVar(&cfg.toggleBuzzer, "b", false, "Toggle the buzzer on touch / action feature")
 	flag.Parse()
 
-	s, err = felicita.New()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if cfg.scan {
+		return scan(ctx, cfg.timeout)
+	}
+
+	s, err = felicita.New(felicita.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to initialize Felicita scale: %w", err)
 	}
@@ -48,12 +64,12 @@ func run() (err error) {
 		}
 	}()
 
-	for {
-		time.Sleep(time.Second)
-		if s.ConnectionStatus().State == scale.StateConnected {
-			break
-		}
+	waitCtx, waitCancel := context.WithTimeout(ctx, cfg.timeout)
+	defer waitCancel()
+	if err := s.WaitConnected(waitCtx); err != nil {
+		return fmt.Errorf("failed to connect to scale: %w", err)
 	}
+	printDeviceInfo(s.DeviceInfo())
 
 	if cfg.togglePrecision {
 		if err := s.TogglePrecision(); err != nil {
@@ -68,3 +84,54 @@ func run() (err error) {
 
 	return nil
 }
+
+func scan(ctx context.Context, timeout time.Duration) error {
+
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	candidates, err := scale.Scan(scanCtx, scale.OpenOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to scan for peripherals: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	for c := range candidates {
+		if _, exists := seen[c.ID]; exists {
+			continue
+		}
+		seen[c.ID] = struct{}{}
+
+		driver := "unsupported"
+		if c.IsSupported() {
+			driver = "driver: " + c.Driver
+		}
+		fmt.Printf("%s\t%-20s\tRSSI: %d dBm\tTX Power: %d\tManufacturer Data: %v\t(%s)\n",
+			c.ID, c.Name, c.RSSI, c.TxPowerLevel, c.ManufacturerData, driver)
+	}
+
+	return nil
+}
+
+func printDeviceInfo(info scale.DeviceInfo) {
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"Manufacturer", info.ManufacturerName},
+		{"Model", info.ModelNumber},
+		{"Serial Number", info.SerialNumber},
+		{"Firmware Revision", info.FirmwareRevision},
+		{"Hardware Revision", info.HardwareRevision},
+		{"Software Revision", info.SoftwareRevision},
+		{"System ID", info.SystemID},
+	} {
+		if field.value != "" {
+			fmt.Printf("%-18s = %s\n", field.name, field.value)
+		}
+	}
+	if info.PnPID != nil {
+		fmt.Printf("%-18s = vendor 0x%04x (source %d), product 0x%04x, version 0x%04x\n", "PnP ID",
+			info.PnPID.VendorID, info.PnPID.VendorIDSource, info.PnPID.ProductID, info.PnPID.ProductVersion)
+	}
+}
@@ -4,7 +4,6 @@ go 1.20
 
 require (
 	github.com/fako1024/gatt v1.0.4
-	github.com/fatih/stopwatch v1.0.0
 	github.com/gofiber/fiber/v2 v2.52.5
 	go.uber.org/zap v1.27.0
 )
@@ -0,0 +1,730 @@
+package acaia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+const (
+	legacyDataService        = "1820"
+	legacyDataCharacteristic = "2a80"
+
+	dataService              = "49535343fe7d4ae58fa99fafd205e455"
+	dataWriteCharacteristic  = "49535343884143f4a8d4ecbe34729bb3"
+	dataNotifyCharacteristic = "495353431e4d4bd9ba6123c647249616"
+	defaultHeartbeatInterval = 3 * time.Second
+	handshakeSettleDelay     = 100 * time.Millisecond
+	maxReceiveBufferSize     = 1024
+)
+
+// Known device name prefixes of Acaia scales (Lunar, Pearl, Pyxis, ...)
+var deviceNamePrefixes = []string{
+	"ACAIA",
+	"PROCHBT",
+	"LUNAR",
+	"PEARL",
+	"PYXIS",
+	"CINCO",
+	"UMBRA",
+}
+
+// Acaia denotes an Acaia bluetooth scale
+type Acaia struct {
+	connectionStatus scale.ConnectionStatus
+	batteryLevel     byte
+	isBuzzingOnTouch bool
+	unit             scale.Unit
+	canonicalUnit    scale.Unit
+	filter           scale.Pipeline
+	precision        scale.Precision
+	deviceInfo       scale.DeviceInfo
+
+	timer scale.Stopwatch
+
+	deviceID          string
+	deviceName        string
+	heartbeatInterval time.Duration
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	ready       scale.ReadySignal
+	release     scale.ReleaseSignal
+	reconnector scale.Reconnector
+	broker      scale.Broker
+	stability   scale.StabilityDetector
+	connWG      sync.WaitGroup
+
+	btDevice               gatt.Device
+	btPeripheral           gatt.Peripheral
+	btWriteCharacteristic  *gatt.Characteristic
+	btNotifyCharacteristic *gatt.Characteristic
+
+	rxBuf []byte
+
+	logger scale.Logger
+
+	mu sync.RWMutex
+}
+
+// New instantiates a new Acaia struct, executing functional options, if any
+func New(options ...func(*Acaia)) (*Acaia, error) {
+
+	// Initialize a new instance of an Acaia scale
+	a := &Acaia{
+		unit:              scale.UnitUnknown,
+		heartbeatInterval: defaultHeartbeatInterval,
+		ctx:               context.Background(),
+		logger:            &scale.NullLogger{},
+	}
+
+	// Execute functional options (if any), see options.go for implementation
+	for _, option := range options {
+		option(a)
+	}
+	if a.canonicalUnit != "" && !a.canonicalUnit.IsValid() {
+		return nil, fmt.Errorf("invalid canonical unit: %s", a.canonicalUnit)
+	}
+
+	// Initialize a new GATT device (if not provided as option)
+	if a.btDevice == nil {
+		btDevice, err := gatt.NewDevice(defaultBTClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		a.btDevice = btDevice
+	}
+
+	// Terminate the connection to the device once the context ends
+	a.ctx, a.cancel = context.WithCancel(a.ctx)
+	go func() {
+		<-a.ctx.Done()
+		if err := a.Close(); err != nil {
+			a.logger.Warnf("failed to close device upon termination of context: %s", err)
+		}
+	}()
+
+	// Release the device (terminating the context) if it cannot be initialized
+	if err := a.subscribe(); err != nil {
+		if closeErr := a.Close(); closeErr != nil {
+			a.logger.Warnf("failed to close device after failed initialization: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// ConnectionStatus returns the current status of the bluetooth device
+func (a *Acaia) ConnectionStatus() scale.ConnectionStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.connectionStatus
+}
+
+// WaitConnected blocks until the scale is connected and usable, returning an error if the
+// context ends or the scale is closed before
+func (a *Acaia) WaitConnected(ctx context.Context) error {
+	return a.ready.Wait(ctx)
+}
+
+// DeviceInfo returns the information provided by the Device Information service of the
+// scale (populated upon connection)
+func (a *Acaia) DeviceInfo() scale.DeviceInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.deviceInfo
+}
+
+// DeliveryStats returns the number of data points / state changes dropped so far
+// (according to the delivery policy)
+func (a *Acaia) DeliveryStats() scale.DeliveryStats {
+	return a.broker.Stats()
+}
+
+// IsBuzzingOnTouch returns if the scale buzzer is turned on or not (on user interaction)
+func (a *Acaia) IsBuzzingOnTouch() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.isBuzzingOnTouch
+}
+
+// BatteryLevel returns the current battery level
+func (a *Acaia) BatteryLevel() float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return parseBatteryLevel(a.batteryLevel)
+}
+
+// BatteryLevelRaw returns the current battery level in its raw form
+func (a *Acaia) BatteryLevelRaw() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return int(a.batteryLevel)
+}
+
+// Unit returns the current weight unit (unknown until the settings have been received)
+func (a *Acaia) Unit() scale.Unit {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.unit
+}
+
+// Subscribe registers a new subscription to the data points and state changes of the
+// scale, executing functional options, if any
+func (a *Acaia) Subscribe(options ...func(*scale.Subscription)) *scale.Subscription {
+	return a.broker.Subscribe(options...)
+}
+
+// IsStable returns if the weight is currently stable
+func (a *Acaia) IsStable() bool {
+	return a.stability.IsStable()
+}
+
+// SetStabilityChangeHandler defines a handler function that is called once the weight
+// becomes stable / unstable
+func (a *Acaia) SetStabilityChangeHandler(fn func(event scale.StabilityEvent)) {
+	a.stability.SetHandler(fn)
+}
+
+// SetStateChangeHandler defines a handler function that is called upon state change
+func (a *Acaia) SetStateChangeHandler(fn func(status scale.ConnectionStatus)) {
+	a.broker.SetStateChangeHandler(fn)
+}
+
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the
+// new state change if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (a *Acaia) SetStateChangeChannel(ch chan scale.ConnectionStatus) {
+	a.broker.SetStateChangeChannel(ch)
+}
+
+// SetDataHandler defines a handler function that is called upon retrieval of data
+func (a *Acaia) SetDataHandler(fn func(data scale.DataPoint)) {
+	a.broker.SetDataHandler(fn)
+}
+
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (a *Acaia) SetDataChannel(ch chan scale.DataPoint) {
+	a.broker.SetDataChannel(ch)
+}
+
+// Tare tares the scale
+func (a *Acaia) Tare() error {
+	return a.write(encode(cmdTare, payloadTare))
+}
+
+// Buzz requests the scale to beep / buzz n times (not supported by Acaia scales)
+func (a *Acaia) Buzz(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of beeps requested: %d", n)
+	}
+
+	return scale.ErrNotSupported
+}
+
+// ToggleBuzzingOnTouch turns the buzzer (on user interaction) on / off (not supported
+// by Acaia scales, the setting has to be changed on the device itself)
+func (a *Acaia) ToggleBuzzingOnTouch() error {
+	return scale.ErrNotSupported
+}
+
+// SetUnit sets the weight unit (changing the unit is not supported by Acaia scales,
+// the setting has to be changed on the device itself)
+func (a *Acaia) SetUnit(unit scale.Unit) error {
+
+	// Check if the unit is already set to the expected value
+	if current := a.Unit(); current != scale.UnitUnknown && current == unit {
+		return nil
+	}
+
+	return scale.ErrNotSupported
+}
+
+// Precision returns the current weight precision (as reported along with the weight data)
+func (a *Acaia) Precision() scale.Precision {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.precision
+}
+
+// SetPrecision sets the weight precision (changing the precision is not supported by
+// Acaia scales)
+func (a *Acaia) SetPrecision(p scale.Precision) error {
+
+	// Check if the precision is already set to the expected value
+	if current := a.Precision(); current != scale.PrecisionUnknown && current == p {
+		return nil
+	}
+
+	return scale.ErrNotSupported
+}
+
+// TogglePrecision toggles the weight precision between 0.1 and 0.01 (not supported
+// by Acaia scales)
+func (a *Acaia) TogglePrecision() error {
+	return scale.ErrNotSupported
+}
+
+// StartTimer starts the timer / stopwatch
+func (a *Acaia) StartTimer() error {
+	if err := a.write(encodeTimer(timerStart)); err != nil {
+		return err
+	}
+
+	a.timer.Start()
+
+	return nil
+}
+
+// StopTimer stops the timer / stopwatch
+func (a *Acaia) StopTimer() error {
+	if err := a.write(encodeTimer(timerStop)); err != nil {
+		return err
+	}
+
+	a.timer.Stop()
+
+	return nil
+}
+
+// ResetTimer resets the timer / stopwatch
+func (a *Acaia) ResetTimer() error {
+	if err := a.write(encodeTimer(timerReset)); err != nil {
+		return err
+	}
+
+	a.timer.Reset()
+
+	return nil
+}
+
+// ElapsedTime returns the current timer value
+func (a *Acaia) ElapsedTime() time.Duration {
+	return a.timer.ElapsedTime()
+}
+
+// Close terminates the connection to the device (subsequent calls are a no-op)
+func (a *Acaia) Close() (err error) {
+	a.closeOnce.Do(func() {
+		a.cancel()
+		a.ready.Close()
+		a.broker.Close()
+
+		_ = a.btDevice.StopScanning()
+		err = a.btDevice.RemoveAllServices()
+
+		// Close the device (releasing it if shared via an adapter)
+		if closeErr := a.btDevice.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (a *Acaia) subscribe() error {
+
+	// Register handlers (routed to this instance if the device is shared via an adapter)
+	scale.HandlePeripherals(a.btDevice, a.genOnPeriphDiscovered(), a.onPeriphConnected, a.onPeriphDisconnected)
+
+	// Initialize the device
+	return a.btDevice.Init(a.onStateChanged)
+}
+
+func (a *Acaia) setStatus(state scale.State, err error) {
+	status := a.reconnector.Status(state, err)
+
+	a.mu.Lock()
+	a.connectionStatus = status
+	a.mu.Unlock()
+
+	// Distribute state change to all consumers
+	a.broker.PublishState(status)
+}
+
+func (a *Acaia) write(msg []byte) error {
+	a.mu.RLock()
+	btPeripheral, btWriteCharacteristic := a.btPeripheral, a.btWriteCharacteristic
+	a.mu.RUnlock()
+
+	if btPeripheral == nil || btWriteCharacteristic == nil {
+		return fmt.Errorf("failed to write to uninitialized device")
+	}
+
+	return btPeripheral.WriteCharacteristic(btWriteCharacteristic, msg, true)
+}
+
+// setPeripheral sets (or resets, if nil) the connected peripheral and its characteristics,
+// discarding any buffered data of a previous connection. Since the unit is only reported
+// by the settings of the scale, it is unknown until they have been received
+func (a *Acaia) setPeripheral(p gatt.Peripheral, write, notify *gatt.Characteristic) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.btPeripheral = p
+	a.btWriteCharacteristic = write
+	a.btNotifyCharacteristic = notify
+	a.rxBuf = nil
+	a.unit = scale.UnitUnknown
+}
+
+// handshake identifies the client to the scale and requests event notifications
+func (a *Acaia) handshake() error {
+	if err := a.write(encode(cmdIdent, payloadIdent)); err != nil {
+		return fmt.Errorf("failed to send identification: %w", err)
+	}
+	time.Sleep(handshakeSettleDelay)
+
+	if err := a.write(encodeNotificationRequest()); err != nil {
+		return fmt.Errorf("failed to request notifications: %w", err)
+	}
+	time.Sleep(handshakeSettleDelay)
+
+	return a.write(encode(cmdGetSettings, payloadGetSettings))
+}
+
+// heartbeat periodically keeps the connection alive (the scale disconnects after a
+// few seconds without receiving a heartbeat) until the done channel is closed
+func (a *Acaia) heartbeat(done chan struct{}) {
+	ticker := time.NewTicker(a.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := a.write(encode(cmdHeartbeat, payloadHeartbeat)); err != nil {
+				a.logger.Warnf("failed to send heartbeat: %s", err)
+				continue
+			}
+			if err := a.write(encode(cmdGetSettings, payloadGetSettings)); err != nil {
+				a.logger.Warnf("failed to request settings: %s", err)
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (a *Acaia) onStateChanged(d gatt.Device, s gatt.State) {
+	switch s {
+	case gatt.StatePoweredOn:
+		a.setStatus(scale.StateScanning, nil)
+		if err := d.Scan([]gatt.UUID{}, false); err != nil {
+			a.logger.Warnf("failed to enable initial scanning: %s", err)
+		}
+		return
+	case gatt.StatePoweredOff:
+		a.setStatus(scale.StateDisconnected, nil)
+		return
+	default:
+		if err := d.StopScanning(); err != nil {
+			a.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+	}
+}
+
+func (a *Acaia) genOnPeriphDiscovered() func(p gatt.Peripheral, arg2 *gatt.Advertisement, arg3 int) {
+	return func(p gatt.Peripheral, arg2 *gatt.Advertisement, arg3 int) {
+
+		a.logger.Debugf("discovered device `%s/%s`", p.Name(), p.ID())
+
+		if !a.thisDevice(p) {
+			return
+		}
+
+		a.logger.Debugf("connecting device `%s/%s`", p.Name(), p.ID())
+
+		// Stop scanning once we've got the peripheral we're looking for.
+		if err := p.Device().StopScanning(); err != nil {
+			a.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+		if err := p.Device().Connect(p); err != nil {
+			a.logger.Errorf("Failed to connect device `%s/%s`: %s", p.Name(), p.ID(), err)
+		}
+
+		a.logger.Debugf("connected device `%s/%s`", p.Name(), p.ID())
+	}
+}
+
+func (a *Acaia) onPeriphConnected(p gatt.Peripheral, connErr error) {
+
+	if !a.thisDevice(p) {
+		return
+	}
+
+	a.logger.Debugf("connected peripheral `%s/%s`", p.Name(), p.ID())
+
+	a.connWG.Add(1)
+	released := a.release.Released(p)
+	a.setStatus(scale.StateConnected, nil)
+	defer func() {
+		a.ready.Set(false)
+		a.setPeripheral(nil, nil, nil)
+		_ = p.Device().CancelConnection(p)
+		a.setStatus(scale.StateDisconnected, connErr)
+		a.release.Done(p)
+		a.connWG.Done()
+	}()
+
+	// Discover services
+	ss, err := p.DiscoverServices(nil)
+	if err != nil {
+		connErr = fmt.Errorf("failed to discover services: %w", err)
+		return
+	}
+	var btWriteCharacteristic, btNotifyCharacteristic *gatt.Characteristic
+	for _, s := range ss {
+		// Read device information (if available)
+		if s.UUID().String() == scale.DeviceInformationService {
+			info, err := scale.ReadDeviceInfo(p, s)
+			if err != nil {
+				a.logger.Warnf("failed to read device information: %s", err)
+				continue
+			}
+			a.mu.Lock()
+			a.deviceInfo = info
+			a.mu.Unlock()
+			continue
+		}
+
+		if s.UUID().String() != legacyDataService && s.UUID().String() != dataService {
+			continue
+		}
+
+		// Discover characteristics
+		cs, err := p.DiscoverCharacteristics(nil, s)
+		if err != nil {
+			connErr = fmt.Errorf("failed to discover characteristics: %w", err)
+			return
+		}
+		for _, c := range cs {
+			switch c.UUID().String() {
+			case legacyDataCharacteristic:
+
+				// Legacy devices (Lunar pre-2021, Pearl) use a single characteristic for both directions
+				btWriteCharacteristic = c
+				btNotifyCharacteristic = c
+			case dataWriteCharacteristic:
+				btWriteCharacteristic = c
+			case dataNotifyCharacteristic:
+				btNotifyCharacteristic = c
+			}
+		}
+	}
+	if btWriteCharacteristic == nil || btNotifyCharacteristic == nil {
+		connErr = fmt.Errorf("failed to find data characteristics")
+		return
+	}
+	a.setPeripheral(p, btWriteCharacteristic, btNotifyCharacteristic)
+
+	// Discover descriptors
+	if _, err := p.DiscoverDescriptors(nil, btNotifyCharacteristic); err != nil {
+		connErr = fmt.Errorf("failed to discover descriptors: %w", err)
+		return
+	}
+
+	if err := p.SetNotifyValue(btNotifyCharacteristic, a.receiveData); err != nil {
+		connErr = fmt.Errorf("failed to subscribe characteristic: %w", err)
+		return
+	}
+
+	if err := a.handshake(); err != nil {
+		connErr = fmt.Errorf("failed to perform handshake: %w", err)
+		return
+	}
+
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go a.heartbeat(heartbeatDone)
+
+	a.ready.Set(true)
+	a.reconnector.Reset()
+
+	// Wait until the peripheral is released upon disconnect (which may have happened at any
+	// point during the connection setup) or once the scale is closed
+	a.logger.Debugf("waiting to release peripheral `%s/%s`", p.Name(), p.ID())
+	select {
+	case <-released:
+	case <-a.ctx.Done():
+	}
+	a.logger.Debugf("released peripheral `%s/%s`", p.Name(), p.ID())
+}
+
+func (a *Acaia) onPeriphDisconnected(p gatt.Peripheral, err error) {
+
+	if !a.thisDevice(p) {
+		return
+	}
+
+	a.release.Release(p)
+	a.logger.Debugf("disconnected peripheral `%s/%s`", p.Name(), p.ID())
+
+	// Once the connection has been released, attempt to reconnect according to the
+	// reconnect policy (reporting the cause of the disconnect, if any)
+	go func() {
+		a.connWG.Wait()
+		if err == nil {
+			err = a.ConnectionStatus().Error
+		}
+		a.reconnector.Run(a.ctx, a.btDevice, &a.ready, err, a.setStatus)
+	}()
+}
+
+func (a *Acaia) thisDevice(p gatt.Peripheral) bool {
+
+	// If a device ID has been set, only the peripheral with this ID is accepted (several
+	// scales may share the same name), otherwise match by name
+	if a.deviceID != "" {
+		return strings.EqualFold(p.ID(), a.deviceID)
+	}
+	if a.deviceName != "" {
+		return strings.EqualFold(p.Name(), a.deviceName)
+	}
+
+	return isAcaiaDeviceName(p.Name())
+}
+
+func (a *Acaia) receiveData(_ *gatt.Characteristic, req []byte, err error) {
+
+	if err != nil {
+		return
+	}
+
+	// Messages may be split across several notifications, hence data is buffered
+	// until complete messages can be decoded
+	a.mu.Lock()
+	a.rxBuf = append(a.rxBuf, req...)
+	if len(a.rxBuf) > maxReceiveBufferSize {
+		a.logger.Warnf("discarding %d bytes of undecodable data", len(a.rxBuf))
+		a.rxBuf = nil
+		a.mu.Unlock()
+		return
+	}
+
+	var msgs []*message
+	for {
+		var msg *message
+		if msg, a.rxBuf = decode(a.rxBuf); msg == nil {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	a.mu.Unlock()
+
+	for _, msg := range msgs {
+		a.handleMessage(msg)
+	}
+}
+
+func (a *Acaia) handleMessage(msg *message) {
+	switch msg.cmd {
+	case cmdSettings:
+		s, ok := parseSettings(msg.payload)
+		if !ok {
+			return
+		}
+		a.mu.Lock()
+		a.batteryLevel = s.batteryLevel
+		a.isBuzzingOnTouch = s.isBuzzingOnTouch
+		if s.unit != scale.UnitUnknown {
+			a.unit = s.unit
+		}
+		a.mu.Unlock()
+	case cmdEvent:
+		switch msg.eventType {
+		case eventWeight:
+			a.handleWeight(msg.payload)
+		case eventHeartbeat:
+
+			// Heartbeat responses may carry a weight measurement as well
+			if len(msg.payload) > 3 && msg.payload[2] == eventWeight {
+				a.handleWeight(msg.payload[3:])
+			}
+		case eventBattery:
+			if len(msg.payload) > 0 {
+				a.mu.Lock()
+				a.batteryLevel = msg.payload[0] & 0x7f
+				a.mu.Unlock()
+			}
+		case eventKey, eventTimer:
+			a.logger.Debugf("received event %#x: %v", msg.eventType, msg.payload)
+		}
+	}
+}
+
+func (a *Acaia) handleWeight(payload []byte) {
+	weight, ok := parseWeight(payload)
+	if !ok {
+		return
+	}
+
+	a.mu.Lock()
+	if precision := parsePrecision(payload); precision != scale.PrecisionUnknown {
+		a.precision = precision
+	}
+	unit := a.unit
+	a.mu.Unlock()
+
+	dataPoint := scale.DataPoint{
+		TimeStamp: time.Now(),
+		Weight:    weight,
+		Unit:      unit,
+	}
+
+	// Convert data point to the canonical unit (if requested), dropping it if its unit
+	// is unknown
+	if a.canonicalUnit != "" {
+		converted, err := dataPoint.In(a.canonicalUnit)
+		if err != nil {
+			a.logger.Debugf("dropping data point: %s", err)
+			return
+		}
+		dataPoint = converted
+	}
+
+	// Filter data point (if filters are installed), dropping it if discarded by any filter
+	filtered, ok := a.filter.Filter(dataPoint)
+	if !ok {
+		return
+	}
+	dataPoint = filtered
+
+	// Annotate data point with the stability of the weight
+	dataPoint = a.stability.Process(dataPoint)
+
+	// Distribute data point to all consumers
+	a.broker.PublishData(dataPoint)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func isAcaiaDeviceName(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range deviceNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
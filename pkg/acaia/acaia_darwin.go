@@ -0,0 +1,7 @@
+package acaia
+
+import "github.com/fako1024/gatt"
+
+var (
+	defaultBTClientOptions = []gatt.Option{}
+)
@@ -0,0 +1,265 @@
+package acaia
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+const (
+	testTimeout        = 5 * time.Second
+	testNotifyInterval = time.Millisecond
+	testIterations     = 100
+)
+
+func newTestScale(t *testing.T, options ...func(*Acaia)) (*Acaia, *fakePeripheral, func()) {
+	t.Helper()
+
+	return newTestScaleWith(t, newFakePeripheral(), options...)
+}
+
+func newTestScaleWith(t *testing.T, p *fakePeripheral, options ...func(*Acaia)) (*Acaia, *fakePeripheral, func()) {
+	t.Helper()
+
+	a, err := New(append([]func(*Acaia){WithDevice(p.device)}, options...)...)
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.onPeriphConnected(p, nil)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := a.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to wait for connection: %s", err)
+	}
+
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.run(testNotifyInterval, done)
+	}()
+
+	return a, p, func() {
+		close(done)
+		if err := a.Close(); err != nil {
+			t.Fatalf("failed to close scale: %s", err)
+		}
+		wg.Wait()
+	}
+}
+
+// waitFor polls a condition until it is met (failing the test upon timeout)
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for i := 0; !condition(); i++ {
+		if i*int(testNotifyInterval) > int(testTimeout) {
+			t.Fatalf("condition was not met within %v", testTimeout)
+		}
+		time.Sleep(testNotifyInterval)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	a, p, cleanup := newTestScale(t)
+	defer cleanup()
+
+	// The scale responds to the settings request issued during the handshake
+	waitFor(t, func() bool { return a.BatteryLevelRaw() == 80 })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.writes) < 3 ||
+		!bytes.Equal(p.writes[0], encode(cmdIdent, payloadIdent)) ||
+		!bytes.Equal(p.writes[1], encodeNotificationRequest()) ||
+		!bytes.Equal(p.writes[2], encode(cmdGetSettings, payloadGetSettings)) {
+		t.Fatalf("unexpected handshake: %x", p.writes)
+	}
+}
+
+func TestReceiveData(t *testing.T) {
+	a, p, cleanup := newTestScale(t)
+	defer cleanup()
+	var _ scale.Publisher = a
+
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
+	// Weight events are split across notifications and have to be reassembled
+	data := <-sub.Data()
+	if data.Weight != 10. || data.Unit != scale.UnitGrams {
+		t.Fatalf("unexpected data point: %+v", data)
+	}
+
+	// A change of unit on the device is reported via the settings
+	p.mu.Lock()
+	p.unitSetting, p.batteryLevel, p.weight = unitSettingOz, 50, -0.5
+	p.mu.Unlock()
+	p.notifySettings()
+	if unit := a.Unit(); unit != scale.UnitOz {
+		t.Fatalf("unexpected unit after change of settings: %s", unit)
+	}
+	if level := a.BatteryLevel(); level != 0.5 {
+		t.Fatalf("unexpected battery level after change of settings: %v", level)
+	}
+	for data = range sub.Data() {
+		if data.Unit == scale.UnitOz && data.Weight == -0.5 {
+			break
+		}
+	}
+
+	if err := a.Tare(); err != nil {
+		t.Fatalf("failed to tare: %s", err)
+	}
+	for data = range sub.Data() {
+		if data.Weight == 0 {
+			break
+		}
+	}
+}
+
+func TestReceiveDataBeforeSettings(t *testing.T) {
+
+	// The scale does not respond to the settings request (yet)
+	p := newFakePeripheral()
+	p.unitSetting, p.ignoreSettings = unitSettingOz, true
+	a, p, cleanup := newTestScaleWith(t, p)
+	defer cleanup()
+
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
+	// Data points received before the settings must not be labelled with an assumed unit
+	if data := <-sub.Data(); data.Weight != 10. || data.Unit != scale.UnitUnknown {
+		t.Fatalf("unexpected data point before settings: %+v", data)
+	}
+	if unit := a.Unit(); unit != scale.UnitUnknown {
+		t.Fatalf("unexpected unit before settings: %s", unit)
+	}
+
+	p.notifySettings()
+	if unit := a.Unit(); unit != scale.UnitOz {
+		t.Fatalf("unexpected unit after settings: %s", unit)
+	}
+	for data := range sub.Data() {
+		if data.Unit == scale.UnitOz {
+			break
+		}
+	}
+}
+
+func TestReceiveDataBeforeSettingsCanonicalUnit(t *testing.T) {
+
+	// The scale does not respond to the settings request (yet)
+	p := newFakePeripheral()
+	p.unitSetting, p.ignoreSettings = unitSettingOz, true
+	a, p, cleanup := newTestScaleWith(t, p, WithCanonicalUnit(scale.UnitGrams))
+	defer cleanup()
+
+	sub := a.Subscribe()
+	defer sub.Unsubscribe()
+
+	// Data points received before the settings cannot be converted and must be dropped
+	select {
+	case data := <-sub.Data():
+		t.Fatalf("unexpected data point before settings: %+v", data)
+	case <-time.After(100 * testNotifyInterval):
+	}
+
+	p.notifySettings()
+	if data := <-sub.Data(); data.Unit != scale.UnitGrams || math.Abs(data.Weight-283.495) > 0.01 {
+		t.Fatalf("unexpected data point after settings: %+v", data)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	a, p, cleanup := newTestScale(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < testIterations; i++ {
+				if err := fn(); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Getters
+	run(func() error {
+		_ = a.ConnectionStatus()
+		_ = a.DeviceInfo()
+		_ = a.BatteryLevel()
+		_ = a.BatteryLevelRaw()
+		_ = a.IsBuzzingOnTouch()
+		_ = a.Unit()
+		_ = a.Precision()
+		_ = a.ElapsedTime()
+		return nil
+	})
+
+	// Notifications
+	run(func() error {
+		p.notifySettings()
+		return nil
+	})
+
+	// Commands
+	run(a.Tare)
+	run(func() error {
+		if err := a.StartTimer(); err != nil {
+			return err
+		}
+		if err := a.StopTimer(); err != nil {
+			return err
+		}
+		return a.ResetTimer()
+	})
+
+	wg.Wait()
+}
+
+func TestDisconnectResetsPeripheral(t *testing.T) {
+	a, p, cleanup := newTestScale(t, WithReconnectPolicy(scale.NeverReconnect))
+	defer cleanup()
+
+	a.onPeriphDisconnected(p, nil)
+	waitFor(t, func() bool { return a.ConnectionStatus().State == scale.StateDisconnected })
+
+	// Commands must not be sent to the peripheral of a previous connection
+	if err := a.Tare(); err == nil {
+		t.Fatalf("expected error sending command while disconnected")
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.btPeripheral != nil || a.btWriteCharacteristic != nil || a.btNotifyCharacteristic != nil {
+		t.Fatalf("peripheral / characteristics not reset upon disconnect")
+	}
+}
+
+func TestCloseDisconnect(t *testing.T) {
+	a, p, cleanup := newTestScale(t)
+
+	// Releasing the peripheral upon closing the scale causes a disconnect, which must be
+	// handled gracefully after the scale has been closed
+	cleanup()
+	a.onPeriphDisconnected(p, nil)
+}
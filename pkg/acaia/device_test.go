@@ -0,0 +1,185 @@
+package acaia
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fako1024/gatt"
+)
+
+// fakeDevice denotes a no-op bluetooth device that can be injected via WithDevice()
+type fakeDevice struct{}
+
+func (d *fakeDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	stateChanged(d, gatt.StatePoweredOn)
+	return nil
+}
+func (d *fakeDevice) Advertise(a *gatt.AdvPacket) error                          { return nil }
+func (d *fakeDevice) AdvertiseNameAndServices(name string, ss []gatt.UUID) error { return nil }
+func (d *fakeDevice) AdvertiseIBeaconData(b []byte) error                        { return nil }
+func (d *fakeDevice) AdvertiseIBeacon(u gatt.UUID, major, minor uint16, pwr int8) error {
+	return nil
+}
+func (d *fakeDevice) StopAdvertising() error                   { return nil }
+func (d *fakeDevice) RemoveAllServices() error                 { return nil }
+func (d *fakeDevice) AddService(s *gatt.Service) error         { return nil }
+func (d *fakeDevice) SetServices(ss []*gatt.Service) error     { return nil }
+func (d *fakeDevice) Scan(ss []gatt.UUID, dup bool) error      { return nil }
+func (d *fakeDevice) StopScanning() error                      { return nil }
+func (d *fakeDevice) Connect(p gatt.Peripheral) error          { return nil }
+func (d *fakeDevice) CancelConnection(p gatt.Peripheral) error { return nil }
+func (d *fakeDevice) Handle(h ...gatt.Handler)                 {}
+func (d *fakeDevice) Close() error                             { return nil }
+func (d *fakeDevice) Option(o ...gatt.Option) error            { return nil }
+
+// fakePeripheral simulates an Acaia scale (using separate write / notify characteristics),
+// applying commands to its internal state and emitting messages
+type fakePeripheral struct {
+	device *fakeDevice
+
+	service              *gatt.Service
+	writeCharacteristic  *gatt.Characteristic
+	notifyCharacteristic *gatt.Characteristic
+
+	weight         float64
+	unitSetting    byte
+	batteryLevel   byte
+	ignoreSettings bool
+	notifyFn       func(*gatt.Characteristic, []byte, error)
+	writes         [][]byte
+
+	mu sync.Mutex
+}
+
+func newFakePeripheral() *fakePeripheral {
+	service := gatt.NewService(gatt.MustParseUUID(dataService))
+
+	return &fakePeripheral{
+		device:               &fakeDevice{},
+		service:              service,
+		writeCharacteristic:  gatt.NewCharacteristic(gatt.MustParseUUID(dataWriteCharacteristic), service, gatt.CharWriteNR, 0, 0),
+		notifyCharacteristic: gatt.NewCharacteristic(gatt.MustParseUUID(dataNotifyCharacteristic), service, gatt.CharNotify, 0, 0),
+		weight:               10.,
+		unitSetting:          unitSettingGrams,
+		batteryLevel:         80,
+	}
+}
+
+func (p *fakePeripheral) Device() gatt.Device       { return p.device }
+func (p *fakePeripheral) ID() string                { return "00:1C:97:1A:2B:3C" }
+func (p *fakePeripheral) Name() string              { return "LUNAR-1A2B3C" }
+func (p *fakePeripheral) Services() []*gatt.Service { return []*gatt.Service{p.service} }
+func (p *fakePeripheral) DiscoverServices(s []gatt.UUID) ([]*gatt.Service, error) {
+	return p.Services(), nil
+}
+func (p *fakePeripheral) DiscoverIncludedServices(ss []gatt.UUID, s *gatt.Service) ([]*gatt.Service, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) DiscoverCharacteristics(c []gatt.UUID, s *gatt.Service) ([]*gatt.Characteristic, error) {
+	return []*gatt.Characteristic{p.writeCharacteristic, p.notifyCharacteristic}, nil
+}
+func (p *fakePeripheral) DiscoverDescriptors(d []gatt.UUID, c *gatt.Characteristic) ([]*gatt.Descriptor, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) ReadCharacteristic(c *gatt.Characteristic) ([]byte, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) ReadLongCharacteristic(c *gatt.Characteristic) ([]byte, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) ReadDescriptor(d *gatt.Descriptor) ([]byte, error)  { return nil, nil }
+func (p *fakePeripheral) WriteDescriptor(d *gatt.Descriptor, b []byte) error { return nil }
+func (p *fakePeripheral) SetIndicateValue(c *gatt.Characteristic, f func(*gatt.Characteristic, []byte, error)) error {
+	return nil
+}
+func (p *fakePeripheral) SetMTU(mtu uint16) error { return nil }
+func (p *fakePeripheral) ReadRSSI() int           { return -60 }
+
+func (p *fakePeripheral) SetNotifyValue(c *gatt.Characteristic, f func(*gatt.Characteristic, []byte, error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notifyFn = f
+	return nil
+}
+
+func (p *fakePeripheral) WriteCharacteristic(c *gatt.Characteristic, b []byte, noRsp bool) error {
+	p.mu.Lock()
+	p.writes = append(p.writes, append([]byte{}, b...))
+	var respondSettings bool
+	if len(b) > 2 {
+		switch b[2] {
+		case cmdTare:
+			p.weight = 0
+		case cmdGetSettings:
+			respondSettings = !p.ignoreSettings
+		}
+	}
+	p.mu.Unlock()
+
+	if respondSettings {
+		p.notifySettings()
+	}
+
+	return nil
+}
+
+// notifyRaw emits raw data (split into chunks of a maximum size, if > 0)
+func (p *fakePeripheral) notifyRaw(b []byte, chunkSize int) {
+	p.mu.Lock()
+	notifyFn := p.notifyFn
+	p.mu.Unlock()
+
+	if notifyFn == nil {
+		return
+	}
+	for len(b) > 0 {
+		n := len(b)
+		if chunkSize > 0 && n > chunkSize {
+			n = chunkSize
+		}
+		notifyFn(p.notifyCharacteristic, b[:n], nil)
+		b = b[n:]
+	}
+}
+
+// notifySettings emits a settings message reflecting the current state
+func (p *fakePeripheral) notifySettings() {
+	p.mu.Lock()
+	payload := []byte{0x09, p.batteryLevel, p.unitSetting, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00}
+	p.mu.Unlock()
+
+	p.notifyRaw(encode(cmdSettings, payload), 0)
+}
+
+// notify emits a weight event reflecting the current state (split into two notifications,
+// as done by actual devices)
+func (p *fakePeripheral) notify() {
+	p.mu.Lock()
+	weight := p.weight
+	p.mu.Unlock()
+
+	value, sign := int(math.Round(math.Abs(weight)*10)), byte(0x00)
+	if weight < 0 {
+		sign = 0x02
+	}
+	payload := []byte{0x08, eventWeight, byte(value), byte(value >> 8), byte(value >> 16), 0x00, 0x01, sign}
+
+	p.notifyRaw(encode(cmdEvent, payload), 7)
+}
+
+// run continuously emits weight events until the done channel is closed
+func (p *fakePeripheral) run(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.notify()
+		}
+	}
+}
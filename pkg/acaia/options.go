@@ -0,0 +1,100 @@
+package acaia
+
+import (
+	"context"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+// WithDeviceID sets the Bluetooth device ID (if set, only the peripheral with this ID is
+// connected to, regardless of its name)
+func WithDeviceID(deviceID string) func(*Acaia) {
+	return func(a *Acaia) {
+		a.deviceID = deviceID
+	}
+}
+
+// WithDeviceName sets the Bluetooth device name (disabling the default matching
+// of known Acaia device name prefixes)
+func WithDeviceName(deviceName string) func(*Acaia) {
+	return func(a *Acaia) {
+		a.deviceName = deviceName
+	}
+}
+
+// WithDevice sets the Bluetooth device
+func WithDevice(btDevice gatt.Device) func(*Acaia) {
+	return func(a *Acaia) {
+		a.btDevice = btDevice
+	}
+}
+
+// WithLogger sets a logger
+func WithLogger(logger scale.Logger) func(*Acaia) {
+	return func(a *Acaia) {
+		a.logger = logger
+	}
+}
+
+// WithHeartbeatInterval sets the interval in which heartbeats are sent to the scale
+func WithHeartbeatInterval(interval time.Duration) func(*Acaia) {
+	return func(a *Acaia) {
+		a.heartbeatInterval = interval
+	}
+}
+
+// WithContext sets a context, terminating the connection to the device (including any
+// ongoing scan) once it ends
+func WithContext(ctx context.Context) func(*Acaia) {
+	return func(a *Acaia) {
+		a.ctx = ctx
+	}
+}
+
+// WithReconnectPolicy sets the strategy to reconnect to the scale after the connection
+// was lost (see scale.ReconnectPolicy for the default behavior)
+func WithReconnectPolicy(policy scale.ReconnectPolicy) func(*Acaia) {
+	return func(a *Acaia) {
+		a.reconnector.Policy = policy
+	}
+}
+
+// WithDeliveryPolicy sets the behavior when putting data points / state changes on a
+// channel whose consumer does not keep up. It applies to the channels set via
+// SetDataChannel() / SetStateChangeChannel() and serves as default for subscriptions (by
+// default, delivery never blocks, see scale.DeliveryDefault)
+func WithDeliveryPolicy(data, state scale.DeliveryPolicy) func(*Acaia) {
+	return func(a *Acaia) {
+		a.broker.DataPolicy = data
+		a.broker.StatePolicy = state
+	}
+}
+
+// WithStabilityDetection sets the time window and the tolerance (in grams) used to determine
+// if the weight is stable (by default, a deviation of up to 0.2g within 1s)
+func WithStabilityDetection(window time.Duration, tolerance float64) func(*Acaia) {
+	return func(a *Acaia) {
+		a.stability.Window = window
+		a.stability.Tolerance = tolerance
+	}
+}
+
+// WithCanonicalUnit ensures that all data points are delivered in a certain unit, regardless
+// of the unit selected on the scale (data points in an unknown unit, i.e. those received
+// before the settings of the scale, are dropped)
+func WithCanonicalUnit(unit scale.Unit) func(*Acaia) {
+	return func(a *Acaia) {
+		a.canonicalUnit = unit
+	}
+}
+
+// WithFilter installs a filter pipeline for the weight readings, i.e. all data points
+// are passed through the given filters in order before being delivered (the unfiltered
+// weight being retained in their RawWeight field)
+func WithFilter(filters ...scale.Filter) func(*Acaia) {
+	return func(a *Acaia) {
+		a.filter = append(a.filter, filters...)
+	}
+}
@@ -0,0 +1,197 @@
+package acaia
+
+import (
+	"math"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+const (
+	header1 = 0xef
+	header2 = 0xdd
+
+	// Minimum length of a message: header (2) + command (1) + length (1) + checksum (2)
+	minMsgLen = 6
+
+	cmdHeartbeat   = 0x00
+	cmdTare        = 0x04
+	cmdGetSettings = 0x06
+	cmdSettings    = 0x08
+	cmdIdent       = 0x0b
+	cmdEvent       = 0x0c
+	cmdTimer       = 0x0d
+
+	eventWeight    = 0x05
+	eventBattery   = 0x06
+	eventTimer     = 0x07
+	eventKey       = 0x08
+	eventHeartbeat = 0x0b
+
+	timerStart = 0x00
+	timerReset = 0x01
+	timerStop  = 0x02
+
+	unitSettingGrams = 0x02
+	unitSettingOz    = 0x05
+)
+
+var (
+	payloadIdent       = []byte("012345678901234")
+	payloadHeartbeat   = []byte{0x02, 0x00}
+	payloadGetSettings = make([]byte, 16)
+	payloadTare        = []byte{0x00}
+
+	// Request weight, battery, timer, key and settings notifications
+	payloadNotificationRequest = []byte{0x00, 0x01, 0x01, 0x02, 0x02, 0x05, 0x03, 0x04}
+)
+
+// message denotes a single decoded message received from the scale
+type message struct {
+	cmd       byte
+	eventType byte
+	payload   []byte
+}
+
+// settings denotes the decoded settings of the scale
+type settings struct {
+	batteryLevel     byte
+	unit             scale.Unit
+	isBuzzingOnTouch bool
+}
+
+// encode frames a command and its payload, appending the checksum
+func encode(cmd byte, payload []byte) []byte {
+	var cksum1, cksum2 byte
+	for i, b := range payload {
+		if i%2 == 0 {
+			cksum1 += b
+		} else {
+			cksum2 += b
+		}
+	}
+
+	msg := make([]byte, 0, len(payload)+5)
+	msg = append(msg, header1, header2, cmd)
+	msg = append(msg, payload...)
+
+	return append(msg, cksum1, cksum2)
+}
+
+// encodeNotificationRequest frames the request to enable event notifications
+func encodeNotificationRequest() []byte {
+	return encode(cmdEvent, append([]byte{byte(len(payloadNotificationRequest) + 1)}, payloadNotificationRequest...))
+}
+
+// encodeTimer frames a timer command (start / stop / reset)
+func encodeTimer(action byte) []byte {
+	return encode(cmdTimer, []byte{0x00, action})
+}
+
+// decode attempts to extract the first complete message from the provided buffer,
+// returning the message (if any) and the remainder of the buffer
+func decode(buf []byte) (*message, []byte) {
+
+	// Find the start of the next message
+	start := -1
+	for i := 0; i < len(buf)-1; i++ {
+		if buf[i] == header1 && buf[i+1] == header2 {
+			start = i
+			break
+		}
+	}
+
+	// If no header was found, retain only a potential partial header at the end
+	if start < 0 {
+		if len(buf) > 0 && buf[len(buf)-1] == header1 {
+			return nil, buf[len(buf)-1:]
+		}
+		return nil, nil
+	}
+	buf = buf[start:]
+	if len(buf) < minMsgLen {
+		return nil, buf
+	}
+
+	end := int(buf[3]) + 5
+	if end > len(buf) {
+		return nil, buf
+	}
+
+	msg := message{
+		cmd: buf[2],
+	}
+	switch msg.cmd {
+	case cmdEvent:
+		msg.eventType = buf[4]
+		msg.payload = buf[5:end]
+	default:
+		msg.payload = buf[3:end]
+	}
+
+	return &msg, buf[end:]
+}
+
+// parseWeight decodes a weight from the payload of a weight event
+func parseWeight(payload []byte) (float64, bool) {
+	if len(payload) < 6 {
+		return 0., false
+	}
+
+	value := float64(int(payload[0]) | int(payload[1])<<8 | int(payload[2])<<16)
+	if exp := payload[4]; exp > 0 && exp <= 4 {
+		value /= math.Pow10(int(exp))
+	}
+	if payload[5]&0x02 != 0 {
+		value = -value
+	}
+
+	return value, true
+}
+
+// parsePrecision decodes the precision from the payload of a weight event
+func parsePrecision(payload []byte) scale.Precision {
+	if len(payload) < 6 {
+		return scale.PrecisionUnknown
+	}
+
+	switch payload[4] {
+	case 1:
+		return scale.PrecisionLow
+	case 2:
+		return scale.PrecisionHigh
+	}
+
+	return scale.PrecisionUnknown
+}
+
+// parseSettings decodes the payload of a settings message
+func parseSettings(payload []byte) (settings, bool) {
+	if len(payload) < 7 {
+		return settings{}, false
+	}
+
+	return settings{
+		batteryLevel:     payload[1] & 0x7f,
+		unit:             parseUnit(payload[2]),
+		isBuzzingOnTouch: payload[6] == 0x01,
+	}, true
+}
+
+func parseUnit(data byte) scale.Unit {
+	switch data {
+	case unitSettingGrams:
+		return scale.UnitGrams
+	case unitSettingOz:
+		return scale.UnitOz
+	}
+
+	return scale.UnitUnknown
+}
+
+func parseBatteryLevel(data byte) float64 {
+	if data > 100 {
+		return 1.
+	}
+
+	return float64(data) / 100.
+}
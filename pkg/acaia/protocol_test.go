@@ -0,0 +1,98 @@
+package acaia
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+func TestEncodeNotificationRequest(t *testing.T) {
+	expected := []byte{0xef, 0xdd, 0x0c, 0x09, 0x00, 0x01, 0x01, 0x02, 0x02, 0x05, 0x03, 0x04, 0x15, 0x06}
+	if msg := encodeNotificationRequest(); !bytes.Equal(msg, expected) {
+		t.Fatalf("unexpected notification request, want %x, have %x", expected, msg)
+	}
+}
+
+func TestDecode(t *testing.T) {
+
+	// Weight event (100.0g) and settings message (83% battery, grams, buzzer on)
+	weightEvent := []byte{0xef, 0xdd, 0x0c, 0x08, 0x05, 0xe8, 0x03, 0x00, 0x00, 0x01, 0x00, 0xf1, 0x08}
+	settingsMsg := []byte{0xef, 0xdd, 0x08, 0x09, 0x53, 0x02, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x11, 0x53}
+
+	for _, cs := range []struct {
+		name      string
+		buf       []byte
+		cmd       byte
+		eventType byte
+		payload   []byte
+		remainder []byte
+	}{
+		{"empty", nil, 0, 0, nil, nil},
+		{"no header", []byte{0x01, 0x02, 0x03}, 0, 0, nil, nil},
+		{"partial header", []byte{0x01, 0x02, 0xef}, 0, 0, nil, []byte{0xef}},
+		{"short message", []byte{0x01, 0xef, 0xdd, 0x0c}, 0, 0, nil, []byte{0xef, 0xdd, 0x0c}},
+		{"incomplete message", weightEvent[:10], 0, 0, nil, weightEvent[:10]},
+		{"weight event", weightEvent, cmdEvent, eventWeight, weightEvent[5:], []byte{}},
+		{"settings", settingsMsg, cmdSettings, 0, settingsMsg[3:], []byte{}},
+		{"leading garbage and trailing message", append(append([]byte{0x00, 0x42}, weightEvent...), settingsMsg[:4]...),
+			cmdEvent, eventWeight, weightEvent[5:], settingsMsg[:4]},
+	} {
+		t.Run(cs.name, func(t *testing.T) {
+			msg, remainder := decode(cs.buf)
+			if !bytes.Equal(remainder, cs.remainder) {
+				t.Fatalf("unexpected remainder, want %x, have %x", cs.remainder, remainder)
+			}
+			if cs.cmd == 0 {
+				if msg != nil {
+					t.Fatalf("unexpected message: %+v", msg)
+				}
+				return
+			}
+			if msg == nil || msg.cmd != cs.cmd || msg.eventType != cs.eventType || !bytes.Equal(msg.payload, cs.payload) {
+				t.Fatalf("unexpected message: %+v", msg)
+			}
+		})
+	}
+}
+
+func TestParseWeight(t *testing.T) {
+	for _, cs := range []struct {
+		payload   []byte
+		weight    float64
+		precision scale.Precision
+		ok        bool
+	}{
+		{[]byte{0xe8, 0x03, 0x00, 0x00, 0x01, 0x00}, 100., scale.PrecisionLow, true},
+		{[]byte{0xd2, 0x04, 0x00, 0x00, 0x02, 0x02}, -12.34, scale.PrecisionHigh, true},
+		{[]byte{0x05, 0x00, 0x00, 0x00, 0x00, 0x00}, 5., scale.PrecisionUnknown, true},
+		{[]byte{0x40, 0x42, 0x0f, 0x00, 0x01, 0x00, 0xf1, 0x08}, 100000., scale.PrecisionLow, true},
+		{[]byte{0xe8, 0x03, 0x00, 0x00, 0x01}, 0., scale.PrecisionUnknown, false},
+	} {
+		weight, ok := parseWeight(cs.payload)
+		if ok != cs.ok || weight != cs.weight {
+			t.Fatalf("unexpected weight for payload %x, want %v/%v, have %v/%v", cs.payload, cs.weight, cs.ok, weight, ok)
+		}
+		if precision := parsePrecision(cs.payload); precision != cs.precision {
+			t.Fatalf("unexpected precision for payload %x, want %v, have %v", cs.payload, cs.precision, precision)
+		}
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	for _, cs := range []struct {
+		payload  []byte
+		expected settings
+		ok       bool
+	}{
+		{[]byte{0x09, 0x53, 0x02, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00}, settings{83, scale.UnitGrams, true}, true},
+		{[]byte{0x09, 0xe4, 0x05, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00}, settings{100, scale.UnitOz, false}, true},
+		{[]byte{0x09, 0x10, 0x07, 0x00, 0x05, 0x00, 0x00}, settings{16, scale.UnitUnknown, false}, true},
+		{[]byte{0x09, 0x53, 0x02, 0x00, 0x05, 0x00}, settings{}, false},
+	} {
+		s, ok := parseSettings(cs.payload)
+		if ok != cs.ok || s != cs.expected {
+			t.Fatalf("unexpected settings for payload %x, want %+v/%v, have %+v/%v", cs.payload, cs.expected, cs.ok, s, ok)
+		}
+	}
+}
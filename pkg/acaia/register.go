@@ -0,0 +1,29 @@
+package acaia
+
+import (
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+// DriverName denotes the name of the driver in the scale driver registry
+const DriverName = "acaia"
+
+func init() {
+	scale.Register(scale.Driver{
+		Name: DriverName,
+		Match: func(p gatt.Peripheral, _ *gatt.Advertisement) bool {
+			return isAcaiaDeviceName(p.Name())
+		},
+		New: func(cfg scale.DriverConfig) (scale.Basic, error) {
+			return New(
+				WithDeviceID(cfg.DeviceID),
+				WithDevice(cfg.Device),
+				WithLogger(cfg.Logger),
+				WithReconnectPolicy(cfg.ReconnectPolicy),
+				WithDeliveryPolicy(cfg.DataDeliveryPolicy, cfg.StateDeliveryPolicy),
+				WithCanonicalUnit(cfg.CanonicalUnit),
+				WithFilter(cfg.Filters...),
+			)
+		},
+	})
+}
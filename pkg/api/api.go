@@ -1,6 +1,9 @@
 package api
 
 import (
+	"sync"
+	"time"
+
 	"github.com/fako1024/btscale/pkg/scale"
 	"github.com/gofiber/fiber/v2"
 )
@@ -9,17 +12,62 @@ import (
 type API struct {
 	scale  scale.Scale
 	router *fiber.App
+	flow   scale.FlowProcessor
+	target scale.TargetWatcher
+
+	logger        scale.Logger
+	canonicalUnit scale.Unit
+	last          *scale.DataPoint
+
+	mu sync.RWMutex
 }
 
-// New instantiates a new API
-func New(s scale.Scale, endpoint string) *API {
+// New instantiates a new API, executing functional options, if any
+func New(s scale.Scale, endpoint string, options ...func(*API)) *API {
 
-	api := API{
+	api := &API{
 		scale:  s,
 		router: fiber.New(),
+		logger: &scale.NullLogger{},
+		target: scale.TargetWatcher{
+			Buzzer: s,
+		},
+	}
+
+	// Execute functional options (if any), see options.go for implementation
+	for _, option := range options {
+		option(api)
+	}
+
+	// An invalid canonical unit is disregarded (serving weights in the unit of the scale)
+	if api.canonicalUnit != "" && !api.canonicalUnit.IsValid() {
+		api.logger.Warnf("ignoring invalid canonical unit `%s`, serving weights in the unit of the scale", api.canonicalUnit)
+		api.canonicalUnit = ""
+	}
+
+	// Continuously track the weight, compute the flow rate and watch the target weight
+	// from the data of the scale (which requires support for subscriptions, leaving the data
+	// handler / channel of the scale to the caller)
+	if pub, ok := s.(scale.Publisher); ok {
+		sub := pub.Subscribe()
+		go func() {
+			for data := range sub.Data() {
+				api.process(data)
+			}
+		}()
+	} else {
+		api.logger.Warnf("scale does not support subscriptions, weight, flow rate and target weight are unavailable")
 	}
 
 	// Setup routes
+	api.router.Get("/device_info", api.handleDeviceInfo())
+	api.router.Get("/weight", api.handleWeight())
+	api.router.Get("/flow_rate", api.handleFlowRate())
+	api.router.Get("/target", api.handleGetTarget())
+	api.router.Put("/target", api.handleSetTarget())
+	api.router.Delete("/target", api.handleDeleteTarget())
+	api.router.Get("/link_quality", api.handleLinkQuality())
+	api.router.Get("/delivery_stats", api.handleDeliveryStats())
 	api.router.Post("/toggle_buzzer", api.handleToggleBuzzer())
 
 	// Start to listen in goroutine
@@ -29,7 +77,159 @@ func New(s scale.Scale, endpoint string) *API {
 		}
 	}()
 
-	return &api
+	return api
+}
+
+func (api *API) handleDeviceInfo() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		di, ok := api.scale.(scale.DeviceInformation)
+		if !ok {
+			return fiber.ErrNotImplemented
+		}
+
+		return c.JSON(di.DeviceInfo())
+	}
+}
+
+// targetStatus denotes the configuration / state of the target weight watcher
+type targetStatus struct {
+	Target  float64 `json:"target"`
+	LagMs   int64   `json:"lag_ms"`
+	IsArmed bool    `json:"is_armed"`
+}
+
+// process converts a data point to the canonical unit (if requested, dropping it if its
+// unit is unknown) and updates the current weight and flow rate
+func (api *API) process(data scale.DataPoint) {
+	if api.canonicalUnit != "" {
+		converted, err := data.In(api.canonicalUnit)
+		if err != nil {
+			return
+		}
+		data = converted
+	}
+
+	api.flow.Process(data)
+	api.target.Process(data)
+
+	api.mu.Lock()
+	api.last = &data
+	api.mu.Unlock()
+}
+
+func (api *API) handleWeight() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		api.mu.RLock()
+		last := api.last
+		api.mu.RUnlock()
+
+		if last == nil {
+			return fiber.ErrServiceUnavailable
+		}
+
+		return c.JSON(struct {
+			TimeStamp time.Time  `json:"timestamp"`
+			Unit      scale.Unit `json:"unit"`
+			Weight    float64    `json:"weight"`
+			Stable    bool       `json:"stable"`
+		}{
+			TimeStamp: last.TimeStamp,
+			Unit:      last.Unit,
+			Weight:    last.Weight,
+			Stable:    last.Stable,
+		})
+	}
+}
+
+func (api *API) handleFlowRate() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(api.flow.Rate())
+	}
+}
+
+func (api *API) handleGetTarget() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(api.targetStatus())
+	}
+}
+
+func (api *API) handleSetTarget() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Target float64 `json:"target"`
+			LagMs  *int64  `json:"lag_ms"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if req.Target <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "target weight must be positive")
+		}
+
+		if req.LagMs != nil {
+			if *req.LagMs < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "lag must not be negative")
+			}
+			api.target.SetLag(time.Duration(*req.LagMs) * time.Millisecond)
+		}
+		api.target.SetTarget(req.Target)
+
+		return c.JSON(api.targetStatus())
+	}
+}
+
+func (api *API) handleDeleteTarget() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		api.target.SetTarget(0)
+
+		return c.JSON(api.targetStatus())
+	}
+}
+
+func (api *API) targetStatus() targetStatus {
+	return targetStatus{
+		Target:  api.target.Target(),
+		LagMs:   api.target.Lag().Milliseconds(),
+		IsArmed: api.target.IsArmed(),
+	}
+}
+
+func (api *API) handleDeliveryStats() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		pub, ok := api.scale.(scale.Publisher)
+		if !ok {
+			return fiber.ErrNotImplemented
+		}
+
+		return c.JSON(pub.DeliveryStats())
+	}
+}
+
+func (api *API) handleLinkQuality() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		lq, ok := api.scale.(scale.LinkQuality)
+		if !ok {
+			return fiber.ErrNotImplemented
+		}
+
+		// The signal strength is omitted if it cannot be obtained while connected (instead
+		// of reporting a stale value)
+		res := struct {
+			RSSISupported bool               `json:"rssi_supported"`
+			RSSI          *int               `json:"rssi,omitempty"`
+			RSSIHistory   []scale.RSSISample `json:"rssi_history,omitempty"`
+			FrameStats    scale.FrameStats   `json:"frame_stats"`
+		}{
+			RSSISupported: lq.IsRSSISupported(),
+			FrameStats:    lq.FrameStats(),
+		}
+		if res.RSSISupported {
+			rssi := lq.RSSI()
+			res.RSSI, res.RSSIHistory = &rssi, lq.RSSIHistory()
+		}
+
+		return c.JSON(res)
+	}
 }
 
 func (api *API) handleToggleBuzzer() func(c *fiber.Ctx) error {
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/fako1024/btscale/pkg/mock"
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+// testLogger records the warnings emitted
+type testLogger struct {
+	scale.NullLogger
+
+	warnings []string
+	mu       sync.Mutex
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.warnings = append(l.warnings, format)
+}
+
+func TestInvalidCanonicalUnit(t *testing.T) {
+	m, err := mock.New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	// An invalid canonical unit must be ignored (serving weights in the unit of the scale)
+	logger := &testLogger{}
+	api := New(m, "127.0.0.1:0", WithCanonicalUnit("kg"), WithLogger(logger))
+	defer api.router.Shutdown()
+
+	if api.canonicalUnit != "" {
+		t.Fatalf("unexpected canonical unit: %s", api.canonicalUnit)
+	}
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.warnings) != 1 {
+		t.Fatalf("unexpected warnings: %v", logger.warnings)
+	}
+}
+
+// basicScale hides all optional functionality of the wrapped scale
+type basicScale struct {
+	scale.Scale
+}
+
+func TestOptionalFunctionality(t *testing.T) {
+	m, err := mock.New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	// A scale lacking optional functionality must still be served
+	logger := &testLogger{}
+	api := New(basicScale{m}, "127.0.0.1:0", WithLogger(logger))
+	defer api.router.Shutdown()
+
+	for _, path := range []string{"/device_info", "/delivery_stats", "/link_quality"} {
+		resp, err := api.router.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("failed to perform request to %s: %s", path, err)
+		}
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Fatalf("unexpected status code for %s: %d", path, resp.StatusCode)
+		}
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.warnings) != 1 {
+		t.Fatalf("unexpected warnings: %v", logger.warnings)
+	}
+}
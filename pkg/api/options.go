@@ -0,0 +1,19 @@
+package api
+
+import "github.com/fako1024/btscale/pkg/scale"
+
+// WithLogger sets a logger
+func WithLogger(logger scale.Logger) func(*API) {
+	return func(api *API) {
+		api.logger = logger
+	}
+}
+
+// WithCanonicalUnit ensures that all weights / flow rates are served in a certain unit,
+// regardless of the unit selected on the scale (an invalid unit is ignored, logging a
+// warning)
+func WithCanonicalUnit(unit scale.Unit) func(*API) {
+	return func(api *API) {
+		api.canonicalUnit = unit
+	}
+}
@@ -0,0 +1,652 @@
+package decent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+const (
+	defaultDeviceName        = "Decent Scale"
+	dataService              = "fff0"
+	dataNotifyCharacteristic = "fff4"
+	dataWriteCharacteristic  = "36f5"
+)
+
+// Decent denotes a Decent bluetooth scale
+type Decent struct {
+	connectionStatus scale.ConnectionStatus
+	batteryLevel     byte
+	isUSBPowered     bool
+	isLEDOn          bool
+	isStable         bool
+	unit             scale.Unit
+	canonicalUnit    scale.Unit
+	filter           scale.Pipeline
+	tareCounter      byte
+	deviceInfo       scale.DeviceInfo
+
+	timer scale.Stopwatch
+
+	deviceID     string
+	deviceName   string
+	ledOnConnect bool
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	ready       scale.ReadySignal
+	release     scale.ReleaseSignal
+	reconnector scale.Reconnector
+	broker      scale.Broker
+	stability   scale.StabilityDetector
+	connWG      sync.WaitGroup
+
+	btDevice               gatt.Device
+	btPeripheral           gatt.Peripheral
+	btWriteCharacteristic  *gatt.Characteristic
+	btNotifyCharacteristic *gatt.Characteristic
+
+	logger scale.Logger
+
+	mu sync.RWMutex
+}
+
+// New instantiates a new Decent struct, executing functional options, if any
+func New(options ...func(*Decent)) (*Decent, error) {
+
+	// Initialize a new instance of a Decent scale
+	d := &Decent{
+		deviceName:   defaultDeviceName,
+		unit:         scale.UnitGrams,
+		ledOnConnect: true,
+		ctx:          context.Background(),
+		logger:       &scale.NullLogger{},
+	}
+
+	// Execute functional options (if any), see options.go for implementation
+	for _, option := range options {
+		option(d)
+	}
+	if d.canonicalUnit != "" && !d.canonicalUnit.IsValid() {
+		return nil, fmt.Errorf("invalid canonical unit: %s", d.canonicalUnit)
+	}
+
+	// Initialize a new GATT device (if not provided as option)
+	if d.btDevice == nil {
+		btDevice, err := gatt.NewDevice(defaultBTClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		d.btDevice = btDevice
+	}
+
+	// Terminate the connection to the device once the context ends
+	d.ctx, d.cancel = context.WithCancel(d.ctx)
+	go func() {
+		<-d.ctx.Done()
+		if err := d.Close(); err != nil {
+			d.logger.Warnf("failed to close device upon termination of context: %s", err)
+		}
+	}()
+
+	// Release the device (terminating the context) if it cannot be initialized
+	if err := d.subscribe(); err != nil {
+		if closeErr := d.Close(); closeErr != nil {
+			d.logger.Warnf("failed to close device after failed initialization: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// ConnectionStatus returns the current status of the bluetooth device
+func (d *Decent) ConnectionStatus() scale.ConnectionStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.connectionStatus
+}
+
+// WaitConnected blocks until the scale is connected and usable, returning an error if the
+// context ends or the scale is closed before
+func (d *Decent) WaitConnected(ctx context.Context) error {
+	return d.ready.Wait(ctx)
+}
+
+// DeviceInfo returns the information provided by the Device Information service of the
+// scale (populated upon connection)
+func (d *Decent) DeviceInfo() scale.DeviceInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.deviceInfo
+}
+
+// DeliveryStats returns the number of data points / state changes dropped so far
+// (according to the delivery policy)
+func (d *Decent) DeliveryStats() scale.DeliveryStats {
+	return d.broker.Stats()
+}
+
+// IsBuzzingOnTouch returns if the scale buzzer is turned on or not (on user interaction),
+// the Decent scale does not have a buzzer, hence this is always false
+func (d *Decent) IsBuzzingOnTouch() bool {
+	return false
+}
+
+// BatteryLevel returns the current battery level (or 1.0 if powered via USB)
+func (d *Decent) BatteryLevel() float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.isUSBPowered {
+		return 1.
+	}
+	return parseBatteryLevel(d.batteryLevel)
+}
+
+// BatteryLevelRaw returns the current battery level in its raw form
+func (d *Decent) BatteryLevelRaw() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return int(d.batteryLevel)
+}
+
+// IsUSBPowered returns if the scale is currently powered via USB
+func (d *Decent) IsUSBPowered() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.isUSBPowered
+}
+
+// IsStable returns if the last weight measurement was reported as stable by the scale
+func (d *Decent) IsStable() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.isStable
+}
+
+// Unit returns the current weight unit shown on the display (the scale always reports
+// the weight in grams, hence data points are converted to this unit)
+func (d *Decent) Unit() scale.Unit {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.unit
+}
+
+// Subscribe registers a new subscription to the data points and state changes of the
+// scale, executing functional options, if any
+func (d *Decent) Subscribe(options ...func(*scale.Subscription)) *scale.Subscription {
+	return d.broker.Subscribe(options...)
+}
+
+// SetStabilityChangeHandler defines a handler function that is called once the weight
+// becomes stable / unstable
+func (d *Decent) SetStabilityChangeHandler(fn func(event scale.StabilityEvent)) {
+	d.stability.SetHandler(fn)
+}
+
+// SetStateChangeHandler defines a handler function that is called upon state change
+func (d *Decent) SetStateChangeHandler(fn func(status scale.ConnectionStatus)) {
+	d.broker.SetStateChangeHandler(fn)
+}
+
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the
+// new state change if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (d *Decent) SetStateChangeChannel(ch chan scale.ConnectionStatus) {
+	d.broker.SetStateChangeChannel(ch)
+}
+
+// SetDataHandler defines a handler function that is called upon retrieval of data
+func (d *Decent) SetDataHandler(fn func(data scale.DataPoint)) {
+	d.broker.SetDataHandler(fn)
+}
+
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (d *Decent) SetDataChannel(ch chan scale.DataPoint) {
+	d.broker.SetDataChannel(ch)
+}
+
+// Tare tares the scale
+func (d *Decent) Tare() error {
+	d.mu.Lock()
+	d.tareCounter++
+	tareCounter := d.tareCounter
+	d.mu.Unlock()
+
+	return d.write(encode(cmdTare, tareCounter))
+}
+
+// Buzz requests the scale to beep / buzz n times (not supported by the Decent scale)
+func (d *Decent) Buzz(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of beeps requested: %d", n)
+	}
+
+	return scale.ErrNotSupported
+}
+
+// ToggleBuzzingOnTouch turns the buzzer (on user interaction) on / off (not supported
+// by the Decent scale)
+func (d *Decent) ToggleBuzzingOnTouch() error {
+	return scale.ErrNotSupported
+}
+
+// SetUnit changes the weight unit shown on the display (and hence the unit of the data
+// points) from / to g / oz
+func (d *Decent) SetUnit(unit scale.Unit) error {
+
+	// Check if the unit is already set to the expected value
+	if current := d.Unit(); current != scale.UnitUnknown && current == unit {
+		return nil
+	}
+	if unit != scale.UnitGrams && unit != scale.UnitOz {
+		return fmt.Errorf("invalid unit requested: %s", unit)
+	}
+
+	if err := d.write(encodeLED(true, unit)); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.unit, d.isLEDOn = unit, true
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Precision returns the current weight precision (the Decent scale always operates
+// at a fixed precision of 0.1 g)
+func (d *Decent) Precision() scale.Precision {
+	return scale.PrecisionLow
+}
+
+// SetPrecision sets the weight precision (changing the precision is not supported by
+// the Decent scale)
+func (d *Decent) SetPrecision(p scale.Precision) error {
+	if p == scale.PrecisionLow {
+		return nil
+	}
+
+	return scale.ErrNotSupported
+}
+
+// TogglePrecision toggles the weight precision between 0.1 and 0.01 (not supported
+// by the Decent scale)
+func (d *Decent) TogglePrecision() error {
+	return scale.ErrNotSupported
+}
+
+// IsLEDOn returns if the display / LEDs are currently turned on
+func (d *Decent) IsLEDOn() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.isLEDOn
+}
+
+// SetLED turns the display / LEDs on or off
+func (d *Decent) SetLED(on bool) error {
+	if err := d.write(encodeLED(on, d.Unit())); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.isLEDOn = on
+	d.mu.Unlock()
+
+	return nil
+}
+
+// StartTimer starts the timer / stopwatch
+func (d *Decent) StartTimer() error {
+	if err := d.write(encode(cmdTimer, timerStart)); err != nil {
+		return err
+	}
+
+	d.timer.Start()
+
+	return nil
+}
+
+// StopTimer stops the timer / stopwatch
+func (d *Decent) StopTimer() error {
+	if err := d.write(encode(cmdTimer, timerStop)); err != nil {
+		return err
+	}
+
+	d.timer.Stop()
+
+	return nil
+}
+
+// ResetTimer resets the timer / stopwatch
+func (d *Decent) ResetTimer() error {
+	if err := d.write(encode(cmdTimer, timerReset)); err != nil {
+		return err
+	}
+
+	d.timer.Reset()
+
+	return nil
+}
+
+// ElapsedTime returns the current timer value
+func (d *Decent) ElapsedTime() time.Duration {
+	return d.timer.ElapsedTime()
+}
+
+// Close terminates the connection to the device (subsequent calls are a no-op)
+func (d *Decent) Close() (err error) {
+	d.closeOnce.Do(func() {
+		d.cancel()
+		d.ready.Close()
+		d.broker.Close()
+
+		_ = d.btDevice.StopScanning()
+		err = d.btDevice.RemoveAllServices()
+
+		// Close the device (releasing it if shared via an adapter)
+		if closeErr := d.btDevice.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (d *Decent) subscribe() error {
+
+	// Register handlers (routed to this instance if the device is shared via an adapter)
+	scale.HandlePeripherals(d.btDevice, d.genOnPeriphDiscovered(), d.onPeriphConnected, d.onPeriphDisconnected)
+
+	// Initialize the device
+	return d.btDevice.Init(d.onStateChanged)
+}
+
+func (d *Decent) setStatus(state scale.State, err error) {
+	status := d.reconnector.Status(state, err)
+
+	d.mu.Lock()
+	d.connectionStatus = status
+	d.mu.Unlock()
+
+	// Distribute state change to all consumers
+	d.broker.PublishState(status)
+}
+
+func (d *Decent) write(msg []byte) error {
+	d.mu.RLock()
+	btPeripheral, btWriteCharacteristic := d.btPeripheral, d.btWriteCharacteristic
+	d.mu.RUnlock()
+
+	if btPeripheral == nil || btWriteCharacteristic == nil {
+		return fmt.Errorf("failed to write to uninitialized device")
+	}
+
+	return btPeripheral.WriteCharacteristic(btWriteCharacteristic, msg, false)
+}
+
+// setPeripheral sets (or resets, if nil) the connected peripheral and its characteristics
+func (d *Decent) setPeripheral(p gatt.Peripheral, write, notify *gatt.Characteristic) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.btPeripheral = p
+	d.btWriteCharacteristic = write
+	d.btNotifyCharacteristic = notify
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (d *Decent) onStateChanged(dev gatt.Device, s gatt.State) {
+	switch s {
+	case gatt.StatePoweredOn:
+		d.setStatus(scale.StateScanning, nil)
+		if err := dev.Scan([]gatt.UUID{}, false); err != nil {
+			d.logger.Warnf("failed to enable initial scanning: %s", err)
+		}
+		return
+	case gatt.StatePoweredOff:
+		d.setStatus(scale.StateDisconnected, nil)
+		return
+	default:
+		if err := dev.StopScanning(); err != nil {
+			d.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+	}
+}
+
+func (d *Decent) genOnPeriphDiscovered() func(p gatt.Peripheral, arg2 *gatt.Advertisement, arg3 int) {
+	return func(p gatt.Peripheral, arg2 *gatt.Advertisement, arg3 int) {
+
+		d.logger.Debugf("discovered device `%s/%s`", p.Name(), p.ID())
+
+		if !d.thisDevice(p) {
+			return
+		}
+
+		d.logger.Debugf("connecting device `%s/%s`", p.Name(), p.ID())
+
+		// Stop scanning once we've got the peripheral we're looking for.
+		if err := p.Device().StopScanning(); err != nil {
+			d.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+		if err := p.Device().Connect(p); err != nil {
+			d.logger.Errorf("Failed to connect device `%s/%s`: %s", p.Name(), p.ID(), err)
+		}
+
+		d.logger.Debugf("connected device `%s/%s`", p.Name(), p.ID())
+	}
+}
+
+func (d *Decent) onPeriphConnected(p gatt.Peripheral, connErr error) {
+
+	if !d.thisDevice(p) {
+		return
+	}
+
+	d.logger.Debugf("connected peripheral `%s/%s`", p.Name(), p.ID())
+
+	d.connWG.Add(1)
+	released := d.release.Released(p)
+	d.setStatus(scale.StateConnected, nil)
+	defer func() {
+		d.ready.Set(false)
+		d.setPeripheral(nil, nil, nil)
+		_ = p.Device().CancelConnection(p)
+		d.setStatus(scale.StateDisconnected, connErr)
+		d.release.Done(p)
+		d.connWG.Done()
+	}()
+
+	// Discover services
+	ss, err := p.DiscoverServices(nil)
+	if err != nil {
+		connErr = fmt.Errorf("failed to discover services: %w", err)
+		return
+	}
+	var btWriteCharacteristic, btNotifyCharacteristic *gatt.Characteristic
+	for _, s := range ss {
+		// Read device information (if available)
+		if s.UUID().String() == scale.DeviceInformationService {
+			info, err := scale.ReadDeviceInfo(p, s)
+			if err != nil {
+				d.logger.Warnf("failed to read device information: %s", err)
+				continue
+			}
+			d.mu.Lock()
+			d.deviceInfo = info
+			d.mu.Unlock()
+			continue
+		}
+
+		if s.UUID().String() != dataService {
+			continue
+		}
+
+		// Discover characteristics
+		cs, err := p.DiscoverCharacteristics(nil, s)
+		if err != nil {
+			connErr = fmt.Errorf("failed to discover characteristics: %w", err)
+			return
+		}
+		for _, c := range cs {
+			switch c.UUID().String() {
+			case dataWriteCharacteristic:
+				btWriteCharacteristic = c
+			case dataNotifyCharacteristic:
+				btNotifyCharacteristic = c
+			}
+		}
+	}
+	if btWriteCharacteristic == nil || btNotifyCharacteristic == nil {
+		connErr = fmt.Errorf("failed to find data characteristics")
+		return
+	}
+	d.setPeripheral(p, btWriteCharacteristic, btNotifyCharacteristic)
+
+	// Discover descriptors
+	if _, err := p.DiscoverDescriptors(nil, btNotifyCharacteristic); err != nil {
+		connErr = fmt.Errorf("failed to discover descriptors: %w", err)
+		return
+	}
+
+	if err := p.SetNotifyValue(btNotifyCharacteristic, d.receiveData); err != nil {
+		connErr = fmt.Errorf("failed to subscribe characteristic: %w", err)
+		return
+	}
+
+	// Set the LED state, which also causes the scale to report its battery level
+	if err := d.SetLED(d.ledOnConnect); err != nil {
+		d.logger.Warnf("failed to set LED state upon connection: %s", err)
+	}
+
+	d.ready.Set(true)
+	d.reconnector.Reset()
+
+	// Wait until the peripheral is released upon disconnect (which may have happened at any
+	// point during the connection setup) or once the scale is closed
+	d.logger.Debugf("waiting to release peripheral `%s/%s`", p.Name(), p.ID())
+	select {
+	case <-released:
+	case <-d.ctx.Done():
+	}
+	d.logger.Debugf("released peripheral `%s/%s`", p.Name(), p.ID())
+}
+
+func (d *Decent) onPeriphDisconnected(p gatt.Peripheral, err error) {
+
+	if !d.thisDevice(p) {
+		return
+	}
+
+	d.release.Release(p)
+	d.logger.Debugf("disconnected peripheral `%s/%s`", p.Name(), p.ID())
+
+	// Once the connection has been released, attempt to reconnect according to the
+	// reconnect policy (reporting the cause of the disconnect, if any)
+	go func() {
+		d.connWG.Wait()
+		if err == nil {
+			err = d.ConnectionStatus().Error
+		}
+		d.reconnector.Run(d.ctx, d.btDevice, &d.ready, err, d.setStatus)
+	}()
+}
+
+func (d *Decent) thisDevice(p gatt.Peripheral) bool {
+
+	// If a device ID has been set, only the peripheral with this ID is accepted (several
+	// scales may share the same name), otherwise match by name
+	if d.deviceID != "" {
+		return strings.EqualFold(p.ID(), d.deviceID)
+	}
+	return strings.EqualFold(p.Name(), d.deviceName)
+}
+
+func (d *Decent) receiveData(_ *gatt.Characteristic, req []byte, err error) {
+
+	if err != nil || !isValid(req) {
+		return
+	}
+
+	switch req[1] {
+	case typeWeightStable, typeWeightChanging:
+	case cmdLED:
+
+		// Response to the LED command, containing the battery level
+		d.mu.Lock()
+		if req[4] == batteryLevelUSBPowered {
+			d.isUSBPowered = true
+		} else {
+			d.isUSBPowered = false
+			d.batteryLevel = req[4]
+		}
+		d.mu.Unlock()
+		return
+	case typeButton:
+		d.logger.Debugf("button %d pressed (%#x)", req[2], req[3])
+		return
+	default:
+		return
+	}
+
+	d.mu.Lock()
+	d.isStable = req[1] == typeWeightStable
+	unit := d.unit
+	d.mu.Unlock()
+
+	dataPoint := scale.DataPoint{
+		TimeStamp: time.Now(),
+		Weight:    parseWeight(req),
+		Unit:      scale.UnitGrams,
+		Stable:    req[1] == typeWeightStable,
+	}
+
+	// Convert data point to the unit shown on the display (the scale always reports the
+	// weight in grams)
+	if converted, err := dataPoint.In(unit); err == nil {
+		dataPoint = converted
+	}
+
+	// Convert data point to the canonical unit (if requested), dropping it if its unit
+	// is unknown
+	if d.canonicalUnit != "" {
+		converted, err := dataPoint.In(d.canonicalUnit)
+		if err != nil {
+			d.logger.Debugf("dropping data point: %s", err)
+			return
+		}
+		dataPoint = converted
+	}
+
+	// Filter data point (if filters are installed), dropping it if discarded by any filter
+	filtered, ok := d.filter.Filter(dataPoint)
+	if !ok {
+		return
+	}
+	dataPoint = filtered
+
+	// Report the stability of the weight (as determined by the scale itself)
+	dataPoint = d.stability.Report(dataPoint)
+
+	// Distribute data point to all consumers
+	d.broker.PublishData(dataPoint)
+}
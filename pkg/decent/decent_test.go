@@ -0,0 +1,48 @@
+package decent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+func TestReceiveData(t *testing.T) {
+	for _, unit := range []scale.Unit{scale.UnitGrams, scale.UnitOz} {
+		d := &Decent{unit: unit, logger: &scale.NullLogger{}}
+		sub := d.Subscribe()
+
+		// The weight is always reported in grams, but has to be published in the unit
+		// shown on the display
+		d.receiveData(nil, []byte{0x03, 0xce, 0x01, 0xf4, 0x00, 0x00, 0x38}, nil)
+		data := <-sub.Data()
+		expected := 50.
+		if unit == scale.UnitOz {
+			expected = 50. / 28.349523125
+		}
+		if data.Unit != unit || math.Abs(data.Weight-expected) > 1e-9 || !data.Stable {
+			t.Fatalf("unexpected data point for unit %s: %+v", unit, data)
+		}
+		sub.Unsubscribe()
+	}
+}
+
+func TestReceiveBatteryLevel(t *testing.T) {
+	d := &Decent{logger: &scale.NullLogger{}}
+
+	d.receiveData(nil, []byte{0x03, 0x0a, 0x01, 0x00, 0x55, 0x00, 0x5d}, nil)
+	if level := d.BatteryLevel(); level != 0.85 || d.IsUSBPowered() {
+		t.Fatalf("unexpected battery level: %v", level)
+	}
+
+	// Frames with an invalid checksum are discarded
+	d.receiveData(nil, []byte{0x03, 0x0a, 0x01, 0x00, 0xff, 0x00, 0x5d}, nil)
+	if d.IsUSBPowered() {
+		t.Fatalf("invalid frame was not discarded")
+	}
+
+	d.receiveData(nil, []byte{0x03, 0x0a, 0x01, 0x00, 0xff, 0x00, 0xf7}, nil)
+	if level := d.BatteryLevel(); level != 1. || !d.IsUSBPowered() {
+		t.Fatalf("unexpected battery level while powered via USB: %v", level)
+	}
+}
@@ -0,0 +1,88 @@
+package decent
+
+import (
+	"context"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+// WithDeviceID sets the Bluetooth device ID (if set, only the peripheral with this ID is
+// connected to, regardless of its name)
+func WithDeviceID(deviceID string) func(*Decent) {
+	return func(d *Decent) {
+		d.deviceID = deviceID
+	}
+}
+
+// WithDeviceName sets the Bluetooth device name
+func WithDeviceName(deviceName string) func(*Decent) {
+	return func(d *Decent) {
+		d.deviceName = deviceName
+	}
+}
+
+// WithDevice sets the Bluetooth device
+func WithDevice(btDevice gatt.Device) func(*Decent) {
+	return func(d *Decent) {
+		d.btDevice = btDevice
+	}
+}
+
+// WithLogger sets a logger
+func WithLogger(logger scale.Logger) func(*Decent) {
+	return func(d *Decent) {
+		d.logger = logger
+	}
+}
+
+// WithLEDOnConnect defines if the display / LEDs are turned on (or off) upon connection
+func WithLEDOnConnect(on bool) func(*Decent) {
+	return func(d *Decent) {
+		d.ledOnConnect = on
+	}
+}
+
+// WithContext sets a context, terminating the connection to the device (including any
+// ongoing scan) once it ends
+func WithContext(ctx context.Context) func(*Decent) {
+	return func(d *Decent) {
+		d.ctx = ctx
+	}
+}
+
+// WithReconnectPolicy sets the strategy to reconnect to the scale after the connection
+// was lost (see scale.ReconnectPolicy for the default behavior)
+func WithReconnectPolicy(policy scale.ReconnectPolicy) func(*Decent) {
+	return func(d *Decent) {
+		d.reconnector.Policy = policy
+	}
+}
+
+// WithDeliveryPolicy sets the behavior when putting data points / state changes on a
+// channel whose consumer does not keep up. It applies to the channels set via
+// SetDataChannel() / SetStateChangeChannel() and serves as default for subscriptions (by
+// default, delivery never blocks, see scale.DeliveryDefault)
+func WithDeliveryPolicy(data, state scale.DeliveryPolicy) func(*Decent) {
+	return func(d *Decent) {
+		d.broker.DataPolicy = data
+		d.broker.StatePolicy = state
+	}
+}
+
+// WithCanonicalUnit ensures that all data points are delivered in a certain unit, regardless
+// of the unit selected on the scale (data points in an unknown unit are dropped)
+func WithCanonicalUnit(unit scale.Unit) func(*Decent) {
+	return func(d *Decent) {
+		d.canonicalUnit = unit
+	}
+}
+
+// WithFilter installs a filter pipeline for the weight readings, i.e. all data points
+// are passed through the given filters in order before being delivered (the unfiltered
+// weight being retained in their RawWeight field)
+func WithFilter(filters ...scale.Filter) func(*Decent) {
+	return func(d *Decent) {
+		d.filter = append(d.filter, filters...)
+	}
+}
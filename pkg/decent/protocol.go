@@ -0,0 +1,81 @@
+package decent
+
+import (
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+const (
+	msgLen    = 7
+	msgHeader = 0x03
+
+	cmdLED   = 0x0a
+	cmdTimer = 0x0b
+	cmdTare  = 0x0f
+
+	typeWeightStable   = 0xce
+	typeWeightChanging = 0xca
+	typeButton         = 0xaa
+
+	timerStop  = 0x00
+	timerReset = 0x02
+	timerStart = 0x03
+
+	ledOff = 0x00
+	ledOn  = 0x01
+
+	displayGrams = 0x00
+	displayOz    = 0x01
+
+	batteryLevelUSBPowered = 0xff
+)
+
+// encode frames a command and its arguments, appending the XOR checksum
+func encode(cmd byte, args ...byte) []byte {
+	msg := make([]byte, msgLen)
+	msg[0] = msgHeader
+	msg[1] = cmd
+	copy(msg[2:msgLen-1], args)
+	msg[msgLen-1] = checksum(msg[:msgLen-1])
+
+	return msg
+}
+
+// encodeLED frames a command to turn the display / LEDs on or off, using the
+// provided display unit
+func encodeLED(on bool, unit scale.Unit) []byte {
+	state, display := byte(ledOff), byte(displayGrams)
+	if on {
+		state = ledOn
+	}
+	if unit == scale.UnitOz {
+		display = displayOz
+	}
+
+	return encode(cmdLED, state, display)
+}
+
+// checksum computes the XOR checksum of a message
+func checksum(data []byte) (cksum byte) {
+	for _, b := range data {
+		cksum ^= b
+	}
+	return
+}
+
+// isValid checks if a message has the expected length, header and checksum
+func isValid(msg []byte) bool {
+	return len(msg) == msgLen && msg[0] == msgHeader && checksum(msg[:msgLen-1]) == msg[msgLen-1]
+}
+
+// parseWeight decodes the weight (in grams) of a weight message
+func parseWeight(msg []byte) float64 {
+	return float64(int16(uint16(msg[2])<<8|uint16(msg[3]))) / 10.
+}
+
+func parseBatteryLevel(data byte) float64 {
+	if data > 100 {
+		return 1.
+	}
+
+	return float64(data) / 100.
+}
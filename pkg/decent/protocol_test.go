@@ -0,0 +1,75 @@
+package decent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+func TestEncode(t *testing.T) {
+	for _, cs := range []struct {
+		msg      []byte
+		expected []byte
+	}{
+		{encode(cmdTare, 0x01), []byte{0x03, 0x0f, 0x01, 0x00, 0x00, 0x00, 0x0d}},
+		{encodeLED(true, scale.UnitGrams), []byte{0x03, 0x0a, 0x01, 0x00, 0x00, 0x00, 0x08}},
+		{encodeLED(false, scale.UnitOz), []byte{0x03, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x08}},
+	} {
+		if !bytes.Equal(cs.msg, cs.expected) {
+			t.Fatalf("unexpected message, want %x, have %x", cs.expected, cs.msg)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	for _, cs := range []struct {
+		data     []byte
+		expected byte
+	}{
+		{nil, 0x00},
+		{[]byte{0x03, 0xce, 0x00, 0x00, 0x00, 0x00}, 0xcd},
+		{[]byte{0x03, 0xca, 0x01, 0xf4, 0x00, 0x00}, 0x3c},
+		{[]byte{0x03, 0x0a, 0x01, 0x00, 0x55, 0x00}, 0x5d},
+	} {
+		if cksum := checksum(cs.data); cksum != cs.expected {
+			t.Fatalf("unexpected checksum for %x, want %#x, have %#x", cs.data, cs.expected, cksum)
+		}
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	for _, cs := range []struct {
+		msg      []byte
+		expected bool
+	}{
+		{[]byte{0x03, 0xce, 0x00, 0x00, 0x00, 0x00, 0xcd}, true},
+		{[]byte{0x03, 0xca, 0x01, 0xf4, 0x00, 0x00, 0x3c}, true},
+		{[]byte{0x03, 0x0a, 0x01, 0x00, 0x55, 0x00, 0x5d}, true},
+		{[]byte{0x03, 0xca, 0x01, 0xf4, 0x00, 0x00, 0x3d}, false},
+		{[]byte{0x04, 0xce, 0x00, 0x00, 0x00, 0x00, 0xca}, false},
+		{[]byte{0x03, 0xce, 0x00, 0x00, 0x00, 0xcd}, false},
+		{[]byte{0x03, 0xce, 0x00, 0x00, 0x00, 0x00, 0xcd, 0x00}, false},
+		{nil, false},
+	} {
+		if res := isValid(cs.msg); res != cs.expected {
+			t.Fatalf("unexpected validity of %x, want %v, have %v", cs.msg, cs.expected, res)
+		}
+	}
+}
+
+func TestParseWeight(t *testing.T) {
+	for _, cs := range []struct {
+		msg      []byte
+		expected float64
+	}{
+		{[]byte{0x03, 0xce, 0x00, 0x00, 0x00, 0x00, 0xcd}, 0.},
+		{[]byte{0x03, 0xca, 0x01, 0xf4, 0x00, 0x00, 0x3c}, 50.},
+		{[]byte{0x03, 0xce, 0xff, 0x9c, 0x00, 0x00, 0xae}, -10.},
+		{[]byte{0x03, 0xca, 0x7f, 0xff, 0x00, 0x00, 0x49}, 3276.7},
+	} {
+		if weight := parseWeight(cs.msg); weight != cs.expected {
+			t.Fatalf("unexpected weight for %x, want %v, have %v", cs.msg, cs.expected, weight)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Package drivers registers all available scale drivers for automatic discovery via
+// scale.Open(), simply import it for its side effects:
+//
+//	import _ "github.com/fako1024/btscale/pkg/drivers"
+package drivers
+
+import (
+
+	// Vendor specific drivers are registered first, the generic Weight Scale Service
+	// driver serves as fallback
+	_ "github.com/fako1024/btscale/pkg/acaia"
+	_ "github.com/fako1024/btscale/pkg/decent"
+	_ "github.com/fako1024/btscale/pkg/felicita"
+	_ "github.com/fako1024/btscale/pkg/weightscale"
+)
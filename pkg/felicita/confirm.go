@@ -0,0 +1,163 @@
+package felicita
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/fako1024/btscale/pkg/felicita/protocol"
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+// confirmation denotes a pending command, waiting for its effect to be reflected by
+// the data received from the scale
+type confirmation struct {
+	isConfirmed func(frame protocol.Frame) bool
+	done        chan struct{}
+}
+
+// execute writes a command to the scale and waits for its confirmation (if a timeout
+// for confirmations has been set via WithConfirmationTimeout())
+func (f *Felicita) execute(cmd protocol.Command, isConfirmed func(frame protocol.Frame) bool) error {
+	if f.confirmationTimeout <= 0 {
+		return f.write(cmd)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.confirmationTimeout)
+	defer cancel()
+
+	return f.executeContext(ctx, cmd, isConfirmed)
+}
+
+// executeContext writes a command to the scale and waits until a subsequent frame
+// confirms it. If the context ends beforehand, a *scale.TimeoutError is returned
+func (f *Felicita) executeContext(ctx context.Context, cmd protocol.Command, isConfirmed func(frame protocol.Frame) bool) error {
+
+	// If the effect of the command cannot be determined, just write it
+	if isConfirmed == nil {
+		return f.write(cmd)
+	}
+
+	// Register the confirmation prior to writing the command to ensure that no
+	// relevant frame is missed
+	c := &confirmation{
+		isConfirmed: isConfirmed,
+		done:        make(chan struct{}),
+	}
+	f.mu.Lock()
+	f.confirmations = append(f.confirmations, c)
+	f.mu.Unlock()
+
+	if err := f.write(cmd); err != nil {
+		f.dropConfirmation(c)
+		return err
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-f.ctx.Done():
+		f.dropConfirmation(c)
+		return scale.ErrClosed
+	case <-ctx.Done():
+		f.dropConfirmation(c)
+		return &scale.TimeoutError{
+			Command: cmd.String(),
+			Err:     ctx.Err(),
+		}
+	}
+}
+
+// confirm releases all pending confirmations satisfied by a frame (requires the lock
+// to be held)
+func (f *Felicita) confirm(frame protocol.Frame) {
+	pending := f.confirmations[:0]
+	for _, c := range f.confirmations {
+		if c.isConfirmed(frame) {
+			close(c.done)
+			continue
+		}
+		pending = append(pending, c)
+	}
+	f.confirmations = pending
+}
+
+func (f *Felicita) dropConfirmation(c *confirmation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.confirmations {
+		if f.confirmations[i] == c {
+			f.confirmations = append(f.confirmations[:i], f.confirmations[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasToggledPrecision generates a confirmation for a precision toggle based on the
+// current precision (or none if the current precision is unknown)
+func hasToggledPrecision(current scale.Precision) func(frame protocol.Frame) bool {
+	switch current {
+	case scale.PrecisionLow:
+		return hasPrecision(scale.PrecisionHigh)
+	case scale.PrecisionHigh:
+		return hasPrecision(scale.PrecisionLow)
+	}
+
+	return nil
+}
+
+func isTared(frame protocol.Frame) bool {
+	data, err := scale.DataPoint{Weight: frame.Weight, Unit: frame.Unit}.In(scale.UnitGrams)
+	if err != nil {
+		return false
+	}
+
+	return math.Abs(data.Weight) <= tareTolerance
+}
+
+func isBuzzingOnTouch(state bool) func(frame protocol.Frame) bool {
+	return func(frame protocol.Frame) bool {
+		return frame.IsBuzzingOnTouch == state
+	}
+}
+
+func hasUnit(unit scale.Unit) func(frame protocol.Frame) bool {
+	return func(frame protocol.Frame) bool {
+		return frame.Unit == unit
+	}
+}
+
+// hasPrecision generates a confirmation for a precision, following the same logic as
+// updatePrecision(): High precision is proven by a single frame with non-zero hundredths
+// digit, low precision by a sequence of non-zero weight frames without
+func hasPrecision(p scale.Precision) func(frame protocol.Frame) bool {
+	lowPrecisionFrames := 0
+	return func(frame protocol.Frame) bool {
+		if frame.Precision() == scale.PrecisionHigh {
+			lowPrecisionFrames = 0
+			return p == scale.PrecisionHigh
+		}
+		if frame.Weight != 0 {
+			lowPrecisionFrames++
+		}
+
+		return p == scale.PrecisionLow && lowPrecisionFrames >= minLowPrecisionFrames
+	}
+}
+
+func validateUnit(unit scale.Unit) error {
+	if unit != scale.UnitGrams && unit != scale.UnitOz {
+		return fmt.Errorf("invalid unit requested: %s", unit)
+	}
+
+	return nil
+}
+
+func validatePrecision(p scale.Precision) error {
+	if p != scale.PrecisionLow && p != scale.PrecisionHigh {
+		return fmt.Errorf("invalid precision requested: %v", p)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package felicita
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/felicita/protocol"
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+// fakeDevice denotes a no-op bluetooth device that can be injected via WithDevice()
+type fakeDevice struct{}
+
+func (d *fakeDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	stateChanged(d, gatt.StatePoweredOn)
+	return nil
+}
+func (d *fakeDevice) Advertise(a *gatt.AdvPacket) error                          { return nil }
+func (d *fakeDevice) AdvertiseNameAndServices(name string, ss []gatt.UUID) error { return nil }
+func (d *fakeDevice) AdvertiseIBeaconData(b []byte) error                        { return nil }
+func (d *fakeDevice) AdvertiseIBeacon(u gatt.UUID, major, minor uint16, pwr int8) error {
+	return nil
+}
+func (d *fakeDevice) StopAdvertising() error                   { return nil }
+func (d *fakeDevice) RemoveAllServices() error                 { return nil }
+func (d *fakeDevice) AddService(s *gatt.Service) error         { return nil }
+func (d *fakeDevice) SetServices(ss []*gatt.Service) error     { return nil }
+func (d *fakeDevice) Scan(ss []gatt.UUID, dup bool) error      { return nil }
+func (d *fakeDevice) StopScanning() error                      { return nil }
+func (d *fakeDevice) Connect(p gatt.Peripheral) error          { return nil }
+func (d *fakeDevice) CancelConnection(p gatt.Peripheral) error { return nil }
+func (d *fakeDevice) Handle(h ...gatt.Handler)                 {}
+func (d *fakeDevice) Close() error                             { return nil }
+func (d *fakeDevice) Option(o ...gatt.Option) error            { return nil }
+
+// fakePeripheral simulates a Felicita scale, applying commands to its internal state
+// and emitting notification frames
+type fakePeripheral struct {
+	device         *fakeDevice
+	service        *gatt.Service
+	characteristic *gatt.Characteristic
+
+	infoService         *gatt.Service
+	infoCharacteristics []*gatt.Characteristic
+	infoValues          map[string][]byte
+
+	frame         protocol.Frame
+	highPrecision bool
+	ignoreWrites  bool
+	rssi          int
+	onDiscover    func()
+	notifyFn      func(*gatt.Characteristic, []byte, error)
+	writes        []protocol.Command
+
+	mu sync.Mutex
+}
+
+func newFakePeripheral() *fakePeripheral {
+	service := gatt.NewService(gatt.MustParseUUID(dataService))
+	infoService := gatt.NewService(gatt.MustParseUUID(scale.DeviceInformationService))
+
+	// Device information as reported by an actual device (see ble.txt)
+	infoValues := map[string][]byte{
+		"2a24": []byte("Model Number\x00"),
+		"2a26": []byte("Firmware Revision\x00"),
+		"2a29": []byte("Manufacturer Name\x00"),
+	}
+	var infoCharacteristics []*gatt.Characteristic
+	for uuid := range infoValues {
+		infoCharacteristics = append(infoCharacteristics, gatt.NewCharacteristic(gatt.MustParseUUID(uuid), infoService, gatt.CharRead, 0, 0))
+	}
+
+	return &fakePeripheral{
+		device:              &fakeDevice{},
+		service:             service,
+		characteristic:      gatt.NewCharacteristic(gatt.MustParseUUID(dataCharacteristic), service, gatt.CharNotify|gatt.CharWrite, 0, 0),
+		infoService:         infoService,
+		infoCharacteristics: infoCharacteristics,
+		infoValues:          infoValues,
+		frame: protocol.Frame{
+			Weight:          10.,
+			Unit:            scale.UnitGrams,
+			BatteryLevelRaw: 150,
+		},
+		rssi: -60,
+	}
+}
+
+func (p *fakePeripheral) Device() gatt.Device { return p.device }
+func (p *fakePeripheral) ID() string          { return "C8:FD:19:8E:3E:3C" }
+func (p *fakePeripheral) Name() string        { return defaultDeviceName }
+func (p *fakePeripheral) Services() []*gatt.Service {
+	return []*gatt.Service{p.infoService, p.service}
+}
+func (p *fakePeripheral) DiscoverServices(s []gatt.UUID) ([]*gatt.Service, error) {
+	p.mu.Lock()
+	onDiscover := p.onDiscover
+	p.mu.Unlock()
+
+	if onDiscover != nil {
+		onDiscover()
+	}
+	return p.Services(), nil
+}
+func (p *fakePeripheral) DiscoverIncludedServices(ss []gatt.UUID, s *gatt.Service) ([]*gatt.Service, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) DiscoverCharacteristics(c []gatt.UUID, s *gatt.Service) ([]*gatt.Characteristic, error) {
+	if s == p.infoService {
+		return p.infoCharacteristics, nil
+	}
+	return []*gatt.Characteristic{p.characteristic}, nil
+}
+func (p *fakePeripheral) DiscoverDescriptors(d []gatt.UUID, c *gatt.Characteristic) ([]*gatt.Descriptor, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) ReadCharacteristic(c *gatt.Characteristic) ([]byte, error) {
+	return p.infoValues[c.UUID().String()], nil
+}
+func (p *fakePeripheral) ReadLongCharacteristic(c *gatt.Characteristic) ([]byte, error) {
+	return nil, nil
+}
+func (p *fakePeripheral) ReadDescriptor(d *gatt.Descriptor) ([]byte, error)  { return nil, nil }
+func (p *fakePeripheral) WriteDescriptor(d *gatt.Descriptor, b []byte) error { return nil }
+func (p *fakePeripheral) SetIndicateValue(c *gatt.Characteristic, f func(*gatt.Characteristic, []byte, error)) error {
+	return nil
+}
+func (p *fakePeripheral) SetMTU(mtu uint16) error { return nil }
+
+func (p *fakePeripheral) ReadRSSI() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.rssi
+}
+
+// notifyRaw emits a single raw notification (e.g. an invalid frame or an error)
+func (p *fakePeripheral) notifyRaw(b []byte, err error) {
+	p.mu.Lock()
+	notifyFn := p.notifyFn
+	p.mu.Unlock()
+
+	if notifyFn != nil {
+		notifyFn(p.characteristic, b, err)
+	}
+}
+
+func (p *fakePeripheral) SetNotifyValue(c *gatt.Characteristic, f func(*gatt.Characteristic, []byte, error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notifyFn = f
+	return nil
+}
+
+func (p *fakePeripheral) WriteCharacteristic(c *gatt.Characteristic, b []byte, noRsp bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ignoreWrites {
+		return nil
+	}
+
+	for _, cmd := range b {
+		p.writes = append(p.writes, protocol.Command(cmd))
+		switch protocol.Command(cmd) {
+		case protocol.CmdToggleBuzzer:
+			p.frame.IsBuzzingOnTouch = !p.frame.IsBuzzingOnTouch
+		case protocol.CmdToggleUnit:
+			if p.frame.Unit == scale.UnitGrams {
+				p.frame.Unit = scale.UnitOz
+			} else {
+				p.frame.Unit = scale.UnitGrams
+			}
+		case protocol.CmdTogglePrecision:
+			p.highPrecision = !p.highPrecision
+		case protocol.CmdTare:
+			p.frame.Weight = 0
+		}
+	}
+
+	return nil
+}
+
+// notify emits a single notification frame reflecting the current state
+func (p *fakePeripheral) notify() {
+	p.mu.Lock()
+	frame, notifyFn := p.frame, p.notifyFn
+	if p.highPrecision {
+		frame.Weight += 0.01
+	}
+	p.mu.Unlock()
+
+	if notifyFn != nil {
+		notifyFn(p.characteristic, frame.Encode(), nil)
+	}
+}
+
+// run continuously emits notification frames until the done channel is closed
+func (p *fakePeripheral) run(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.notify()
+		}
+	}
+}
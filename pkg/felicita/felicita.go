@@ -1,15 +1,15 @@
 package felicita
 
 import (
+	"context"
 	"fmt"
-	"math"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/fako1024/btscale/pkg/felicita/protocol"
 	"github.com/fako1024/btscale/pkg/scale"
 	"github.com/fako1024/gatt"
-	"github.com/fatih/stopwatch"
 )
 
 const (
@@ -17,20 +17,19 @@ const (
 	dataService        = "ffe0"
 	dataCharacteristic = "ffe1"
 
-	minBatteryLevel = 129.
-	maxBatteryLevel = 158.
+	// Timeout for the confirmation of buzzer state changes during Buzz()
+	buzzerConfirmationTimeout = 5 * time.Second
 
-	cmdStartTimer = 0x52
-	cmdStopTimer  = 0x53
-	cmdResetTimer = 0x43
+	// Maximum absolute weight (in grams, converted to the current unit) regarded as zero
+	// after taring
+	tareTolerance = 0.5
 
-	cmdToggleBuzzer    = 0x42
-	cmdTogglePrecision = 0x44
-	cmdTare            = 0x54
-	cmdToggleUnit      = 0x55
+	// Number of consecutive (non-zero weight) frames without hundredths digit after
+	// which the scale is assumed to operate at low precision
+	minLowPrecisionFrames = 20
 
-	btSettleDelay   = 50 * time.Millisecond
-	btSettleRetries = 100
+	// Interval in which the signal strength of the connected peripheral is read
+	defaultRSSIInterval = 5 * time.Second
 )
 
 // Felicita denotes a Felicita bluetooth scale
@@ -39,26 +38,43 @@ type Felicita struct {
 	batteryLevel     byte
 	isBuzzingOnTouch bool
 	unit             scale.Unit
+	canonicalUnit    scale.Unit
+	filter           scale.Pipeline
 
-	timer *stopwatch.Stopwatch
+	precision          scale.Precision
+	lowPrecisionFrames int
+
+	deviceInfo scale.DeviceInfo
+
+	timer scale.Stopwatch
 
 	deviceID                    string
 	deviceName                  string
 	forceBuzzerSettingOnConnect BuzzerSetting
+	confirmationTimeout         time.Duration
 	hasReceivedData             bool
-
-	stateChangeHandler func(status scale.ConnectionStatus)
-	stateChangeChan    chan scale.ConnectionStatus
-
-	dataHandler func(data scale.DataPoint)
-	dataChan    chan scale.DataPoint
-	doneChan    chan struct{}
+	confirmations               []*confirmation
+	rssiInterval                time.Duration
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	ready       scale.ReadySignal
+	release     scale.ReleaseSignal
+	reconnector scale.Reconnector
+	broker      scale.Broker
+	stability   scale.StabilityDetector
+	connWG      sync.WaitGroup
+	link        scale.LinkMonitor
 
 	btDevice         gatt.Device
 	btPeripheral     gatt.Peripheral
 	btCharacteristic *gatt.Characteristic
 
 	logger scale.Logger
+
+	mu     sync.RWMutex
+	buzzMu sync.Mutex
 }
 
 // New instantiates a new Felicita struct, executing functional options, if any
@@ -66,15 +82,20 @@ func New(options ...func(*Felicita)) (*Felicita, error) {
 
 	// Initialize a new instance of a Felicita scale
 	f := &Felicita{
-		deviceName: defaultDeviceName,
-		doneChan:   make(chan struct{}),
-		logger:     &scale.NullLogger{},
+		deviceName:   defaultDeviceName,
+		rssiInterval: defaultRSSIInterval,
+		ctx:          context.Background(),
+		logger:       &scale.NullLogger{},
 	}
 
 	// Execute functional options (if any), see options.go for implementation
 	for _, option := range options {
 		option(f)
 	}
+	if f.canonicalUnit != "" && !f.canonicalUnit.IsValid() {
+		return nil, fmt.Errorf("invalid canonical unit: %s", f.canonicalUnit)
+	}
+	f.link.Logger = f.logger
 
 	// Initialize a new GATT device (if not provided as option)
 	if f.btDevice == nil {
@@ -85,57 +106,160 @@ func New(options ...func(*Felicita)) (*Felicita, error) {
 		f.btDevice = btDevice
 	}
 
-	return f, f.subscribe()
+	// Terminate the connection to the device once the context ends
+	f.ctx, f.cancel = context.WithCancel(f.ctx)
+	go func() {
+		<-f.ctx.Done()
+		if err := f.Close(); err != nil {
+			f.logger.Warnf("failed to close device upon termination of context: %s", err)
+		}
+	}()
+
+	// Release the device (terminating the context) if it cannot be initialized
+	if err := f.subscribe(); err != nil {
+		if closeErr := f.Close(); closeErr != nil {
+			f.logger.Warnf("failed to close device after failed initialization: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	return f, nil
 }
 
 // ConnectionStatus returns the current status of the bluetooth device
 func (f *Felicita) ConnectionStatus() scale.ConnectionStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.connectionStatus
 }
 
+// WaitConnected blocks until the scale is connected and usable, returning an error if the
+// context ends or the scale is closed before
+func (f *Felicita) WaitConnected(ctx context.Context) error {
+	return f.ready.Wait(ctx)
+}
+
+// DeviceInfo returns the information provided by the Device Information service of the
+// scale (populated upon connection)
+func (f *Felicita) DeviceInfo() scale.DeviceInfo {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.deviceInfo
+}
+
+// DeliveryStats returns the number of data points / state changes dropped so far
+// (according to the delivery policy)
+func (f *Felicita) DeliveryStats() scale.DeliveryStats {
+	return f.broker.Stats()
+}
+
+// IsRSSISupported returns if the signal strength of the scale can be obtained while
+// connected (which is never the case on Linux, since the bluetooth library does not
+// implement reading it)
+func (f *Felicita) IsRSSISupported() bool {
+	return f.link.IsRSSISupported()
+}
+
+// RSSI returns the most recent signal strength of the scale (in dBm, 0 if unknown or
+// unsupported)
+func (f *Felicita) RSSI() int {
+	return f.link.RSSI()
+}
+
+// RSSIHistory returns the recent signal strength samples of the scale (oldest first, empty
+// if unsupported)
+func (f *Felicita) RSSIHistory() []scale.RSSISample {
+	return f.link.RSSIHistory()
+}
+
+// FrameStats returns statistics on the data frames received from the scale
+func (f *Felicita) FrameStats() scale.FrameStats {
+	return f.link.FrameStats()
+}
+
 // IsBuzzingOnTouch returns if the scale buzzer is turned on or not (on user interaction)
 func (f *Felicita) IsBuzzingOnTouch() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.isBuzzingOnTouch
 }
 
 // BatteryLevel returns the current battery level
 func (f *Felicita) BatteryLevel() float64 {
-	return parseBatteryLevel(f.batteryLevel)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return protocol.ParseBatteryLevel(f.batteryLevel)
 }
 
 // BatteryLevelRaw returns the current battery level in its raw form
 func (f *Felicita) BatteryLevelRaw() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return int(f.batteryLevel)
 }
 
 // Unit returns the current weight unit
 func (f *Felicita) Unit() scale.Unit {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.unit
 }
 
+// Subscribe registers a new subscription to the data points and state changes of the
+// scale, executing functional options, if any
+func (f *Felicita) Subscribe(options ...func(*scale.Subscription)) *scale.Subscription {
+	return f.broker.Subscribe(options...)
+}
+
+// IsStable returns if the weight is currently stable
+func (f *Felicita) IsStable() bool {
+	return f.stability.IsStable()
+}
+
+// SetStabilityChangeHandler defines a handler function that is called once the weight
+// becomes stable / unstable
+func (f *Felicita) SetStabilityChangeHandler(fn func(event scale.StabilityEvent)) {
+	f.stability.SetHandler(fn)
+}
+
 // SetStateChangeHandler defines a handler function that is called upon state change
 func (f *Felicita) SetStateChangeHandler(fn func(status scale.ConnectionStatus)) {
-	f.stateChangeHandler = fn
+	f.broker.SetStateChangeHandler(fn)
 }
 
-// SetStateChangeChannel defines a handler function that is called upon state change
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the
+// new state change if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
 func (f *Felicita) SetStateChangeChannel(ch chan scale.ConnectionStatus) {
-	f.stateChangeChan = ch
+	f.broker.SetStateChangeChannel(ch)
 }
 
 // SetDataHandler defines a handler function that is called upon retrieval of data
 func (f *Felicita) SetDataHandler(fn func(data scale.DataPoint)) {
-	f.dataHandler = fn
+	f.broker.SetDataHandler(fn)
 }
 
-// SetDataChannel defines a handler function that is called upon retrieval of data
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
 func (f *Felicita) SetDataChannel(ch chan scale.DataPoint) {
-	f.dataChan = ch
+	f.broker.SetDataChannel(ch)
 }
 
 // Tare tares the scale
 func (f *Felicita) Tare() error {
-	return f.write(cmdTare)
+	return f.execute(protocol.CmdTare, isTared)
+}
+
+// TareContext tares the scale and waits until the scale reports a (near) zero weight
+func (f *Felicita) TareContext(ctx context.Context) error {
+	return f.executeContext(ctx, protocol.CmdTare, isTared)
 }
 
 // Buzz requests the scale to beep / buzz n times
@@ -145,24 +269,21 @@ func (f *Felicita) Buzz(n int) (err error) {
 		return fmt.Errorf("invalid number of beeps requested: %d", n)
 	}
 
+	// Ensure that concurrent buzz requests do not interfere with each other
+	f.buzzMu.Lock()
+	defer f.buzzMu.Unlock()
+
 	// If the buzzer is currently turned on, shortly turn it off and ensure it is
 	// re-enabled at the end of the function. In this case, n is reduced by one since
 	// enabling the buzzer will cause yet another buzz at the end
 	if f.IsBuzzingOnTouch() {
-		if err = f.ToggleBuzzingOnTouch(); err != nil {
+		if err = f.toggleBuzzerConfirmed(); err != nil {
 			return
 		}
 		n--
-		if err = f.waitForBuzzer(false); err != nil {
-			return
-		}
 
 		defer func() {
-			if derr := f.ToggleBuzzingOnTouch(); derr != nil {
-				err = derr
-				return
-			}
-			if derr := f.waitForBuzzer(true); derr != nil {
+			if derr := f.toggleBuzzerConfirmed(); derr != nil {
 				err = derr
 				return
 			}
@@ -182,125 +303,192 @@ func (f *Felicita) Buzz(n int) (err error) {
 
 // ToggleBuzzingOnTouch turns the buzzer (on user interaction) on / off
 func (f *Felicita) ToggleBuzzingOnTouch() error {
-	return f.write(cmdToggleBuzzer)
+	return f.execute(protocol.CmdToggleBuzzer, isBuzzingOnTouch(!f.IsBuzzingOnTouch()))
+}
+
+// ToggleBuzzingOnTouchContext turns the buzzer (on user interaction) on / off and waits
+// until the scale reports the new setting
+func (f *Felicita) ToggleBuzzingOnTouchContext(ctx context.Context) error {
+	return f.executeContext(ctx, protocol.CmdToggleBuzzer, isBuzzingOnTouch(!f.IsBuzzingOnTouch()))
 }
 
 // SetUnit changes the weight unit from / to g / oz
 func (f *Felicita) SetUnit(unit scale.Unit) error {
+	if err := validateUnit(unit); err != nil {
+		return err
+	}
 
 	// Check if the unit is already set to the expected value
-	if f.unit != scale.UnitUnknown && f.unit == unit {
+	if current := f.Unit(); current != scale.UnitUnknown && current == unit {
 		return nil
 	}
 
 	// Toggle unit, if not
-	return f.write(cmdToggleUnit)
+	return f.execute(protocol.CmdToggleUnit, hasUnit(unit))
+}
+
+// SetUnitContext changes the weight unit from / to g / oz and waits until the scale
+// reports the new unit
+func (f *Felicita) SetUnitContext(ctx context.Context, unit scale.Unit) error {
+	if err := validateUnit(unit); err != nil {
+		return err
+	}
+
+	// Check if the unit is already set to the expected value
+	if current := f.Unit(); current != scale.UnitUnknown && current == unit {
+		return nil
+	}
+
+	// Toggle unit, if not
+	return f.executeContext(ctx, protocol.CmdToggleUnit, hasUnit(unit))
+}
+
+// Precision returns the current weight precision (derived from the weight data, hence
+// it may be unknown until a non-zero weight has been measured for a while)
+func (f *Felicita) Precision() scale.Precision {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.precision
+}
+
+// SetPrecision changes the weight precision from / to 0.1 / 0.01. Since the scale only
+// supports toggling the precision, scale.ErrPrecisionUnknown is returned while the current
+// precision is unknown (i.e. until a non-zero weight has been measured)
+func (f *Felicita) SetPrecision(p scale.Precision) error {
+	if isSet, err := f.isPrecisionSet(p); isSet || err != nil {
+		return err
+	}
+
+	// Toggle precision, if not
+	return f.TogglePrecision()
+}
+
+// SetPrecisionContext changes the weight precision from / to 0.1 / 0.01 and waits until
+// the change is reflected by the weight data (see SetPrecision and TogglePrecisionContext)
+func (f *Felicita) SetPrecisionContext(ctx context.Context, p scale.Precision) error {
+	if isSet, err := f.isPrecisionSet(p); isSet || err != nil {
+		return err
+	}
+
+	// Toggle precision, if not
+	return f.TogglePrecisionContext(ctx)
 }
 
 // TogglePrecision toggles the weight precision between 0.1 and 0.01
 func (f *Felicita) TogglePrecision() error {
-	return f.write(cmdTogglePrecision)
+	current := f.Precision()
+	if err := f.execute(protocol.CmdTogglePrecision, hasToggledPrecision(current)); err != nil {
+		return err
+	}
+
+	f.anticipatePrecisionToggle(current)
+	return nil
+}
+
+// TogglePrecisionContext toggles the weight precision between 0.1 and 0.01 and waits
+// until the change is reflected by the weight data. Since the precision can only be
+// derived from a non-zero weight, the change cannot be confirmed (and the method
+// returns as soon as the command was sent) while the current precision is unknown
+func (f *Felicita) TogglePrecisionContext(ctx context.Context) error {
+	current := f.Precision()
+	if err := f.executeContext(ctx, protocol.CmdTogglePrecision, hasToggledPrecision(current)); err != nil {
+		return err
+	}
+
+	f.anticipatePrecisionToggle(current)
+	return nil
 }
 
 // StartTimer starts the timer / stopwatch
 func (f *Felicita) StartTimer() error {
-	if err := f.write(cmdStartTimer); err != nil {
+	if err := f.write(protocol.CmdStartTimer); err != nil {
 		return err
 	}
 
-	if f.timer == nil {
-		f.timer = stopwatch.Start(0)
-	} else {
-		f.timer.Start(0)
-	}
+	f.timer.Start()
 
 	return nil
 }
 
 // StopTimer stops the timer / stopwatch
 func (f *Felicita) StopTimer() error {
-	if err := f.write(cmdStopTimer); err != nil {
+	if err := f.write(protocol.CmdStopTimer); err != nil {
 		return err
 	}
 
-	if f.timer != nil {
-		f.timer.Stop()
-	}
+	f.timer.Stop()
 
 	return nil
 }
 
 // ResetTimer resets the timer / stopwatch
 func (f *Felicita) ResetTimer() error {
-	if err := f.write(cmdResetTimer); err != nil {
+	if err := f.write(protocol.CmdResetTimer); err != nil {
 		return err
 	}
 
-	if f.timer != nil {
-		f.timer.Reset()
-	}
+	f.timer.Reset()
 
 	return nil
 }
 
 // ElapsedTime returns the current timer value
 func (f *Felicita) ElapsedTime() time.Duration {
-	if f.timer != nil {
-		return f.timer.ElapsedTime()
-	}
-
-	return 0
+	return f.timer.ElapsedTime()
 }
 
-// Close terminates the connection to the device
-func (f *Felicita) Close() error {
-	close(f.doneChan)
+// Close terminates the connection to the device (subsequent calls are a no-op)
+func (f *Felicita) Close() (err error) {
+	f.closeOnce.Do(func() {
+		f.cancel()
+		f.ready.Close()
+		f.broker.Close()
 
-	_ = f.btDevice.StopScanning()
-	return f.btDevice.RemoveAllServices()
+		_ = f.btDevice.StopScanning()
+		err = f.btDevice.RemoveAllServices()
+
+		// Close the device (releasing it if shared via an adapter)
+		if closeErr := f.btDevice.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 func (f *Felicita) subscribe() error {
 
-	// Register handlers
-	f.btDevice.Handle(
-		gatt.AddPeripheralDiscovered(f.genOnPeriphDiscovered()),
-		gatt.AddPeripheralConnected(f.onPeriphConnected),
-		gatt.AddPeripheralDisconnected(f.onPeriphDisconnected),
-	)
+	// Register handlers (routed to this instance if the device is shared via an adapter)
+	scale.HandlePeripherals(f.btDevice, f.genOnPeriphDiscovered(), f.onPeriphConnected, f.onPeriphDisconnected)
 
 	// Initialize the device
 	return f.btDevice.Init(f.onStateChanged)
 }
 
 func (f *Felicita) setStatus(state scale.State, err error) {
-	f.connectionStatus = scale.ConnectionStatus{
-		State: state,
-		Error: err,
-	}
+	status := f.reconnector.Status(state, err)
 
-	// Call handler function, if any
-	if f.stateChangeHandler != nil {
-		f.stateChangeHandler(f.connectionStatus)
-	}
+	f.mu.Lock()
+	f.connectionStatus = status
+	f.mu.Unlock()
 
-	// Put state change on channel, if any
-	if f.stateChangeChan != nil {
-		select {
-		case f.stateChangeChan <- f.connectionStatus:
-		default:
-		}
-	}
+	// Distribute state change to all consumers
+	f.broker.PublishState(status)
 }
 
-func (f *Felicita) write(cmd byte) error {
-	if f.btPeripheral == nil || f.btCharacteristic == nil {
+func (f *Felicita) write(cmd protocol.Command) error {
+	f.mu.RLock()
+	btPeripheral, btCharacteristic := f.btPeripheral, f.btCharacteristic
+	f.mu.RUnlock()
+
+	if btPeripheral == nil || btCharacteristic == nil {
 		return fmt.Errorf("failed to write to uninitialized device")
 	}
 
-	return f.btPeripheral.WriteCharacteristic(f.btCharacteristic, []byte{cmd}, false)
+	return btPeripheral.WriteCharacteristic(btCharacteristic, cmd.Encode(), false)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -332,6 +520,7 @@ func (f *Felicita) genOnPeriphDiscovered() func(p gatt.Peripheral, arg2 *gatt.Ad
 			return
 		}
 
+		f.link.AddRSSI(arg3)
 		f.logger.Debugf("connecting device `%s/%s`", p.Name(), p.ID())
 
 		// Stop scanning once we've got the peripheral we're looking for.
@@ -354,10 +543,15 @@ func (f *Felicita) onPeriphConnected(p gatt.Peripheral, connErr error) {
 
 	f.logger.Debugf("connected peripheral `%s/%s`", p.Name(), p.ID())
 
+	f.connWG.Add(1)
+	released := f.release.Released(p)
 	f.setStatus(scale.StateConnected, nil)
 	defer func() {
+		f.ready.Set(false)
 		_ = p.Device().CancelConnection(p)
 		f.setStatus(scale.StateDisconnected, connErr)
+		f.release.Done(p)
+		f.connWG.Done()
 	}()
 
 	// Set connection MTU
@@ -373,6 +567,20 @@ func (f *Felicita) onPeriphConnected(p gatt.Peripheral, connErr error) {
 		return
 	}
 	for _, s := range ss {
+
+		// Read device information (if available)
+		if s.UUID().String() == scale.DeviceInformationService {
+			info, err := scale.ReadDeviceInfo(p, s)
+			if err != nil {
+				f.logger.Warnf("failed to read device information: %s", err)
+				continue
+			}
+			f.mu.Lock()
+			f.deviceInfo = info
+			f.mu.Unlock()
+			continue
+		}
+
 		if s.UUID().String() == dataService {
 
 			// Discover characteristics
@@ -383,8 +591,10 @@ func (f *Felicita) onPeriphConnected(p gatt.Peripheral, connErr error) {
 			}
 			for _, c := range cs {
 				if c.UUID().String() == dataCharacteristic {
+					f.mu.Lock()
 					f.btPeripheral = p
 					f.btCharacteristic = c
+					f.mu.Unlock()
 
 					// Discover descriptors
 					_, err := p.DiscoverDescriptors(nil, c)
@@ -402,146 +612,223 @@ func (f *Felicita) onPeriphConnected(p gatt.Peripheral, connErr error) {
 		}
 	}
 
+	f.ready.Set(true)
+	f.reconnector.Reset()
+
+	// Monitor the signal strength for as long as the peripheral is connected
+	stopMonitor := make(chan struct{})
+	defer close(stopMonitor)
+	go f.monitorRSSI(p, stopMonitor)
+
+	// Wait until the peripheral is released upon disconnect (which may have happened at any
+	// point during the connection setup) or once the scale is closed
 	f.logger.Debugf("waiting to release peripheral `%s/%s`", p.Name(), p.ID())
-	<-f.doneChan
+	select {
+	case <-released:
+	case <-f.ctx.Done():
+	}
 	f.logger.Debugf("released peripheral `%s/%s`", p.Name(), p.ID())
 }
 
-func (f *Felicita) onPeriphDisconnected(p gatt.Peripheral, _ error) {
+func (f *Felicita) onPeriphDisconnected(p gatt.Peripheral, err error) {
 
 	if !f.thisDevice(p) {
 		return
 	}
 
-	f.disconnect()
+	f.release.Release(p)
 	f.logger.Debugf("disconnected peripheral `%s/%s`", p.Name(), p.ID())
 
-	time.Sleep(100 * time.Millisecond)
-	f.setStatus(scale.StateScanning, nil)
-	if err := f.btDevice.Scan([]gatt.UUID{}, false); err != nil {
-		f.logger.Warnf("failed to re-enable scanning after disconnect: %s", err)
-	}
+	// Once the connection has been released, attempt to reconnect according to the
+	// reconnect policy (reporting the cause of the disconnect, if any)
+	go func() {
+		f.connWG.Wait()
+		if err == nil {
+			err = f.ConnectionStatus().Error
+		}
+		f.reconnector.Run(f.ctx, f.btDevice, &f.ready, err, f.setStatus)
+	}()
 }
 
-func (f *Felicita) thisDevice(p gatt.Peripheral) bool {
+// monitorRSSI periodically reads the signal strength of a connected peripheral until
+// stop is closed (or the platform turns out not to support reading it)
+func (f *Felicita) monitorRSSI(p gatt.Peripheral, stop chan struct{}) {
+	if f.rssiInterval <= 0 {
+		return
+	}
 
-	// Check if name and / or device ID have been overridden
-	if f.deviceID != "" && strings.EqualFold(p.ID(), f.deviceID) {
-		return true
+	ticker := time.NewTicker(f.rssiInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !f.link.AddRSSI(p.ReadRSSI()) {
+				f.logger.Debugf("reading RSSI of peripheral `%s/%s` not supported, stopping link monitoring", p.Name(), p.ID())
+				f.link.SetRSSIUnsupported()
+				return
+			}
+		case <-stop:
+			return
+		case <-f.ctx.Done():
+			return
+		}
 	}
-	return strings.EqualFold(p.Name(), f.deviceName)
 }
 
-func (f *Felicita) disconnect() {
-	select {
-	case f.doneChan <- struct{}{}:
-	default:
+func (f *Felicita) thisDevice(p gatt.Peripheral) bool {
+
+	// If a device ID has been set, only the peripheral with this ID is accepted (several
+	// scales may share the same name), otherwise match by name
+	if f.deviceID != "" {
+		return strings.EqualFold(p.ID(), f.deviceID)
 	}
+	return strings.EqualFold(p.Name(), f.deviceName)
 }
 
 func (f *Felicita) receiveData(_ *gatt.Characteristic, req []byte, err error) {
 
-	if err != nil || len(req) != 18 {
+	if err != nil {
+		f.link.CountDropped()
+		f.logger.Debugf("failed to receive frame: %s", err)
 		return
 	}
 
-	weight, convErr := strconv.ParseFloat(string(req[2:9]), 64)
-	if convErr != nil {
+	frame, err := protocol.Decode(req)
+	if err != nil {
+		f.link.CountInvalid()
+		f.logger.Debugf("dropping invalid frame: %s", err)
 		return
 	}
+	f.link.CountReceived()
+
 	dataPoint := scale.DataPoint{
 		TimeStamp: time.Now(),
-		Weight:    weight / 100.,
-		Unit:      parseUnit(req[9:11]),
+		Weight:    frame.Weight,
+		Unit:      frame.Unit,
 	}
-	f.batteryLevel = req[15]
-	f.isBuzzingOnTouch = parseSignalFlag(req[14])
-	f.unit = dataPoint.Unit
 
-	// Upon first data reception, check if the Buzzer is configured as expected and
-	// attempt to force the setting if not (unles not configured)
-	f.forceBuzzerSetting()
+	f.mu.Lock()
+	f.batteryLevel = frame.BatteryLevelRaw
+	f.isBuzzingOnTouch = frame.IsBuzzingOnTouch
+	f.unit = frame.Unit
+	f.updatePrecision(frame)
+	needsBuzzerToggle := f.needsBuzzerToggle()
 	f.hasReceivedData = true
+	f.confirm(frame)
+	f.mu.Unlock()
 
-	// Call handler function, if any
-	if f.dataHandler != nil {
-		f.dataHandler(dataPoint)
+	// Upon first data reception, check if the Buzzer is configured as expected and
+	// attempt to force the setting if not (unles not configured)
+	if needsBuzzerToggle {
+		if err := f.write(protocol.CmdToggleBuzzer); err != nil {
+			f.logger.Warnf("failed to force buzzer setting to `%s`: %s", f.forceBuzzerSettingOnConnect, err)
+		}
 	}
 
-	// Put data point on channel, if any
-	if f.dataChan != nil {
-		f.dataChan <- dataPoint
+	// Convert data point to the canonical unit (if requested), dropping it if its unit
+	// is unknown
+	if f.canonicalUnit != "" {
+		converted, err := dataPoint.In(f.canonicalUnit)
+		if err != nil {
+			f.logger.Debugf("dropping data point: %s", err)
+			return
+		}
+		dataPoint = converted
 	}
-}
 
-func (f *Felicita) buzzAndRestore() (err error) {
-	if err = f.ToggleBuzzingOnTouch(); err != nil {
-		return
-	}
-	if err = f.waitForBuzzer(true); err != nil {
+	// Filter data point (if filters are installed), dropping it if discarded by any filter
+	filtered, ok := f.filter.Filter(dataPoint)
+	if !ok {
 		return
 	}
-	if err = f.ToggleBuzzingOnTouch(); err != nil {
+	dataPoint = filtered
+
+	// Annotate data point with the stability of the weight
+	dataPoint = f.stability.Process(dataPoint)
+
+	// Distribute data point to all consumers
+	f.broker.PublishData(dataPoint)
+}
+
+// updatePrecision derives the current precision from a frame (requires the lock to be held)
+func (f *Felicita) updatePrecision(frame protocol.Frame) {
+
+	// A non-zero hundredths digit proves high precision
+	if frame.Precision() == scale.PrecisionHigh {
+		f.precision = scale.PrecisionHigh
+		f.lowPrecisionFrames = 0
 		return
 	}
-	if err = f.waitForBuzzer(false); err != nil {
+
+	// A zero weight does not provide any information on the precision
+	if frame.Weight == 0 {
 		return
 	}
 
-	return
+	// A persistent zero hundredths digit on a non-zero weight implies low precision
+	if f.lowPrecisionFrames++; f.lowPrecisionFrames >= minLowPrecisionFrames {
+		f.precision = scale.PrecisionLow
+	}
 }
 
-func (f *Felicita) waitForBuzzer(targetState bool) error {
-	for i := 0; i < btSettleRetries; i++ {
-		if f.IsBuzzingOnTouch() == targetState {
-			return nil
-		}
-		time.Sleep(btSettleDelay)
+func (f *Felicita) buzzAndRestore() (err error) {
+	if err = f.toggleBuzzerConfirmed(); err != nil {
+		return
 	}
 
-	return fmt.Errorf("target buzzer state %v was not reached within %v", targetState, time.Duration(btSettleRetries)*btSettleDelay)
+	return f.toggleBuzzerConfirmed()
 }
 
-func (f *Felicita) forceBuzzerSetting() {
-	if !f.hasReceivedData && f.forceBuzzerSettingOnConnect != "" {
-		if f.isBuzzingOnTouch && f.forceBuzzerSettingOnConnect == BuzzerSettingOff ||
-			!f.isBuzzingOnTouch && f.forceBuzzerSettingOnConnect == BuzzerSettingOn {
-			if err := f.ToggleBuzzingOnTouch(); err != nil {
-				f.logger.Warnf("failed to force buzzer setting to `%s`: %s", f.forceBuzzerSettingOnConnect, err)
-			}
-		}
-	}
-}
+func (f *Felicita) toggleBuzzerConfirmed() error {
+	ctx, cancel := context.WithTimeout(context.Background(), buzzerConfirmationTimeout)
+	defer cancel()
 
-////////////////////////////////////////////////////////////////////////////////
+	return f.ToggleBuzzingOnTouchContext(ctx)
+}
 
-func parseUnit(data []byte) scale.Unit {
-	if len(data) != 2 {
-		return scale.UnitUnknown
+// isPrecisionSet returns if the precision is already set to the expected value (or an error
+// if the requested precision is invalid or the current one is unknown, in which case it
+// cannot be determined if toggling it would yield the requested one)
+func (f *Felicita) isPrecisionSet(p scale.Precision) (bool, error) {
+	if err := validatePrecision(p); err != nil {
+		return false, err
 	}
 
-	if strings.Contains(strings.ToLower(string(data)), "g") {
-		return scale.UnitGrams
-	}
-	if strings.Contains(strings.ToLower(string(data)), "oz") {
-		return scale.UnitOz
+	current := f.Precision()
+	if current == scale.PrecisionUnknown {
+		return false, scale.ErrPrecisionUnknown
 	}
 
-	return scale.UnitUnknown
+	return current == p, nil
 }
 
-func parseBatteryLevel(data byte) float64 {
+// anticipatePrecisionToggle anticipates the new precision (if known and not yet
+// confirmed) after toggling it, subsequent data will correct it if required
+func (f *Felicita) anticipatePrecisionToggle(previous scale.Precision) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	val := int(data)
-	if val < minBatteryLevel {
-		return 0.
-	} else if val > maxBatteryLevel {
-		return 1.
+	if f.precision != previous {
+		return
 	}
 
-	return math.Round((float64(val)-minBatteryLevel)/(maxBatteryLevel-minBatteryLevel)*100.) / 100.
+	switch f.precision {
+	case scale.PrecisionLow:
+		f.precision = scale.PrecisionHigh
+	case scale.PrecisionHigh:
+		f.precision = scale.PrecisionLow
+	}
+	f.lowPrecisionFrames = 0
 }
 
-func parseSignalFlag(data byte) bool {
-	return data == 0x22
+// needsBuzzerToggle determines if the buzzer setting has to be forced upon first data
+// reception (requires the lock to be held)
+func (f *Felicita) needsBuzzerToggle() bool {
+	if f.hasReceivedData || f.forceBuzzerSettingOnConnect == "" {
+		return false
+	}
+
+	return f.isBuzzingOnTouch && f.forceBuzzerSettingOnConnect == BuzzerSettingOff ||
+		!f.isBuzzingOnTouch && f.forceBuzzerSettingOnConnect == BuzzerSettingOn
 }
@@ -0,0 +1,593 @@
+package felicita
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/felicita/protocol"
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+const (
+	testTimeout        = 5 * time.Second
+	testNotifyInterval = time.Millisecond
+	testIterations     = 100
+)
+
+func newTestScale(t *testing.T, options ...func(*Felicita)) (*Felicita, *fakePeripheral, func()) {
+	t.Helper()
+
+	return newTestScaleWith(t, newFakePeripheral(), options...)
+}
+
+func newTestScaleWith(t *testing.T, p *fakePeripheral, options ...func(*Felicita)) (*Felicita, *fakePeripheral, func()) {
+	t.Helper()
+
+	f, err := New(append([]func(*Felicita){WithDevice(p.device)}, options...)...)
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.onPeriphConnected(p, nil)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := f.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to wait for connection: %s", err)
+	}
+
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.run(testNotifyInterval, done)
+	}()
+
+	return f, p, func() {
+		close(done)
+		if err := f.Close(); err != nil {
+			t.Fatalf("failed to close scale: %s", err)
+		}
+		wg.Wait()
+	}
+}
+
+// waitFor polls a condition until it is met (failing the test upon timeout)
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for i := 0; !condition(); i++ {
+		if i*int(testNotifyInterval) > int(testTimeout) {
+			t.Fatalf("condition was not met within %v", testTimeout)
+		}
+		time.Sleep(testNotifyInterval)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	// Drain data and state channels while they are continuously replaced (the drain
+	// must outlive the scale to not block any pending notification upon cleanup)
+	done := make(chan struct{})
+	defer close(done)
+	dataChan := make(chan scale.DataPoint, 16)
+	stateChan := make(chan scale.ConnectionStatus, 16)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-dataChan:
+			case <-stateChan:
+			}
+		}
+	}()
+
+	f, _, cleanup := newTestScale(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < testIterations; i++ {
+				if err := fn(); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Getters
+	run(func() error {
+		_ = f.ConnectionStatus()
+		_ = f.BatteryLevel()
+		_ = f.BatteryLevelRaw()
+		_ = f.IsBuzzingOnTouch()
+		_ = f.Unit()
+		_ = f.Precision()
+		_ = f.ElapsedTime()
+		return nil
+	})
+
+	// Handlers and channels
+	run(func() error {
+		f.SetDataHandler(func(data scale.DataPoint) {})
+		f.SetDataChannel(dataChan)
+		f.SetStateChangeHandler(func(status scale.ConnectionStatus) {})
+		f.SetStateChangeChannel(stateChan)
+		f.setStatus(scale.StateConnected, nil)
+		return nil
+	})
+
+	// Commands
+	run(f.Tare)
+	run(f.TogglePrecision)
+	run(func() error {
+
+		// The precision may not have been derived from the weight data yet
+		if err := f.SetPrecision(scale.PrecisionHigh); !errors.Is(err, scale.ErrPrecisionUnknown) {
+			return err
+		}
+		return nil
+	})
+	run(func() error { return f.SetUnit(scale.UnitOz) })
+	run(func() error {
+		if err := f.StartTimer(); err != nil {
+			return err
+		}
+		if err := f.StopTimer(); err != nil {
+			return err
+		}
+		return f.ResetTimer()
+	})
+
+	// Concurrent buzz requests must not interfere with each other
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.Buzz(2); err != nil {
+				t.Errorf("unexpected error during buzz: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestBuzzRestoresBuzzerState(t *testing.T) {
+	f, p, cleanup := newTestScale(t, WithForceBuzzerSettingOnConnect(BuzzerSettingOn))
+	defer cleanup()
+
+	for i := 0; !f.IsBuzzingOnTouch(); i++ {
+		if i*int(testNotifyInterval) > int(testTimeout) {
+			t.Fatalf("buzzer setting was not forced upon connection")
+		}
+		time.Sleep(testNotifyInterval)
+	}
+	if err := f.Buzz(2); err != nil {
+		t.Fatalf("unexpected error during buzz: %s", err)
+	}
+	if !f.IsBuzzingOnTouch() {
+		t.Fatalf("buzzer state was not restored")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.frame.IsBuzzingOnTouch {
+		t.Fatalf("buzzer state of device was not restored")
+	}
+}
+
+func TestCloseOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := New(WithDevice(&fakeDevice{}), WithContext(ctx))
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), testTimeout)
+	defer waitCancel()
+	if err := f.WaitConnected(waitCtx); !errors.Is(err, scale.ErrClosed) {
+		t.Fatalf("unexpected error waiting for connection, want %v, have %v", scale.ErrClosed, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error closing scale twice: %s", err)
+	}
+}
+
+func TestConfirmedCommands(t *testing.T) {
+	f, _, cleanup := newTestScale(t, WithConfirmationTimeout(testTimeout))
+	defer cleanup()
+
+	if err := f.SetUnit(scale.UnitOz); err != nil {
+		t.Fatalf("failed to set unit: %s", err)
+	}
+	if unit := f.Unit(); unit != scale.UnitOz {
+		t.Fatalf("unexpected unit after confirmation, want %s, have %s", scale.UnitOz, unit)
+	}
+
+	if err := f.ToggleBuzzingOnTouch(); err != nil {
+		t.Fatalf("failed to toggle buzzer: %s", err)
+	}
+	if !f.IsBuzzingOnTouch() {
+		t.Fatalf("unexpected buzzer state after confirmation")
+	}
+
+	// The precision can only be confirmed once it has been derived from the weight data
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	for f.Precision() == scale.PrecisionUnknown {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("precision was not derived from weight data")
+		case <-time.After(testNotifyInterval):
+		}
+	}
+	for _, p := range []scale.Precision{scale.PrecisionHigh, scale.PrecisionLow} {
+		if err := f.SetPrecisionContext(ctx, p); err != nil {
+			t.Fatalf("failed to set precision to %v: %s", p, err)
+		}
+		if current := f.Precision(); current != p {
+			t.Fatalf("unexpected precision after confirmation, want %v, have %v", p, current)
+		}
+	}
+
+	if err := f.TareContext(ctx); err != nil {
+		t.Fatalf("failed to tare: %s", err)
+	}
+}
+
+func TestSetPrecisionUnknown(t *testing.T) {
+
+	// Nothing on the platform, hence the precision cannot be derived from the weight data
+	p := newFakePeripheral()
+	p.frame.Weight = 0
+	f, p, cleanup := newTestScaleWith(t, p)
+	defer cleanup()
+	waitFor(t, func() bool { return f.FrameStats().Received > 0 })
+
+	// Setting the precision (repeatedly, e.g. by several clients) must neither toggle it
+	// blindly nor invert it
+	for i := 0; i < 2; i++ {
+		if err := f.SetPrecision(scale.PrecisionHigh); !errors.Is(err, scale.ErrPrecisionUnknown) {
+			t.Fatalf("unexpected error setting precision while unknown: %v", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.writes) != 0 || p.highPrecision {
+		t.Fatalf("unexpected commands sent to scale: %v", p.writes)
+	}
+}
+
+func TestIsTared(t *testing.T) {
+	for _, cs := range []struct {
+		weight   float64
+		unit     scale.Unit
+		expected bool
+	}{
+		{0, scale.UnitGrams, true},
+		{-0.4, scale.UnitGrams, true},
+		{0.6, scale.UnitGrams, false},
+		{0.01, scale.UnitOz, true},
+		{0.3, scale.UnitOz, false},
+		{0, scale.UnitUnknown, false},
+	} {
+		if res := isTared(protocol.Frame{Weight: cs.weight, Unit: cs.unit}); res != cs.expected {
+			t.Fatalf("unexpected tare confirmation for %v%s: %v", cs.weight, cs.unit, res)
+		}
+	}
+}
+
+func TestUnconfirmedCommand(t *testing.T) {
+	f, p, cleanup := newTestScale(t)
+	defer cleanup()
+
+	p.mu.Lock()
+	p.ignoreWrites = true
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var timeoutErr *scale.TimeoutError
+	if err := f.TareContext(ctx); !errors.As(err, &timeoutErr) {
+		t.Fatalf("unexpected error for unconfirmed command, want %T, have %v", timeoutErr, err)
+	}
+	if !errors.Is(timeoutErr, context.DeadlineExceeded) {
+		t.Fatalf("unexpected underlying error: %s", timeoutErr.Err)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.confirmations) != 0 {
+		t.Fatalf("unexpected number of pending confirmations: %d", len(f.confirmations))
+	}
+}
+
+// failingDevice simulates a device that cannot be initialized
+type failingDevice struct {
+	fakeDevice
+
+	closed bool
+}
+
+func (d *failingDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	return errors.New("device unavailable")
+}
+
+func (d *failingDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestFailedInit(t *testing.T) {
+	d := &failingDevice{}
+	f, err := New(WithDevice(d))
+	if err == nil {
+		t.Fatalf("unexpected success instantiating scale on failing device")
+	}
+	if f != nil {
+		t.Fatalf("unexpected scale returned along with error: %v", err)
+	}
+
+	// The device must be released (terminating the context of the scale)
+	if !d.closed {
+		t.Fatalf("device was not closed after failed initialization")
+	}
+}
+
+func TestCloseDisconnect(t *testing.T) {
+	f, p, cleanup := newTestScale(t)
+
+	// Releasing the peripheral upon closing the scale causes a disconnect, which must be
+	// handled gracefully after the scale has been closed
+	cleanup()
+	f.onPeriphDisconnected(p, nil)
+	f.onPeriphDisconnected(p, nil)
+}
+
+func TestDisconnectDuringSetup(t *testing.T) {
+	p := newFakePeripheral()
+	f, err := New(WithDevice(p.device), WithReconnectPolicy(scale.NeverReconnect))
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer f.Close()
+
+	// A disconnect while discovering the services of the peripheral must release the
+	// connection once its setup has finished
+	p.onDiscover = func() {
+		f.onPeriphDisconnected(p, nil)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.onPeriphConnected(p, nil)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatalf("connection was not released after disconnect during setup")
+	}
+	waitFor(t, func() bool { return f.ConnectionStatus().State == scale.StateDisconnected })
+}
+
+func TestReconnectPolicy(t *testing.T) {
+	f, p, cleanup := newTestScale(t, WithReconnectPolicy(scale.ReconnectPolicy{
+		InitialDelay: time.Millisecond,
+		MaxAttempts:  2,
+		ScanTimeout:  10 * time.Millisecond,
+	}))
+	defer cleanup()
+
+	stateChan := make(chan scale.ConnectionStatus, 16)
+	f.SetStateChangeChannel(stateChan)
+	waitForState := func(state scale.State) scale.ConnectionStatus {
+		for {
+			select {
+			case status := <-stateChan:
+				if status.State == state {
+					return status
+				}
+			case <-time.After(testTimeout):
+				t.Fatalf("state %v was not reached", state)
+			}
+		}
+	}
+
+	// The cause of the disconnect should be reported upon the first attempt, subsequent
+	// attempts fail since the (fake) scale is never found again
+	errDisconnect := errors.New("connection lost")
+	f.onPeriphDisconnected(p, errDisconnect)
+	for attempt := 1; attempt <= 2; attempt++ {
+		status := waitForState(scale.StateReconnecting)
+		if status.Attempt != attempt || status.Error == nil || attempt == 1 && !errors.Is(status.Error, errDisconnect) {
+			t.Fatalf("unexpected reconnecting status for attempt %d: %+v", attempt, status)
+		}
+	}
+
+	// Once exhausted, the scale should remain disconnected
+	status := waitForState(scale.StateDisconnected)
+	if !errors.Is(status.Error, scale.ErrReconnectExhausted) {
+		t.Fatalf("unexpected error after exhausting reconnect attempts: %v", status.Error)
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+	f, _, cleanup := newTestScale(t)
+	defer cleanup()
+	var _ scale.DeviceInformation = f
+
+	expected := scale.DeviceInfo{
+		ManufacturerName: "Manufacturer Name",
+		ModelNumber:      "Model Number",
+		FirmwareRevision: "Firmware Revision",
+	}
+	if info := f.DeviceInfo(); info != expected {
+		t.Fatalf("unexpected device information, want %+v, have %+v", expected, info)
+	}
+}
+
+func TestDeviceIDMatching(t *testing.T) {
+	p := newFakePeripheral()
+
+	for _, cs := range []struct {
+		deviceID string
+		expected bool
+	}{
+		{"", true},
+		{p.ID(), true},
+		{"AA:AA:AA:AA:AA:AA", false},
+	} {
+		f := &Felicita{deviceID: cs.deviceID, deviceName: defaultDeviceName}
+		if res := f.thisDevice(p); res != cs.expected {
+			t.Fatalf("unexpected match of peripheral `%s/%s` for device ID `%s`: %v", p.Name(), p.ID(), cs.deviceID, res)
+		}
+	}
+}
+
+func TestLinkQuality(t *testing.T) {
+	f, p, cleanup := newTestScale(t, WithRSSIInterval(time.Millisecond), WithRSSIThreshold(-80))
+	defer cleanup()
+
+	// Wait for the signal strength to be sampled while connected
+	waitFor(t, func() bool { return f.RSSI() == -60 })
+
+	// Degrade the link and wait for it to be detected
+	p.mu.Lock()
+	p.rssi = -90
+	p.mu.Unlock()
+	waitFor(t, func() bool { return f.link.IsDegraded() })
+
+	history := f.RSSIHistory()
+	if len(history) == 0 || history[len(history)-1].RSSI != -90 {
+		t.Fatalf("unexpected RSSI history: %v", history)
+	}
+
+	// Emit an invalid frame and a failed notification
+	before := f.FrameStats()
+	p.notifyRaw([]byte{0x01, 0x02}, nil)
+	p.notifyRaw(nil, errors.New("notification failed"))
+	stats := f.FrameStats()
+	if stats.Invalid != before.Invalid+1 || stats.Dropped != before.Dropped+1 {
+		t.Fatalf("unexpected frame statistics, had %+v, have %+v", before, stats)
+	}
+	if stats.Received == 0 {
+		t.Fatalf("unexpected number of received frames: %d", stats.Received)
+	}
+}
+
+func TestLinkQualityUnsupported(t *testing.T) {
+	f, p, cleanup := newTestScale(t, WithRSSIInterval(time.Millisecond))
+	defer cleanup()
+
+	// Reading the RSSI while connected is not supported (as is the case on Linux), hence
+	// the sample obtained while scanning must not be reported as current
+	p.mu.Lock()
+	p.rssi = -1
+	p.mu.Unlock()
+	waitFor(t, func() bool { return !f.IsRSSISupported() })
+
+	if rssi, history := f.RSSI(), f.RSSIHistory(); rssi != 0 || len(history) != 0 {
+		t.Fatalf("unexpected RSSI while unsupported: %d / %v", rssi, history)
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	f, _, cleanup := newTestScale(t)
+	defer cleanup()
+
+	// A consumer that never reads must neither stall the reception of data nor cause
+	// any data points to be lost unnoticed
+	dataChan := make(chan scale.DataPoint, 1)
+	f.SetDataChannel(dataChan)
+
+	waitFor(t, func() bool { return f.DeliveryStats().DroppedData >= testIterations })
+	if stats := f.FrameStats(); stats.Received < testIterations {
+		t.Fatalf("unexpected number of received frames: %d", stats.Received)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	f, _, cleanup := newTestScale(t)
+	var _ scale.Publisher = f
+
+	// Multiple subscriptions and the legacy data channel must all receive data
+	dataChan := make(chan scale.DataPoint, 1)
+	f.SetDataChannel(dataChan)
+	subs := []*scale.Subscription{f.Subscribe(), f.Subscribe(scale.WithBufferSize(1))}
+	for _, sub := range subs {
+		select {
+		case data := <-sub.Data():
+			if data.Unit != scale.UnitGrams {
+				t.Fatalf("unexpected data point: %+v", data)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no data received via subscription")
+		}
+	}
+	select {
+	case <-dataChan:
+	case <-time.After(testTimeout):
+		t.Fatalf("no data received via data channel")
+	}
+
+	// Closing the scale must close all subscriptions
+	cleanup()
+	for _, sub := range subs {
+		for range sub.Data() {
+		}
+		for range sub.States() {
+		}
+	}
+}
+
+func TestStabilityDetection(t *testing.T) {
+	f, p, cleanup := newTestScale(t, WithStabilityDetection(10*time.Millisecond, 0.1))
+	defer cleanup()
+
+	events := make(chan scale.StabilityEvent, 16)
+	f.SetStabilityChangeHandler(func(event scale.StabilityEvent) {
+		events <- event
+	})
+	waitForEvent := func(stable bool) {
+		for {
+			select {
+			case event := <-events:
+				if event.Stable == stable {
+					return
+				}
+			case <-time.After(testTimeout):
+				t.Fatalf("weight did not become stable = %v", stable)
+			}
+		}
+	}
+
+	// The (constant) weight of the fake scale settles, then changes and settles again
+	waitForEvent(true)
+	p.mu.Lock()
+	p.frame.Weight = 20.
+	p.mu.Unlock()
+	waitForEvent(false)
+	waitForEvent(true)
+
+	if !f.IsStable() {
+		t.Fatalf("weight not reported as stable")
+	}
+}
@@ -1,6 +1,9 @@
 package felicita
 
 import (
+	"context"
+	"time"
+
 	"github.com/fako1024/btscale/pkg/scale"
 	"github.com/fako1024/gatt"
 )
@@ -17,7 +20,8 @@ const (
 	BuzzerSettingOff = "OFF"
 )
 
-// WithDeviceID sets the Bluetooth device ID
+// WithDeviceID sets the Bluetooth device ID (if set, only the peripheral with this ID is
+// connected to, regardless of its name)
 func WithDeviceID(deviceID string) func(*Felicita) {
 	return func(f *Felicita) {
 		f.deviceID = deviceID
@@ -52,3 +56,84 @@ func WithForceBuzzerSettingOnConnect(setting BuzzerSetting) func(*Felicita) {
 		f.forceBuzzerSettingOnConnect = setting
 	}
 }
+
+// WithContext sets a context, terminating the connection to the device (including any
+// ongoing scan) once it ends
+func WithContext(ctx context.Context) func(*Felicita) {
+	return func(f *Felicita) {
+		f.ctx = ctx
+	}
+}
+
+// WithConfirmationTimeout ensures that state-changing commands (Tare, SetUnit,
+// SetPrecision / TogglePrecision and ToggleBuzzingOnTouch) wait until the requested
+// change is reflected by the data received from the scale, returning a *scale.TimeoutError
+// if that does not happen within the given timeout
+func WithConfirmationTimeout(timeout time.Duration) func(*Felicita) {
+	return func(f *Felicita) {
+		f.confirmationTimeout = timeout
+	}
+}
+
+// WithReconnectPolicy sets the strategy to reconnect to the scale after the connection
+// was lost (see scale.ReconnectPolicy for the default behavior)
+func WithReconnectPolicy(policy scale.ReconnectPolicy) func(*Felicita) {
+	return func(f *Felicita) {
+		f.reconnector.Policy = policy
+	}
+}
+
+// WithRSSIInterval sets the interval in which the signal strength of the connected scale
+// is read (0: disable link monitoring while connected). Note that reading it is not
+// supported on Linux, where link monitoring stops after the first attempt
+func WithRSSIInterval(interval time.Duration) func(*Felicita) {
+	return func(f *Felicita) {
+		f.rssiInterval = interval
+	}
+}
+
+// WithRSSIThreshold sets the signal strength (in dBm) below which the link is regarded as
+// degraded, emitting a warning via the logger (not applicable on Linux, see
+// WithRSSIInterval())
+func WithRSSIThreshold(threshold int) func(*Felicita) {
+	return func(f *Felicita) {
+		f.link.Threshold = threshold
+	}
+}
+
+// WithDeliveryPolicy sets the behavior when putting data points / state changes on a
+// channel whose consumer does not keep up. It applies to the channels set via
+// SetDataChannel() / SetStateChangeChannel() and serves as default for subscriptions (by
+// default, delivery never blocks, see scale.DeliveryDefault)
+func WithDeliveryPolicy(data, state scale.DeliveryPolicy) func(*Felicita) {
+	return func(f *Felicita) {
+		f.broker.DataPolicy = data
+		f.broker.StatePolicy = state
+	}
+}
+
+// WithStabilityDetection sets the time window and the tolerance (in grams) used to determine
+// if the weight is stable (by default, a deviation of up to 0.2g within 1s)
+func WithStabilityDetection(window time.Duration, tolerance float64) func(*Felicita) {
+	return func(f *Felicita) {
+		f.stability.Window = window
+		f.stability.Tolerance = tolerance
+	}
+}
+
+// WithCanonicalUnit ensures that all data points are delivered in a certain unit, regardless
+// of the unit selected on the scale (data points in an unknown unit are dropped)
+func WithCanonicalUnit(unit scale.Unit) func(*Felicita) {
+	return func(f *Felicita) {
+		f.canonicalUnit = unit
+	}
+}
+
+// WithFilter installs a filter pipeline for the weight readings, i.e. all data points
+// are passed through the given filters in order before being delivered (the unfiltered
+// weight being retained in their RawWeight field)
+func WithFilter(filters ...scale.Filter) func(*Felicita) {
+	return func(f *Felicita) {
+		f.filter = append(f.filter, filters...)
+	}
+}
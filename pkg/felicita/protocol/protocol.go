@@ -0,0 +1,289 @@
+// Package protocol implements the (hardware independent) wire protocol of Felicita
+// bluetooth scales, i.e. decoding of notification frames and encoding of commands
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+// FrameLen denotes the length of a notification frame
+const FrameLen = 18
+
+// Frame layout (byte offsets)
+const (
+	offsetHeader     = 0
+	offsetSign       = 2
+	offsetWeight     = 3
+	offsetUnit       = 9
+	offsetReserved   = 11
+	offsetBuzzer     = 14
+	offsetBattery    = 15
+	offsetTerminator = 16
+
+	weightLen   = offsetUnit - offsetWeight
+	unitLen     = offsetReserved - offsetUnit
+	reservedLen = offsetBuzzer - offsetReserved
+
+	signPositive = '+'
+	signNegative = '-'
+
+	flagBuzzingOnTouch = 0x22
+	flagBuzzerOff      = 0x20
+
+	weightDivisor = 100.
+	maxRawWeight  = 999999.
+
+	minBatteryLevel = 129.
+	maxBatteryLevel = 158.
+)
+
+var (
+
+	// Header denotes the start sequence of each notification frame (as emitted by Encode,
+	// not validated by Decode)
+	Header = [2]byte{0x01, 0x02}
+
+	// Terminator denotes the end sequence of each notification frame (as emitted by
+	// Encode, not validated by Decode)
+	Terminator = [2]byte{'\r', '\n'}
+)
+
+// Command denotes a command that can be sent to the scale
+type Command byte
+
+const (
+
+	// CmdStartTimer starts the timer
+	CmdStartTimer Command = 0x52
+
+	// CmdStopTimer stops the timer
+	CmdStopTimer Command = 0x53
+
+	// CmdResetTimer resets the timer
+	CmdResetTimer Command = 0x43
+
+	// CmdToggleBuzzer toggles the buzzer (on user interaction)
+	CmdToggleBuzzer Command = 0x42
+
+	// CmdTogglePrecision toggles the weight precision between 0.1 and 0.01
+	CmdTogglePrecision Command = 0x44
+
+	// CmdTare tares the scale
+	CmdTare Command = 0x54
+
+	// CmdToggleUnit toggles the weight unit between g and oz
+	CmdToggleUnit Command = 0x55
+)
+
+// String returns a human-readable representation of the command
+func (c Command) String() string {
+	switch c {
+	case CmdStartTimer:
+		return "StartTimer"
+	case CmdStopTimer:
+		return "StopTimer"
+	case CmdResetTimer:
+		return "ResetTimer"
+	case CmdToggleBuzzer:
+		return "ToggleBuzzer"
+	case CmdTogglePrecision:
+		return "TogglePrecision"
+	case CmdTare:
+		return "Tare"
+	case CmdToggleUnit:
+		return "ToggleUnit"
+	}
+
+	return fmt.Sprintf("Command(%#02x)", byte(c))
+}
+
+// Encode returns the wire representation of the command
+func (c Command) Encode() []byte {
+	return []byte{byte(c)}
+}
+
+var (
+
+	// ErrInvalidLength denotes a frame of unexpected length
+	ErrInvalidLength = errors.New("invalid frame length")
+
+	// ErrInvalidSign denotes a frame with an invalid weight sign
+	ErrInvalidSign = errors.New("invalid weight sign")
+
+	// ErrInvalidWeight denotes a frame with a non-numeric weight
+	ErrInvalidWeight = errors.New("invalid weight")
+)
+
+// DecodeError denotes a failure to decode a notification frame, providing the cause
+// and position of the error as well as the raw data for diagnostic purposes
+type DecodeError struct {
+	Err    error
+	Offset int
+	Data   []byte
+}
+
+// Error returns a descriptive representation of the decoding error
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s at offset %d (frame: % x)", e.Err, e.Offset, e.Data)
+}
+
+// Unwrap returns the underlying cause (to support errors.Is())
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Frame denotes a decoded notification frame. Note that frames do not carry a
+// checksum, hence only their length and the format of the weight are validated (the
+// remaining fields may differ between firmware variants)
+type Frame struct {
+
+	// Weight denotes the (signed) weight in the respective unit
+	Weight float64
+
+	// Negative denotes if the weight sign is negative (also for a zero weight)
+	Negative bool
+
+	// Unit denotes the weight unit (UnitUnknown if not recognized)
+	Unit scale.Unit
+
+	// IsBuzzingOnTouch denotes if the buzzer (on user interaction) is turned on
+	IsBuzzingOnTouch bool
+
+	// BatteryLevelRaw denotes the battery level in its raw form
+	BatteryLevelRaw byte
+
+	// Reserved contains the remaining bytes of the frame (semantics unknown)
+	Reserved [reservedLen]byte
+}
+
+// BatteryLevel returns the battery level (in the range 0.0 - 1.0)
+func (f Frame) BatteryLevel() float64 {
+	return ParseBatteryLevel(f.BatteryLevelRaw)
+}
+
+// Precision returns the weight precision evident from the frame. Since the scale only
+// reports a non-zero hundredths digit when operating at high precision, a single frame
+// can only prove high precision (PrecisionUnknown is returned otherwise)
+func (f Frame) Precision() scale.Precision {
+	if int64(math.Round(math.Abs(f.Weight)*weightDivisor))%10 != 0 {
+		return scale.PrecisionHigh
+	}
+
+	return scale.PrecisionUnknown
+}
+
+// Encode returns the wire representation of the frame (e.g. for simulation purposes)
+func (f Frame) Encode() []byte {
+	data := make([]byte, 0, FrameLen)
+	data = append(data, Header[:]...)
+
+	sign := byte(signPositive)
+	if f.Negative || f.Weight < 0 {
+		sign = signNegative
+	}
+	data = append(data, sign)
+	data = append(data, fmt.Sprintf("%0*d", weightLen, int64(math.Min(math.Round(math.Abs(f.Weight)*weightDivisor), maxRawWeight)))...)
+
+	switch f.Unit {
+	case scale.UnitOz:
+		data = append(data, "oz"...)
+	case scale.UnitGrams:
+		data = append(data, "g "...)
+	default:
+		data = append(data, "  "...)
+	}
+
+	data = append(data, f.Reserved[:]...)
+	if f.IsBuzzingOnTouch {
+		data = append(data, flagBuzzingOnTouch)
+	} else {
+		data = append(data, flagBuzzerOff)
+	}
+	data = append(data, f.BatteryLevelRaw)
+
+	return append(data, Terminator[:]...)
+}
+
+// Decode validates and decodes a notification frame, returning a *DecodeError if the
+// frame is invalid
+func Decode(data []byte) (Frame, error) {
+
+	if len(data) != FrameLen {
+		return Frame{}, newDecodeError(ErrInvalidLength, len(data), data)
+	}
+
+	var frame Frame
+	switch data[offsetSign] {
+	case signPositive:
+	case signNegative:
+		frame.Negative = true
+	default:
+		return Frame{}, newDecodeError(ErrInvalidSign, offsetSign, data)
+	}
+
+	for i := offsetWeight; i < offsetUnit; i++ {
+		if data[i] < '0' || data[i] > '9' {
+			return Frame{}, newDecodeError(ErrInvalidWeight, i, data)
+		}
+	}
+	weight, err := strconv.ParseUint(string(data[offsetWeight:offsetUnit]), 10, 64)
+	if err != nil {
+		return Frame{}, newDecodeError(ErrInvalidWeight, offsetWeight, data)
+	}
+	frame.Weight = float64(weight) / weightDivisor
+	if frame.Negative && frame.Weight != 0 {
+		frame.Weight = -frame.Weight
+	}
+
+	frame.Unit = ParseUnit(data[offsetUnit:offsetReserved])
+	copy(frame.Reserved[:], data[offsetReserved:offsetBuzzer])
+	frame.IsBuzzingOnTouch = data[offsetBuzzer] == flagBuzzingOnTouch
+	frame.BatteryLevelRaw = data[offsetBattery]
+
+	return frame, nil
+}
+
+// ParseUnit decodes the weight unit of a frame
+func ParseUnit(data []byte) scale.Unit {
+	if len(data) != unitLen {
+		return scale.UnitUnknown
+	}
+
+	if strings.Contains(strings.ToLower(string(data)), "g") {
+		return scale.UnitGrams
+	}
+	if strings.Contains(strings.ToLower(string(data)), "oz") {
+		return scale.UnitOz
+	}
+
+	return scale.UnitUnknown
+}
+
+// ParseBatteryLevel converts a raw battery level into the range 0.0 - 1.0
+func ParseBatteryLevel(data byte) float64 {
+
+	val := int(data)
+	if val < minBatteryLevel {
+		return 0.
+	} else if val > maxBatteryLevel {
+		return 1.
+	}
+
+	return math.Round((float64(val)-minBatteryLevel)/(maxBatteryLevel-minBatteryLevel)*100.) / 100.
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func newDecodeError(err error, offset int, data []byte) *DecodeError {
+	return &DecodeError{
+		Err:    err,
+		Offset: offset,
+		Data:   append([]byte{}, data...),
+	}
+}
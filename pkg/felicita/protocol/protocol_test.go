@@ -0,0 +1,200 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+var validFrames = map[string]struct {
+	data     []byte
+	expected Frame
+}{
+	"positive grams, buzzer on": {
+		data: []byte("\x01\x02+001234g \x00\x00\x00\x22\x9a\r\n"),
+		expected: Frame{
+			Weight:           12.34,
+			Unit:             scale.UnitGrams,
+			IsBuzzingOnTouch: true,
+			BatteryLevelRaw:  0x9a,
+		},
+	},
+	"negative ounces, buzzer off": {
+		data: []byte("\x01\x02-000150oz\x01\x02\x03\x20\x81\r\n"),
+		expected: Frame{
+			Weight:          -1.5,
+			Negative:        true,
+			Unit:            scale.UnitOz,
+			BatteryLevelRaw: 0x81,
+			Reserved:        [3]byte{0x01, 0x02, 0x03},
+		},
+	},
+	"negative zero": {
+		data: []byte("\x01\x02-000000g \x00\x00\x00\x20\x90\r\n"),
+		expected: Frame{
+			Negative:        true,
+			Unit:            scale.UnitGrams,
+			BatteryLevelRaw: 0x90,
+		},
+	},
+}
+
+func TestDecode(t *testing.T) {
+	for name, cs := range validFrames {
+		t.Run(name, func(t *testing.T) {
+			frame, err := Decode(cs.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if frame != cs.expected {
+				t.Fatalf("unexpected frame, want %+v, have %+v", cs.expected, frame)
+			}
+		})
+	}
+}
+
+func TestDecodeTolerant(t *testing.T) {
+
+	// In the absence of captured frames from all firmware variants, only the length and
+	// the weight are validated (i.e. deviations in any other field are tolerated)
+	for name, cs := range map[string]struct {
+		data     []byte
+		expected Frame
+	}{
+		"other header": {
+			data:     []byte("\x01\x03+001234g \x00\x00\x00\x22\x9a\r\n"),
+			expected: Frame{Weight: 12.34, Unit: scale.UnitGrams, IsBuzzingOnTouch: true, BatteryLevelRaw: 0x9a},
+		},
+		"other terminator": {
+			data:     []byte("\x01\x02+001234g \x00\x00\x00\x22\x9a\n\r"),
+			expected: Frame{Weight: 12.34, Unit: scale.UnitGrams, IsBuzzingOnTouch: true, BatteryLevelRaw: 0x9a},
+		},
+		"unknown unit": {
+			data:     []byte("\x01\x02+001234lb\x00\x00\x00\x22\x9a\r\n"),
+			expected: Frame{Weight: 12.34, Unit: scale.UnitUnknown, IsBuzzingOnTouch: true, BatteryLevelRaw: 0x9a},
+		},
+		"unit with garbage": {
+			data:     []byte("\x01\x02+001234\x00\x00\x00\x00\x00\x22\x9a\r\n"),
+			expected: Frame{Weight: 12.34, Unit: scale.UnitUnknown, IsBuzzingOnTouch: true, BatteryLevelRaw: 0x9a},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			frame, err := Decode(cs.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if frame != cs.expected {
+				t.Fatalf("unexpected frame, want %+v, have %+v", cs.expected, frame)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for name, cs := range map[string]struct {
+		data           []byte
+		expectedErr    error
+		expectedOffset int
+	}{
+		"empty":         {nil, ErrInvalidLength, 0},
+		"too short":     {[]byte("\x01\x02+001234g \x00\x00\x00\x22\x9a\r"), ErrInvalidLength, 17},
+		"too long":      {[]byte("\x01\x02+001234g \x00\x00\x00\x22\x9a\r\n\x00"), ErrInvalidLength, 19},
+		"invalid sign":  {[]byte("\x01\x02 001234g \x00\x00\x00\x22\x9a\r\n"), ErrInvalidSign, 2},
+		"non-numeric":   {[]byte("\x01\x02+0012x4g \x00\x00\x00\x22\x9a\r\n"), ErrInvalidWeight, 7},
+		"embedded sign": {[]byte("\x01\x02+-01234g \x00\x00\x00\x22\x9a\r\n"), ErrInvalidWeight, 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(cs.data)
+			if !errors.Is(err, cs.expectedErr) {
+				t.Fatalf("unexpected error, want %v, have %v", cs.expectedErr, err)
+			}
+			var decErr *DecodeError
+			if !errors.As(err, &decErr) {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			if decErr.Offset != cs.expectedOffset {
+				t.Fatalf("unexpected error offset, want %d, have %d", cs.expectedOffset, decErr.Offset)
+			}
+		})
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	for name, cs := range validFrames {
+		t.Run(name, func(t *testing.T) {
+			if encoded := cs.expected.Encode(); string(encoded) != string(cs.data) {
+				t.Fatalf("unexpected encoding, want % x, have % x", cs.data, encoded)
+			}
+		})
+	}
+}
+
+func TestFramePrecision(t *testing.T) {
+	for weight, expected := range map[float64]scale.Precision{
+		0.:      scale.PrecisionUnknown,
+		12.3:    scale.PrecisionUnknown,
+		-12.3:   scale.PrecisionUnknown,
+		12.34:   scale.PrecisionHigh,
+		-0.01:   scale.PrecisionHigh,
+		1000.05: scale.PrecisionHigh,
+	} {
+		if precision := (Frame{Weight: weight}).Precision(); precision != expected {
+			t.Fatalf("unexpected precision for weight %v, want %v, have %v", weight, expected, precision)
+		}
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	for _, cmd := range []Command{CmdStartTimer, CmdStopTimer, CmdResetTimer, CmdToggleBuzzer, CmdTogglePrecision, CmdTare, CmdToggleUnit} {
+		if encoded := cmd.Encode(); len(encoded) != 1 || encoded[0] != byte(cmd) {
+			t.Fatalf("unexpected encoding for command %s: % x", cmd, encoded)
+		}
+	}
+}
+
+func TestParseBatteryLevel(t *testing.T) {
+	for raw, expected := range map[byte]float64{
+		0:   0.,
+		128: 0.,
+		129: 0.,
+		158: 1.,
+		200: 1.,
+		143: 0.48,
+	} {
+		if level := ParseBatteryLevel(raw); level != expected {
+			t.Fatalf("unexpected battery level for %d, want %v, have %v", raw, expected, level)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, cs := range validFrames {
+		f.Add(cs.data)
+	}
+	f.Add([]byte("\x01\x02+999999oz\xff\xff\xff\xff\xff\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := Decode(data)
+		if err != nil {
+			var decErr *DecodeError
+			if !errors.As(err, &decErr) {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			return
+		}
+
+		// Any successfully decoded frame must survive an encode / decode round trip
+		reencoded := frame.Encode()
+		if len(reencoded) != FrameLen {
+			t.Fatalf("unexpected length of re-encoded frame: %d", len(reencoded))
+		}
+		decoded, err := Decode(reencoded)
+		if err != nil {
+			t.Fatalf("failed to decode re-encoded frame % x: %s", reencoded, err)
+		}
+		if decoded != frame {
+			t.Fatalf("round trip mismatch, want %+v, have %+v", frame, decoded)
+		}
+	})
+}
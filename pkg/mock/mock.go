@@ -1,16 +1,26 @@
 package mock
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/fako1024/btscale/pkg/scale"
-	"github.com/fatih/stopwatch"
 )
 
 const (
 	defaultDeviceName = "Mock Scale"
+	defaultFirmware   = "1.0.0"
 	btSettleDelay     = 250 * time.Millisecond
+
+	// Parameters of the simulated signal strength (random walk within bounds)
+	rssiInterval = time.Second
+	rssiInitial  = -60
+	rssiMin      = -95
+	rssiMax      = -40
+	rssiMaxStep  = 3
 )
 
 // Mock denotes a Mock bluetooth scale
@@ -20,21 +30,30 @@ type Mock struct {
 	isBuzzingOnTouch bool
 	isHighPrecision  bool
 	unit             scale.Unit
+	canonicalUnit    scale.Unit
+	filter           scale.Pipeline
+
+	lastData   *scale.DataPoint
+	tareOffset *scale.DataPoint
 
-	timer *stopwatch.Stopwatch
+	timer scale.Stopwatch
 
 	deviceName string
 
-	stateChangeHandler func(status scale.ConnectionStatus)
-	stateChangeChan    chan scale.ConnectionStatus
+	doneChan chan struct{}
+
+	closeOnce sync.Once
+	ready     scale.ReadySignal
+	link      scale.LinkMonitor
+	broker    scale.Broker
+	stability scale.StabilityDetector
 
-	dataHandler func(data scale.DataPoint)
-	dataChan    chan scale.DataPoint
-	doneChan    chan struct{}
+	mu     sync.RWMutex
+	buzzMu sync.Mutex
 }
 
-// New instantiates a new Mock struct
-func New() (*Mock, error) {
+// New instantiates a new Mock struct, executing functional options, if any
+func New(options ...func(*Mock)) (*Mock, error) {
 
 	// Initialize a new instance of a Mock scale
 	f := &Mock{
@@ -42,56 +61,159 @@ func New() (*Mock, error) {
 		doneChan:   make(chan struct{}),
 	}
 
-	return f, f.subscribe()
+	// Execute functional options (if any), see options.go for implementation
+	for _, option := range options {
+		option(f)
+	}
+	if f.canonicalUnit != "" && !f.canonicalUnit.IsValid() {
+		return nil, fmt.Errorf("invalid canonical unit: %s", f.canonicalUnit)
+	}
+
+	// Release all resources if the scale cannot be initialized
+	if err := f.subscribe(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
 }
 
 // ConnectionStatus returns the current status of the bluetooth device
 func (f *Mock) ConnectionStatus() scale.ConnectionStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.connectionStatus
 }
 
+// WaitConnected blocks until the scale is connected and usable (which the mock scale
+// always is until it is closed)
+func (f *Mock) WaitConnected(ctx context.Context) error {
+	return f.ready.Wait(ctx)
+}
+
+// DeviceInfo returns (static) device information of the mock scale
+func (f *Mock) DeviceInfo() scale.DeviceInfo {
+	return scale.DeviceInfo{
+		ManufacturerName: "btscale",
+		ModelNumber:      f.deviceName,
+		FirmwareRevision: defaultFirmware,
+	}
+}
+
+// IsRSSISupported returns if the signal strength can be obtained (always true for the
+// simulated signal)
+func (f *Mock) IsRSSISupported() bool {
+	return f.link.IsRSSISupported()
+}
+
+// RSSI returns the most recent (simulated) signal strength (in dBm)
+func (f *Mock) RSSI() int {
+	return f.link.RSSI()
+}
+
+// RSSIHistory returns the recent (simulated) signal strength samples (oldest first)
+func (f *Mock) RSSIHistory() []scale.RSSISample {
+	return f.link.RSSIHistory()
+}
+
+// FrameStats returns statistics on the data points emitted so far
+func (f *Mock) FrameStats() scale.FrameStats {
+	return f.link.FrameStats()
+}
+
+// SetRSSI records a signal strength sample (in dBm), e.g. to simulate link degradation
+func (f *Mock) SetRSSI(rssi int) {
+	f.link.AddRSSI(rssi)
+}
+
+// DeliveryStats returns the number of data points / state changes dropped so far
+// (according to the delivery policy)
+func (f *Mock) DeliveryStats() scale.DeliveryStats {
+	return f.broker.Stats()
+}
+
 // IsBuzzingOnTouch returns if the scale buzzer is turned on or not (on user interaction)
 func (f *Mock) IsBuzzingOnTouch() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.isBuzzingOnTouch
 }
 
 // BatteryLevel returns the current battery level
 func (f *Mock) BatteryLevel() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return float64(f.batteryLevel)
 }
 
 // BatteryLevelRaw returns the current battery level in its raw form
 func (f *Mock) BatteryLevelRaw() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return int(f.batteryLevel)
 }
 
 // Unit returns the current weight unit
 func (f *Mock) Unit() scale.Unit {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	return f.unit
 }
 
+// Subscribe registers a new subscription to the data points and state changes of the
+// scale, executing functional options, if any
+func (f *Mock) Subscribe(options ...func(*scale.Subscription)) *scale.Subscription {
+	return f.broker.Subscribe(options...)
+}
+
+// IsStable returns if the weight is currently stable
+func (f *Mock) IsStable() bool {
+	return f.stability.IsStable()
+}
+
+// SetStabilityChangeHandler defines a handler function that is called once the weight
+// becomes stable / unstable
+func (f *Mock) SetStabilityChangeHandler(fn func(event scale.StabilityEvent)) {
+	f.stability.SetHandler(fn)
+}
+
 // SetStateChangeHandler defines a handler function that is called upon state change
 func (f *Mock) SetStateChangeHandler(fn func(status scale.ConnectionStatus)) {
-	f.stateChangeHandler = fn
+	f.broker.SetStateChangeHandler(fn)
 }
 
-// SetStateChangeChannel defines a handler function that is called upon state change
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the
+// new state change if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
 func (f *Mock) SetStateChangeChannel(ch chan scale.ConnectionStatus) {
-	f.stateChangeChan = ch
+	f.broker.SetStateChangeChannel(ch)
 }
 
 // SetDataHandler defines a handler function that is called upon retrieval of data
 func (f *Mock) SetDataHandler(fn func(data scale.DataPoint)) {
-	f.dataHandler = fn
+	f.broker.SetDataHandler(fn)
 }
 
-// SetDataChannel defines a handler function that is called upon retrieval of data
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
 func (f *Mock) SetDataChannel(ch chan scale.DataPoint) {
-	f.dataChan = ch
+	f.broker.SetDataChannel(ch)
 }
 
-// Tare tares the scale
+// Tare tares the scale (i.e. the weights of subsequently emitted data points are reported
+// relative to the weight of the last emitted one)
 func (f *Mock) Tare() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tareOffset = f.lastData
+
 	return nil
 }
 
@@ -102,6 +224,10 @@ func (f *Mock) Buzz(n int) (err error) {
 		return fmt.Errorf("invalid number of beeps requested: %d", n)
 	}
 
+	// Ensure that concurrent buzz requests do not interfere with each other
+	f.buzzMu.Lock()
+	defer f.buzzMu.Unlock()
+
 	// If the buzzer is currently turned on, shortly turn it off and ensure it is
 	// re-enabled at the end of the function. In this case, n is reduced by one since
 	// enabling the buzzer will cause yet another buzz at the end
@@ -137,6 +263,9 @@ func (f *Mock) Buzz(n int) (err error) {
 
 // ToggleBuzzingOnTouch turns the buzzer (on user interaction) on / off
 func (f *Mock) ToggleBuzzingOnTouch() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.isBuzzingOnTouch = !f.isBuzzingOnTouch
 
 	return nil
@@ -144,6 +273,8 @@ func (f *Mock) ToggleBuzzingOnTouch() error {
 
 // SetUnit changes the weight unit from / to g / oz
 func (f *Mock) SetUnit(unit scale.Unit) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
 	// Check if the unit is already set to the expected value
 	if f.unit != scale.UnitUnknown && f.unit == unit {
@@ -155,8 +286,37 @@ func (f *Mock) SetUnit(unit scale.Unit) error {
 	return nil
 }
 
+// Precision returns the current weight precision
+func (f *Mock) Precision() scale.Precision {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.isHighPrecision {
+		return scale.PrecisionHigh
+	}
+
+	return scale.PrecisionLow
+}
+
+// SetPrecision changes the weight precision from / to 0.1 / 0.01
+func (f *Mock) SetPrecision(p scale.Precision) error {
+	if p != scale.PrecisionLow && p != scale.PrecisionHigh {
+		return fmt.Errorf("invalid precision requested: %v", p)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.isHighPrecision = p == scale.PrecisionHigh
+
+	return nil
+}
+
 // TogglePrecision toggles the weight precision between 0.1 and 0.01
 func (f *Mock) TogglePrecision() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.isHighPrecision = !f.isHighPrecision
 
 	return nil
@@ -164,45 +324,76 @@ func (f *Mock) TogglePrecision() error {
 
 // StartTimer starts the timer / stopwatch
 func (f *Mock) StartTimer() error {
-	if f.timer == nil {
-		f.timer = stopwatch.Start(0)
-	} else {
-		f.timer.Start(0)
-	}
+	f.timer.Start()
 
 	return nil
 }
 
 // StopTimer stops the timer / stopwatch
 func (f *Mock) StopTimer() error {
-	if f.timer != nil {
-		f.timer.Stop()
-	}
+	f.timer.Stop()
 
 	return nil
 }
 
 // ResetTimer resets the timer / stopwatch
 func (f *Mock) ResetTimer() error {
-	if f.timer != nil {
-		f.timer.Reset()
-	}
+	f.timer.Reset()
 
 	return nil
 }
 
 // ElapsedTime returns the current timer value
 func (f *Mock) ElapsedTime() time.Duration {
-	if f.timer != nil {
-		return f.timer.ElapsedTime()
+	return f.timer.ElapsedTime()
+}
+
+// Emit simulates the retrieval of a data point, passing it on to all consumers (if any)
+func (f *Mock) Emit(data scale.DataPoint) {
+	f.link.CountReceived()
+
+	f.mu.Lock()
+	f.unit = data.Unit
+	raw := data
+	f.lastData = &raw
+	if f.tareOffset != nil {
+		if offset, err := f.tareOffset.In(data.Unit); err == nil {
+			data.Weight -= offset.Weight
+		}
+	}
+	f.mu.Unlock()
+
+	// Convert data point to the canonical unit (if requested), dropping it if its unit
+	// is unknown
+	if f.canonicalUnit != "" {
+		converted, err := data.In(f.canonicalUnit)
+		if err != nil {
+			return
+		}
+		data = converted
+	}
+
+	// Filter data point (if filters are installed), dropping it if discarded by any filter
+	filtered, ok := f.filter.Filter(data)
+	if !ok {
+		return
 	}
+	data = filtered
 
-	return 0
+	// Annotate data point with the stability of the weight
+	data = f.stability.Process(data)
+
+	// Distribute data point to all consumers
+	f.broker.PublishData(data)
 }
 
-// Close terminates the connection to the device
+// Close terminates the connection to the device (subsequent calls are a no-op)
 func (f *Mock) Close() error {
-	close(f.doneChan)
+	f.closeOnce.Do(func() {
+		f.ready.Close()
+		f.broker.Close()
+		close(f.doneChan)
+	})
 
 	return nil
 }
@@ -210,6 +401,32 @@ func (f *Mock) Close() error {
 ////////////////////////////////////////////////////////////////////////////////
 
 func (f *Mock) subscribe() error {
+	f.link.AddRSSI(rssiInitial)
+	go f.simulateRSSI()
+
+	f.ready.Set(true)
 
 	return nil
 }
+
+// simulateRSSI continuously varies the signal strength until the scale is closed
+func (f *Mock) simulateRSSI() {
+	ticker := time.NewTicker(rssiInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rssi := f.link.RSSI() + rand.Intn(2*rssiMaxStep+1) - rssiMaxStep
+			if rssi < rssiMin {
+				rssi = rssiMin
+			}
+			if rssi > rssiMax {
+				rssi = rssiMax
+			}
+			f.link.AddRSSI(rssi)
+		case <-f.doneChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+const testIterations = 100
+
+func TestConcurrentAccess(t *testing.T) {
+
+	// Drain data and state channels while they are continuously replaced
+	done := make(chan struct{})
+	defer close(done)
+	dataChan := make(chan scale.DataPoint, 16)
+	stateChan := make(chan scale.ConnectionStatus, 16)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-dataChan:
+			case <-stateChan:
+			}
+		}
+	}()
+
+	m, err := New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < testIterations; i++ {
+				if err := fn(); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Getters
+	run(func() error {
+		_ = m.ConnectionStatus()
+		_ = m.BatteryLevel()
+		_ = m.BatteryLevelRaw()
+		_ = m.IsBuzzingOnTouch()
+		_ = m.Unit()
+		_ = m.Precision()
+		_ = m.ElapsedTime()
+		_ = m.RSSI()
+		_ = m.RSSIHistory()
+		_ = m.FrameStats()
+		return nil
+	})
+
+	// Handlers, channels and data
+	run(func() error {
+		m.SetDataHandler(func(data scale.DataPoint) {})
+		m.SetDataChannel(dataChan)
+		m.SetStateChangeHandler(func(status scale.ConnectionStatus) {})
+		m.SetStateChangeChannel(stateChan)
+		return nil
+	})
+	run(func() error {
+		m.Emit(scale.DataPoint{
+			TimeStamp: time.Now(),
+			Weight:    10.,
+			Unit:      scale.UnitGrams,
+		})
+		return nil
+	})
+
+	// Commands
+	run(m.Tare)
+	run(m.TogglePrecision)
+	run(func() error { return m.SetPrecision(scale.PrecisionHigh) })
+	run(func() error { return m.SetUnit(scale.UnitOz) })
+	run(func() error {
+		if err := m.StartTimer(); err != nil {
+			return err
+		}
+		if err := m.StopTimer(); err != nil {
+			return err
+		}
+		return m.ResetTimer()
+	})
+
+	// Concurrent buzz requests must restore the buzzer state
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Buzz(1); err != nil {
+				t.Errorf("unexpected error during buzz: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if m.IsBuzzingOnTouch() {
+		t.Fatalf("buzzer state was not restored")
+	}
+}
+
+func TestWaitConnected(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	var _ scale.ConnectionWaiter = m
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.WaitConnected(ctx); err != nil {
+		t.Fatalf("unexpected error waiting for connection: %s", err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("failed to close scale: %s", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("unexpected error closing scale twice: %s", err)
+	}
+	if err := m.WaitConnected(ctx); !errors.Is(err, scale.ErrClosed) {
+		t.Fatalf("unexpected error waiting for connection, want %v, have %v", scale.ErrClosed, err)
+	}
+}
+
+func TestLinkQuality(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+	var _ scale.LinkQuality = m
+
+	if !m.IsRSSISupported() {
+		t.Fatalf("unexpectedly unsupported RSSI")
+	}
+	if rssi := m.RSSI(); rssi != rssiInitial {
+		t.Fatalf("unexpected initial RSSI, want %d, have %d", rssiInitial, rssi)
+	}
+
+	m.SetRSSI(-90)
+	history := m.RSSIHistory()
+	if len(history) < 2 || history[len(history)-1].RSSI != -90 {
+		t.Fatalf("unexpected RSSI history: %v", history)
+	}
+
+	m.Emit(scale.DataPoint{
+		TimeStamp: time.Now(),
+		Weight:    10.,
+		Unit:      scale.UnitGrams,
+	})
+	if stats := m.FrameStats(); stats != (scale.FrameStats{Received: 1}) {
+		t.Fatalf("unexpected frame statistics: %+v", stats)
+	}
+}
+
+func TestCanonicalUnit(t *testing.T) {
+	if _, err := New(WithCanonicalUnit(scale.UnitUnknown)); err == nil {
+		t.Fatalf("unexpected success instantiating scale with unknown canonical unit")
+	}
+
+	m, err := New(WithCanonicalUnit(scale.UnitGrams))
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	var data []scale.DataPoint
+	m.SetDataHandler(func(dp scale.DataPoint) {
+		data = append(data, dp)
+	})
+
+	for _, unit := range []scale.Unit{scale.UnitGrams, scale.UnitUnknown, scale.UnitOz} {
+		m.Emit(scale.DataPoint{
+			TimeStamp: time.Now(),
+			Weight:    2.,
+			Unit:      unit,
+		})
+	}
+
+	// Data points in an unknown unit must be dropped, all others converted
+	if len(data) != 2 {
+		t.Fatalf("unexpected number of data points: %d", len(data))
+	}
+	if data[0].Unit != scale.UnitGrams || data[0].Weight != 2. {
+		t.Fatalf("unexpected data point: %+v", data[0])
+	}
+	if data[1].Unit != scale.UnitGrams || math.Abs(data[1].Weight-56.69904625) > 1e-9 {
+		t.Fatalf("unexpected converted data point: %+v", data[1])
+	}
+
+	// The display unit of the scale remains unaffected
+	if unit := m.Unit(); unit != scale.UnitOz {
+		t.Fatalf("unexpected unit: %s", unit)
+	}
+}
+
+func TestAutoTare(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	var events []scale.AutoTareEvent
+	at := scale.AutoTare{
+		Scale:    m,
+		Cooldown: 30 * time.Second,
+	}
+	at.SetHandler(func(event scale.AutoTareEvent) {
+		events = append(events, event)
+	})
+	m.SetDataHandler(at.Process)
+
+	var (
+		start  = time.Now()
+		offset time.Duration
+	)
+	emit := func(weight float64, duration time.Duration) {
+		for end := offset + duration; offset < end; offset += 100 * time.Millisecond {
+			m.Emit(scale.DataPoint{
+				TimeStamp: start.Add(offset),
+				Weight:    weight,
+				Unit:      scale.UnitGrams,
+			})
+		}
+	}
+
+	// Placing a cup on the empty scale must tare it
+	emit(0., 2*time.Second)
+	emit(250., 2*time.Second)
+	if len(events) != 1 || !events[0].Confirmed || events[0].Container != 250. {
+		t.Fatalf("unexpected auto-tare events: %+v", events)
+	}
+	if container, ok := at.Container(); !ok || container.Container != 250. {
+		t.Fatalf("unexpected container: %+v", container)
+	}
+
+	// Brewing into the cup must not tare the scale again
+	for weight := 250.; weight < 286.; weight += 0.5 {
+		emit(weight, 100*time.Millisecond)
+	}
+	emit(286., 2*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("unexpected auto-tare events while brewing: %+v", events)
+	}
+
+	// Removing the cup empties the platform, but placing it again within the cooldown
+	// period must not tare the scale
+	emit(0., 2*time.Second)
+	if _, ok := at.Container(); ok {
+		t.Fatalf("unexpected container after removing it")
+	}
+	emit(250., 2*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("unexpected auto-tare events within cooldown period: %+v", events)
+	}
+
+	// After the cooldown period, placing a cup must tare the scale again
+	emit(0., 30*time.Second)
+	emit(300., 2*time.Second)
+	if len(events) != 2 || !events[1].Confirmed || events[1].Container != 300. {
+		t.Fatalf("unexpected auto-tare events after cooldown period: %+v", events)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	m, err := New(WithFilter(&scale.OutlierFilter{MaxSlope: 10.}, &scale.MedianFilter{Size: 3}))
+	if err != nil {
+		t.Fatalf("failed to instantiate scale: %s", err)
+	}
+	defer m.Close()
+
+	var data []scale.DataPoint
+	m.SetDataHandler(func(dp scale.DataPoint) {
+		data = append(data, dp)
+	})
+
+	// The spike must be discarded, all other data points must be delivered filtered
+	start := time.Now()
+	for i, weight := range []float64{10., 10.4, 50., 10.2} {
+		m.Emit(scale.DataPoint{
+			TimeStamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+			Weight:    weight,
+			Unit:      scale.UnitGrams,
+		})
+	}
+	if len(data) != 3 {
+		t.Fatalf("unexpected number of data points: %d", len(data))
+	}
+	if last := data[2]; last.Weight != 10.2 || last.RawWeight != 10.2 {
+		t.Fatalf("unexpected filtered data point: %+v", last)
+	}
+	if second := data[1]; second.Weight != 10.2 || second.RawWeight != 10.4 {
+		t.Fatalf("unexpected filtered data point: %+v", second)
+	}
+}
@@ -0,0 +1,44 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+// WithDeliveryPolicy sets the behavior when putting data points / state changes on a
+// channel whose consumer does not keep up. It applies to the channels set via
+// SetDataChannel() / SetStateChangeChannel() and serves as default for subscriptions (by
+// default, delivery never blocks, see scale.DeliveryDefault)
+func WithDeliveryPolicy(data, state scale.DeliveryPolicy) func(*Mock) {
+	return func(f *Mock) {
+		f.broker.DataPolicy = data
+		f.broker.StatePolicy = state
+	}
+}
+
+// WithStabilityDetection sets the time window and the tolerance (in grams) used to determine
+// if the weight is stable (by default, a deviation of up to 0.2g within 1s)
+func WithStabilityDetection(window time.Duration, tolerance float64) func(*Mock) {
+	return func(f *Mock) {
+		f.stability.Window = window
+		f.stability.Tolerance = tolerance
+	}
+}
+
+// WithCanonicalUnit ensures that all data points are delivered in a certain unit, regardless
+// of the unit selected on the scale (data points in an unknown unit are dropped)
+func WithCanonicalUnit(unit scale.Unit) func(*Mock) {
+	return func(f *Mock) {
+		f.canonicalUnit = unit
+	}
+}
+
+// WithFilter installs a filter pipeline for the weight readings, i.e. all data points
+// are passed through the given filters in order before being delivered (the unfiltered
+// weight being retained in their RawWeight field)
+func WithFilter(filters ...scale.Filter) func(*Mock) {
+	return func(f *Mock) {
+		f.filter = append(f.filter, filters...)
+	}
+}
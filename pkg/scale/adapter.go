@@ -0,0 +1,413 @@
+package scale
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/fako1024/gatt"
+)
+
+// ErrPeripheralInUse denotes that a peripheral is already connected via another virtual
+// device of an Adapter
+var ErrPeripheralInUse = errors.New("peripheral in use by another device")
+
+// Adapter denotes a bluetooth adapter shared by multiple scales. It multiplexes a single
+// gatt.Device over several driver instances (each using its own virtual device obtained
+// via Device()), routing peripheral callbacks to the instance that initiated the
+// connection to the respective peripheral (a peripheral is only reported to other
+// instances again once it has been disconnected)
+type Adapter struct {
+	btDevice gatt.Device
+
+	devices     map[*AdapterDevice]struct{}
+	peripherals map[string]*AdapterDevice
+	state       gatt.State
+	isInit      bool
+	isScanning  bool
+
+	mu     sync.Mutex
+	scanMu sync.Mutex
+}
+
+// NewAdapter instantiates a new Adapter supporting up to maxConnections simultaneous
+// connections (i.e. scales)
+func NewAdapter(maxConnections int) (*Adapter, error) {
+	btDevice, err := gatt.NewDevice(adapterBTClientOptions(maxConnections)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAdapterFromDevice(btDevice), nil
+}
+
+// NewAdapterFromDevice instantiates a new Adapter based on an existing bluetooth device
+func NewAdapterFromDevice(btDevice gatt.Device) *Adapter {
+	a := &Adapter{
+		btDevice:    btDevice,
+		devices:     make(map[*AdapterDevice]struct{}),
+		peripherals: make(map[string]*AdapterDevice),
+	}
+
+	btDevice.Handle(
+		gatt.AddPeripheralDiscovered(a.onPeriphDiscovered),
+		gatt.AddPeripheralConnected(a.onPeriphConnected),
+		gatt.AddPeripheralDisconnected(a.onPeriphDisconnected),
+	)
+
+	return a
+}
+
+// Device returns a new virtual device to be passed on to a single driver instance (e.g.
+// via its WithDevice() option)
+func (a *Adapter) Device() *AdapterDevice {
+	d := &AdapterDevice{adapter: a}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.devices[d] = struct{}{}
+
+	return d
+}
+
+// Close closes the shared bluetooth device (and hence terminates all connections)
+func (a *Adapter) Close() error {
+	return a.btDevice.Close()
+}
+
+// HandlePeripherals registers peripheral callbacks (if non-nil) on a bluetooth device. If
+// the device is shared via an Adapter, the callbacks are only invoked for peripherals
+// discovered while scanning / connected via this device
+func HandlePeripherals(btDevice gatt.Device, discovered func(gatt.Peripheral, *gatt.Advertisement, int), connected, disconnected func(gatt.Peripheral, error)) {
+	if d, ok := btDevice.(*AdapterDevice); ok {
+		d.adapter.mu.Lock()
+		defer d.adapter.mu.Unlock()
+
+		d.discovered, d.connected, d.disconnected = discovered, connected, disconnected
+		return
+	}
+
+	if discovered != nil {
+		btDevice.Handle(gatt.AddPeripheralDiscovered(discovered))
+	}
+	if connected != nil {
+		btDevice.Handle(gatt.AddPeripheralConnected(connected))
+	}
+	if disconnected != nil {
+		btDevice.Handle(gatt.AddPeripheralDisconnected(disconnected))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (a *Adapter) init() error {
+	a.mu.Lock()
+	if a.isInit {
+		a.mu.Unlock()
+		return nil
+	}
+	a.isInit = true
+	a.mu.Unlock()
+
+	if err := a.btDevice.Init(a.onStateChanged); err != nil {
+		a.mu.Lock()
+		a.isInit = false
+		a.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// setScanning enables / disables scanning for a virtual device, scanning on the shared
+// device as long as any of the virtual devices is scanning
+func (a *Adapter) setScanning(d *AdapterDevice, enable bool, ss []gatt.UUID, dup bool) error {
+	a.scanMu.Lock()
+	defer a.scanMu.Unlock()
+
+	a.mu.Lock()
+	d.isScanning = enable
+	anyScanning := false
+	for dev := range a.devices {
+		if dev.isScanning {
+			anyScanning = true
+			break
+		}
+	}
+	isScanning := a.isScanning
+	a.mu.Unlock()
+
+	// The shared device is not accessed while holding the lock, since it may invoke
+	// callbacks synchronously
+	var err error
+	if anyScanning && !isScanning {
+		err = a.btDevice.Scan(ss, dup)
+	} else if !anyScanning && isScanning {
+		err = a.btDevice.StopScanning()
+	}
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.isScanning = anyScanning
+	a.mu.Unlock()
+
+	return nil
+}
+
+func (a *Adapter) release(d *AdapterDevice) {
+	a.mu.Lock()
+	d.isScanning = false
+	delete(a.devices, d)
+	for id, dev := range a.peripherals {
+		if dev == d {
+			delete(a.peripherals, id)
+		}
+	}
+	a.mu.Unlock()
+
+	// Trigger an update of the scanning state of the shared device
+	_ = a.setScanning(d, false, nil, false)
+}
+
+// releasePeripheral stops routing callbacks for a peripheral to a virtual device (unless
+// it has been claimed by another one in the meantime)
+func (a *Adapter) releasePeripheral(d *AdapterDevice, id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.peripherals[id] == d {
+		delete(a.peripherals, id)
+	}
+}
+
+func (a *Adapter) onStateChanged(_ gatt.Device, s gatt.State) {
+	a.mu.Lock()
+	a.state = s
+	handlers := make(map[*AdapterDevice]func(gatt.Device, gatt.State))
+	for d := range a.devices {
+		if d.stateChanged != nil {
+			handlers[d] = d.stateChanged
+		}
+	}
+	a.mu.Unlock()
+
+	for d, fn := range handlers {
+		fn(d, s)
+	}
+}
+
+func (a *Adapter) onPeriphDiscovered(p gatt.Peripheral, adv *gatt.Advertisement, rssi int) {
+	a.mu.Lock()
+	handlers := make(map[*AdapterDevice]func(gatt.Peripheral, *gatt.Advertisement, int))
+	for d := range a.devices {
+		if d.isScanning && d.discovered != nil {
+			handlers[d] = d.discovered
+		}
+	}
+	a.mu.Unlock()
+
+	for d, fn := range handlers {
+
+		// Peripherals connected via another virtual device are not reported (preventing
+		// several drivers from connecting to the same peripheral, e.g. if they share a
+		// name). Since handlers may connect synchronously, this is checked for each one
+		a.mu.Lock()
+		owner, ok := a.peripherals[p.ID()]
+		a.mu.Unlock()
+		if ok && owner != d {
+			continue
+		}
+
+		fn(d.wrap(p), adv, rssi)
+	}
+}
+
+func (a *Adapter) onPeriphConnected(p gatt.Peripheral, err error) {
+	a.mu.Lock()
+	d := a.peripherals[p.ID()]
+	a.mu.Unlock()
+
+	// A failed connection attempt releases the peripheral
+	if err != nil && d != nil {
+		a.releasePeripheral(d, p.ID())
+	}
+
+	if d != nil && d.connected != nil {
+		d.connected(d.wrap(p), err)
+	}
+}
+
+func (a *Adapter) onPeriphDisconnected(p gatt.Peripheral, err error) {
+	a.mu.Lock()
+	d := a.peripherals[p.ID()]
+	delete(a.peripherals, p.ID())
+	a.mu.Unlock()
+
+	if d != nil && d.disconnected != nil {
+		d.disconnected(d.wrap(p), err)
+	}
+}
+
+// AdapterDevice denotes a virtual bluetooth device, sharing the underlying device of an
+// Adapter with other virtual devices. It only supports the central role (i.e. scanning
+// for and connecting to peripherals)
+type AdapterDevice struct {
+	adapter *Adapter
+
+	stateChanged func(gatt.Device, gatt.State)
+	discovered   func(gatt.Peripheral, *gatt.Advertisement, int)
+	connected    func(gatt.Peripheral, error)
+	disconnected func(gatt.Peripheral, error)
+	isScanning   bool
+}
+
+// Init registers the state change handler and initializes the shared device (if required)
+func (d *AdapterDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	d.adapter.mu.Lock()
+	d.stateChanged = stateChanged
+	isInit, state := d.adapter.isInit, d.adapter.state
+	d.adapter.mu.Unlock()
+
+	// If the shared device has already been initialized, report its current state
+	if isInit && state != gatt.StateUnknown {
+		go stateChanged(d, state)
+		return nil
+	}
+
+	return d.adapter.init()
+}
+
+// Advertise is not supported by a virtual device
+func (d *AdapterDevice) Advertise(a *gatt.AdvPacket) error {
+	return ErrNotSupported
+}
+
+// AdvertiseNameAndServices is not supported by a virtual device
+func (d *AdapterDevice) AdvertiseNameAndServices(name string, ss []gatt.UUID) error {
+	return ErrNotSupported
+}
+
+// AdvertiseIBeaconData is not supported by a virtual device
+func (d *AdapterDevice) AdvertiseIBeaconData(b []byte) error {
+	return ErrNotSupported
+}
+
+// AdvertiseIBeacon is not supported by a virtual device
+func (d *AdapterDevice) AdvertiseIBeacon(u gatt.UUID, major, minor uint16, pwr int8) error {
+	return ErrNotSupported
+}
+
+// StopAdvertising is a no-op for a virtual device
+func (d *AdapterDevice) StopAdvertising() error {
+	return nil
+}
+
+// RemoveAllServices is a no-op for a virtual device
+func (d *AdapterDevice) RemoveAllServices() error {
+	return nil
+}
+
+// AddService is not supported by a virtual device
+func (d *AdapterDevice) AddService(s *gatt.Service) error {
+	return ErrNotSupported
+}
+
+// SetServices is not supported by a virtual device
+func (d *AdapterDevice) SetServices(ss []*gatt.Service) error {
+	return ErrNotSupported
+}
+
+// Scan starts scanning for peripherals (the shared device keeps scanning as long as any
+// of its virtual devices does)
+func (d *AdapterDevice) Scan(ss []gatt.UUID, dup bool) error {
+	return d.adapter.setScanning(d, true, ss, dup)
+}
+
+// StopScanning stops scanning for peripherals
+func (d *AdapterDevice) StopScanning() error {
+	return d.adapter.setScanning(d, false, nil, false)
+}
+
+// Connect connects to a peripheral, routing all subsequent callbacks for it to this device
+// (until it has been disconnected). Connecting to a peripheral that is connected via another
+// virtual device fails with ErrPeripheralInUse
+func (d *AdapterDevice) Connect(p gatt.Peripheral) error {
+	p = unwrap(p)
+
+	d.adapter.mu.Lock()
+	if owner, ok := d.adapter.peripherals[p.ID()]; ok && owner != d {
+		d.adapter.mu.Unlock()
+		return ErrPeripheralInUse
+	}
+	d.adapter.peripherals[p.ID()] = d
+	d.adapter.mu.Unlock()
+
+	if err := d.adapter.btDevice.Connect(p); err != nil {
+		d.adapter.releasePeripheral(d, p.ID())
+		return err
+	}
+
+	return nil
+}
+
+// CancelConnection disconnects a peripheral
+func (d *AdapterDevice) CancelConnection(p gatt.Peripheral) error {
+	return d.adapter.btDevice.CancelConnection(unwrap(p))
+}
+
+// Handle registers the specified handlers on the shared device (i.e. they are called for
+// all peripherals, use HandlePeripherals() to register routed callbacks instead)
+func (d *AdapterDevice) Handle(h ...gatt.Handler) {
+	d.adapter.btDevice.Handle(h...)
+}
+
+// Close releases the virtual device from the adapter (without closing the shared device)
+func (d *AdapterDevice) Close() error {
+	d.adapter.release(d)
+
+	d.adapter.mu.Lock()
+	stateChanged := d.stateChanged
+	d.adapter.mu.Unlock()
+
+	if stateChanged != nil {
+		stateChanged(d, gatt.StatePoweredOff)
+	}
+
+	return nil
+}
+
+// Option is not supported by a virtual device (options have to be set on the shared device)
+func (d *AdapterDevice) Option(o ...gatt.Option) error {
+	return ErrNotSupported
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// adapterPeripheral denotes a peripheral handed to a driver via a virtual device, ensuring
+// that any interaction with its device is routed via the virtual device
+type adapterPeripheral struct {
+	gatt.Peripheral
+	device *AdapterDevice
+}
+
+// Device returns the virtual device the peripheral was discovered / connected by
+func (p *adapterPeripheral) Device() gatt.Device {
+	return p.device
+}
+
+func (d *AdapterDevice) wrap(p gatt.Peripheral) gatt.Peripheral {
+	return &adapterPeripheral{
+		Peripheral: p,
+		device:     d,
+	}
+}
+
+func unwrap(p gatt.Peripheral) gatt.Peripheral {
+	if ap, ok := p.(*adapterPeripheral); ok {
+		return ap.Peripheral
+	}
+
+	return p
+}
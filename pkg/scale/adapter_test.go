@@ -0,0 +1,271 @@
+package scale
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/fako1024/gatt"
+)
+
+type fakeDevice struct {
+	gatt.Device
+
+	isScanning bool
+	connected  []string
+	mu         sync.Mutex
+}
+
+func (d *fakeDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	stateChanged(d, gatt.StatePoweredOn)
+	return nil
+}
+
+func (d *fakeDevice) Handle(h ...gatt.Handler) {}
+
+func (d *fakeDevice) Scan(ss []gatt.UUID, dup bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.isScanning = true
+	return nil
+}
+
+func (d *fakeDevice) StopScanning() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.isScanning = false
+	return nil
+}
+
+func (d *fakeDevice) Connect(p gatt.Peripheral) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := p.(*fakePeripheral); !ok {
+		panic("shared device received a wrapped peripheral")
+	}
+	d.connected = append(d.connected, p.ID())
+	return nil
+}
+
+func (d *fakeDevice) scanning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.isScanning
+}
+
+type fakePeripheral struct {
+	gatt.Peripheral
+	id   string
+	name string
+}
+
+func (p *fakePeripheral) ID() string { return p.id }
+func (p *fakePeripheral) Name() string {
+	if p.name != "" {
+		return p.name
+	}
+	return p.id
+}
+
+// testClient mimics a driver connecting to the first discovered peripheral with its ID
+// (or its name, if no ID is set)
+type testClient struct {
+	id           string
+	name         string
+	device       *AdapterDevice
+	connected    []string
+	disconnected []string
+	scanning     chan struct{}
+	mu           sync.Mutex
+}
+
+func newTestClient(t *testing.T, a *Adapter, id string) *testClient {
+	return newTestClientByName(t, a, id, "")
+}
+
+func newTestClientByName(t *testing.T, a *Adapter, id, name string) *testClient {
+	c := &testClient{
+		id:       id,
+		name:     name,
+		device:   a.Device(),
+		scanning: make(chan struct{}),
+	}
+
+	HandlePeripherals(c.device, func(p gatt.Peripheral, _ *gatt.Advertisement, _ int) {
+		if (c.id != "" && p.ID() != c.id) || (c.id == "" && p.Name() != c.name) {
+			return
+		}
+		if err := p.Device().StopScanning(); err != nil {
+			t.Errorf("failed to stop scanning: %s", err)
+		}
+		if err := p.Device().Connect(p); err != nil {
+			t.Errorf("failed to connect: %s", err)
+		}
+	}, func(p gatt.Peripheral, _ error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.connected = append(c.connected, p.ID())
+	}, func(p gatt.Peripheral, _ error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.disconnected = append(c.disconnected, p.ID())
+	})
+
+	if err := c.device.Init(func(d gatt.Device, s gatt.State) {
+		if s == gatt.StatePoweredOn {
+			if err := d.Scan(nil, false); err != nil {
+				t.Errorf("failed to scan: %s", err)
+			}
+			close(c.scanning)
+		}
+	}); err != nil {
+		t.Fatalf("failed to initialize device: %s", err)
+	}
+	<-c.scanning
+
+	return c
+}
+
+func TestAdapterRouting(t *testing.T) {
+	btDevice := &fakeDevice{}
+	a := NewAdapterFromDevice(btDevice)
+
+	c1 := newTestClient(t, a, "AA:AA:AA:AA:AA:AA")
+	if !btDevice.scanning() {
+		t.Fatalf("shared device not scanning after initialization of first client")
+	}
+	c2 := newTestClient(t, a, "BB:BB:BB:BB:BB:BB")
+
+	// The first scale is found, the shared device has to continue scanning for the second one
+	p1, p2 := &fakePeripheral{id: c1.id}, &fakePeripheral{id: c2.id}
+	a.onPeriphDiscovered(p1, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p1, nil)
+	if !btDevice.scanning() {
+		t.Fatalf("shared device stopped scanning while second client is still scanning")
+	}
+
+	// Once both scales are found, scanning has to stop
+	a.onPeriphDiscovered(p2, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p2, nil)
+	if btDevice.scanning() {
+		t.Fatalf("shared device still scanning after all clients stopped scanning")
+	}
+
+	// Disconnecting one of the scales must only affect its client
+	a.onPeriphDisconnected(p1, nil)
+
+	for _, cs := range []struct {
+		client       *testClient
+		connected    []string
+		disconnected []string
+	}{
+		{c1, []string{c1.id}, []string{c1.id}},
+		{c2, []string{c2.id}, nil},
+	} {
+		cs.client.mu.Lock()
+		if !equal(cs.client.connected, cs.connected) || !equal(cs.client.disconnected, cs.disconnected) {
+			t.Fatalf("unexpected callbacks for client %s: connected %v, disconnected %v", cs.client.id, cs.client.connected, cs.client.disconnected)
+		}
+		cs.client.mu.Unlock()
+	}
+
+	// Releasing a virtual device must not affect the other one
+	if err := c1.device.Close(); err != nil {
+		t.Fatalf("failed to close virtual device: %s", err)
+	}
+	a.onPeriphDisconnected(p2, nil)
+	c2.mu.Lock()
+	defer c2.mu.Unlock()
+	if !equal(c2.disconnected, []string{c2.id}) {
+		t.Fatalf("unexpected disconnect callbacks for client %s: %v", c2.id, c2.disconnected)
+	}
+}
+
+func TestAdapterSharedName(t *testing.T) {
+	btDevice := &fakeDevice{}
+	a := NewAdapterFromDevice(btDevice)
+
+	// Two scales sharing the same name, connected by name only
+	c1 := newTestClientByName(t, a, "", "FELICITA")
+	c2 := newTestClientByName(t, a, "", "FELICITA")
+	p1 := &fakePeripheral{id: "AA:AA:AA:AA:AA:AA", name: "FELICITA"}
+	p2 := &fakePeripheral{id: "BB:BB:BB:BB:BB:BB", name: "FELICITA"}
+
+	// Only one of the clients may connect to the first scale, the other one has to
+	// continue scanning and connect to the second scale
+	a.onPeriphDiscovered(p1, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p1, nil)
+	if !btDevice.scanning() {
+		t.Fatalf("shared device stopped scanning while second client is still scanning")
+	}
+	a.onPeriphDiscovered(p1, &gatt.Advertisement{}, -50)
+	a.onPeriphDiscovered(p2, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p2, nil)
+	if btDevice.scanning() {
+		t.Fatalf("shared device still scanning after all clients stopped scanning")
+	}
+
+	c1.mu.Lock()
+	c2.mu.Lock()
+	connected := append(append([]string{}, c1.connected...), c2.connected...)
+	c1.mu.Unlock()
+	c2.mu.Unlock()
+	if !equal(connected, []string{p1.id, p2.id}) && !equal(connected, []string{p2.id, p1.id}) {
+		t.Fatalf("unexpected connected callbacks: %v", connected)
+	}
+
+	// Connecting to a peripheral in use via another virtual device must fail
+	other := c2
+	if equal(connected, []string{p2.id, p1.id}) {
+		other = c1
+	}
+	if err := other.device.Connect(p1); err != ErrPeripheralInUse {
+		t.Fatalf("unexpected error connecting to peripheral in use: %v", err)
+	}
+
+	btDevice.mu.Lock()
+	defer btDevice.mu.Unlock()
+	if !equal(btDevice.connected, []string{p1.id, p2.id}) {
+		t.Fatalf("unexpected connections on shared device: %v", btDevice.connected)
+	}
+}
+
+func TestAdapterDeviceID(t *testing.T) {
+	btDevice := &fakeDevice{}
+	a := NewAdapterFromDevice(btDevice)
+
+	// Two scales sharing the same name, connected by ID (the second one being
+	// discovered first)
+	c1 := newTestClientByName(t, a, "AA:AA:AA:AA:AA:AA", "FELICITA")
+	c2 := newTestClientByName(t, a, "BB:BB:BB:BB:BB:BB", "FELICITA")
+	p1 := &fakePeripheral{id: c1.id, name: "FELICITA"}
+	p2 := &fakePeripheral{id: c2.id, name: "FELICITA"}
+
+	a.onPeriphDiscovered(p2, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p2, nil)
+	a.onPeriphDiscovered(p1, &gatt.Advertisement{}, -50)
+	a.onPeriphConnected(p1, nil)
+
+	for _, c := range []*testClient{c1, c2} {
+		c.mu.Lock()
+		if !equal(c.connected, []string{c.id}) {
+			t.Fatalf("unexpected connected callbacks for client %s: %v", c.id, c.connected)
+		}
+		c.mu.Unlock()
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
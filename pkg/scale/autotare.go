@@ -0,0 +1,243 @@
+package scale
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutoTareMinWeight      = 5.
+	defaultAutoTareTolerance      = 0.5
+	defaultAutoTareCooldown       = 10 * time.Second
+	defaultAutoTareConfirmTimeout = 3 * time.Second
+)
+
+// AutoTareEvent denotes an automatic tare of the scale
+type AutoTareEvent struct {
+	TimeStamp time.Time `json:"timestamp"`
+	Unit      Unit      `json:"unit"`
+
+	// Container denotes the weight of the container that was placed on the scale
+	Container float64 `json:"container"`
+
+	// Confirmed denotes if the scale reported a zero weight after taring it
+	Confirmed bool `json:"confirmed"`
+}
+
+// AutoTare automatically tares a scale once a container is placed on its empty platform,
+// i.e. once the weight changed from zero to a stable weight above a threshold. The weight
+// of the container is recorded, and removing the container (the scale reporting its
+// negative weight) or taring the scale manually (the scale reporting zero after a step)
+// renders the platform empty again. No automatic tare takes place within
+// a cooldown period after the previous one (e.g. while brewing). The zero value is ready
+// to use (with default settings), but requires a scale to be set
+type AutoTare struct {
+
+	// Scale denotes the scale to tare
+	Scale Basic
+
+	// MinWeight denotes the minimum weight (in grams, converted to the unit of the data
+	// points) of a container (0: 5g)
+	MinWeight float64
+
+	// Tolerance denotes the maximum deviation (in grams, converted to the unit of the data
+	// points) from the weight of the empty platform / from zero after taring the scale
+	// (0: 0.5g)
+	Tolerance float64
+
+	// Cooldown denotes the minimum time between two automatic tares (0: 10s)
+	Cooldown time.Duration
+
+	// ConfirmTimeout denotes the time the scale may take to report a zero weight after
+	// taring it (0: 3s)
+	ConfirmTimeout time.Duration
+
+	// Logger denotes the logger to emit tare errors to (nil: none)
+	Logger Logger
+
+	handler   func(event AutoTareEvent)
+	stability StabilityDetector
+
+	empty      DataPoint
+	lastStable *DataPoint
+	isOccupied bool
+	container  *AutoTareEvent
+	pending    *AutoTareEvent
+	lastTare   time.Time
+
+	mu sync.Mutex
+}
+
+// SetHandler defines a handler function that is called after each automatic tare (once
+// the scale reported a zero weight or the confirmation timed out)
+func (a *AutoTare) SetHandler(fn func(event AutoTareEvent)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.handler = fn
+}
+
+// Container returns the most recent (confirmed) automatic tare, i.e. the container that
+// is currently placed on the scale (if any)
+func (a *AutoTare) Container() (AutoTareEvent, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.container == nil {
+		return AutoTareEvent{}, false
+	}
+
+	return *a.container, true
+}
+
+// Run processes all data points of a subscription until its channel is closed
+func (a *AutoTare) Run(sub *Subscription) {
+	for data := range sub.Data() {
+		a.Process(data)
+	}
+}
+
+// Process processes a data point, taring the scale if a container was placed on its
+// empty platform
+func (a *AutoTare) Process(data DataPoint) {
+	a.mu.Lock()
+
+	data.Stable = a.stability.Process(data).Stable
+	minWeight, tolerance := a.settings(data.Unit)
+
+	// Data points in an unknown unit cannot be judged
+	if !data.Unit.IsValid() {
+		a.mu.Unlock()
+		return
+	}
+
+	// Await the confirmation of a previous tare
+	if a.pending != nil {
+		event := *a.pending
+		switch {
+		case event.Unit == data.Unit && math.Abs(data.Weight) <= tolerance:
+			event.Confirmed = true
+			a.pending, a.container, a.lastStable = nil, &event, &data
+			a.empty = DataPoint{Unit: event.Unit, Weight: -event.Container}
+		case data.TimeStamp.Sub(event.TimeStamp) > a.confirmTimeout():
+			a.pending = nil
+		default:
+			a.mu.Unlock()
+			return
+		}
+		handler := a.handler
+		a.mu.Unlock()
+
+		if !event.Confirmed {
+			a.logf("scale did not report a zero weight after automatic tare")
+		}
+		if handler != nil {
+			handler(event)
+		}
+		return
+	}
+
+	if !data.Stable {
+		a.mu.Unlock()
+		return
+	}
+
+	// Determine if the platform is empty, i.e. if the weight does not exceed the one of
+	// the empty platform (zero initially, the negative weight of the container after an
+	// automatic tare). A stable weight of zero following a step (e.g. after taring the
+	// scale manually) re-establishes the empty platform
+	empty, err := a.empty.In(data.Unit)
+	if err != nil || (math.Abs(data.Weight) <= tolerance && a.isStep(data, tolerance)) {
+		empty = DataPoint{Unit: data.Unit}
+	}
+	a.empty, a.lastStable = empty, &data
+	if data.Weight <= a.empty.Weight+tolerance {
+		a.isOccupied, a.container = false, nil
+		a.empty.Weight = math.Min(a.empty.Weight, data.Weight)
+		a.mu.Unlock()
+		return
+	}
+
+	// Tare the scale once a container was placed on the empty platform (unless within
+	// the cooldown period, in which case the container is disregarded)
+	if a.isOccupied || data.Weight-a.empty.Weight < minWeight {
+		a.mu.Unlock()
+		return
+	}
+	a.isOccupied = true
+	if !a.lastTare.IsZero() && data.TimeStamp.Sub(a.lastTare) < a.cooldown() {
+		a.mu.Unlock()
+		return
+	}
+
+	a.lastTare = data.TimeStamp
+	a.pending = &AutoTareEvent{
+		TimeStamp: data.TimeStamp,
+		Unit:      data.Unit,
+		Container: data.Weight - a.empty.Weight,
+	}
+	s := a.Scale
+	a.mu.Unlock()
+
+	if s == nil {
+		return
+	}
+	if err := s.Tare(); err != nil {
+		a.logf("failed to tare scale: %s", err)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// isStep determines if a stable weight deviates from the previous stable one (requires
+// the lock to be held)
+func (a *AutoTare) isStep(data DataPoint, tolerance float64) bool {
+	if a.lastStable == nil {
+		return false
+	}
+	last, err := a.lastStable.In(data.Unit)
+	if err != nil {
+		return false
+	}
+
+	return math.Abs(data.Weight-last.Weight) > tolerance
+}
+
+func (a *AutoTare) logf(format string, args ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Warnf(format, args...)
+	}
+}
+
+// settings returns the minimum weight and the tolerance converted to a unit
+func (a *AutoTare) settings(unit Unit) (float64, float64) {
+	minWeight, tolerance := a.MinWeight, a.Tolerance
+	if minWeight <= 0 {
+		minWeight = defaultAutoTareMinWeight
+	}
+	if tolerance <= 0 {
+		tolerance = defaultAutoTareTolerance
+	}
+	if unit == UnitOz {
+		minWeight, tolerance = minWeight/gramsPerOunce, tolerance/gramsPerOunce
+	}
+
+	return minWeight, tolerance
+}
+
+func (a *AutoTare) cooldown() time.Duration {
+	if a.Cooldown <= 0 {
+		return defaultAutoTareCooldown
+	}
+
+	return a.Cooldown
+}
+
+func (a *AutoTare) confirmTimeout() time.Duration {
+	if a.ConfirmTimeout <= 0 {
+		return defaultAutoTareConfirmTimeout
+	}
+
+	return a.ConfirmTimeout
+}
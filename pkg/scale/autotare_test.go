@@ -0,0 +1,55 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTareManualTare(t *testing.T) {
+	var (
+		events []AutoTareEvent
+		start  = time.Now()
+		offset time.Duration
+	)
+	a := AutoTare{Cooldown: time.Second}
+	a.SetHandler(func(event AutoTareEvent) {
+		events = append(events, event)
+	})
+
+	// Feeds a constant weight (as reported by the scale) for some time
+	feed := func(weight float64, duration time.Duration) {
+		for end := offset + duration; offset < end; offset += 100 * time.Millisecond {
+			a.Process(DataPoint{
+				TimeStamp: start.Add(offset),
+				Weight:    weight,
+				Unit:      UnitGrams,
+			})
+		}
+	}
+
+	// Placing a cup on the empty scale triggers a tare (confirmed by a zero weight)
+	feed(0., 2*time.Second)
+	feed(100., 2*time.Second)
+	feed(0., 2*time.Second)
+	if len(events) != 1 || !events[0].Confirmed || events[0].Container != 100. {
+		t.Fatalf("unexpected auto-tare events: %+v", events)
+	}
+
+	// Removing the cup and taring the scale manually must not be regarded as placing a
+	// container on the scale
+	feed(-100., 2*time.Second)
+	if _, ok := a.Container(); ok {
+		t.Fatalf("unexpected container after removing it")
+	}
+	feed(0., 2*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("unexpected auto-tare events after manual tare: %+v", events)
+	}
+
+	// The next cup must be tared again (relative to the re-established empty platform)
+	feed(150., 2*time.Second)
+	feed(0., 2*time.Second)
+	if len(events) != 2 || !events[1].Confirmed || events[1].Container != 150. {
+		t.Fatalf("unexpected auto-tare events after placing another cup: %+v", events)
+	}
+}
@@ -0,0 +1,217 @@
+package scale
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutoTimerStartThreshold = 0.3
+	defaultAutoTimerStopFlowRate   = 0.1
+	defaultAutoTimerStopDuration   = 2 * time.Second
+)
+
+// AutoTimerEvent denotes an automatic start / stop of the timer
+type AutoTimerEvent struct {
+
+	// Running denotes if the timer was started (or stopped)
+	Running bool `json:"running"`
+
+	// DataPoint denotes the data point that caused the event
+	DataPoint DataPoint `json:"data_point"`
+}
+
+// AutoTimer automatically starts the timer of a scale once the first drops arrive (i.e.
+// the weight rises above the noise level after taring the scale) and stops it once the
+// flow ceased. Abrupt weight changes (e.g. placing a cup on the scale) do not start the
+// timer. The zero value is ready to use (with default settings), but requires a timer
+// to be set
+type AutoTimer struct {
+
+	// Timer denotes the timer to start / stop
+	Timer Timer
+
+	// StartThreshold denotes the weight (in grams, converted to the unit of the data
+	// points) above which the weight is regarded as rising after a tare, i.e. the noise
+	// level (0: 0.3g)
+	StartThreshold float64
+
+	// StopFlowRate denotes the flow rate (in grams per second, converted to the unit of the
+	// data points) below which the flow is regarded as ceased (0: 0.1g/s)
+	StopFlowRate float64
+
+	// StopDuration denotes the time the flow rate has to remain below StopFlowRate to stop
+	// the timer (0: 2s)
+	StopDuration time.Duration
+
+	// Logger denotes the logger to emit timer errors to (nil: none)
+	Logger Logger
+
+	handler func(event AutoTimerEvent)
+	flow    FlowProcessor
+
+	isArmed   bool
+	isRunning bool
+	ceasedAt  time.Time
+	last      *DataPoint
+
+	mu sync.Mutex
+}
+
+// SetHandler defines a handler function that is called once the timer was started /
+// stopped
+func (a *AutoTimer) SetHandler(fn func(event AutoTimerEvent)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.handler = fn
+}
+
+// IsRunning returns if the timer has been started (and not yet stopped) automatically
+func (a *AutoTimer) IsRunning() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.isRunning
+}
+
+// Run processes all data points of a subscription until its channel is closed
+func (a *AutoTimer) Run(sub *Subscription) {
+	for data := range sub.Data() {
+		a.Process(data)
+	}
+}
+
+// Process processes a data point, starting / stopping the timer (if applicable)
+func (a *AutoTimer) Process(data DataPoint) {
+	a.mu.Lock()
+
+	rate := a.flow.Process(data)
+	last := a.last
+	a.last = &data
+
+	// Data points in an unknown unit cannot be judged
+	if !data.Unit.IsValid() {
+		a.mu.Unlock()
+		return
+	}
+	startThreshold, stopFlowRate := a.settings(data.Unit)
+
+	if a.isRunning {
+
+		// Stop the timer once the flow ceased for long enough
+		if math.Abs(rate.Rate) >= stopFlowRate {
+			a.ceasedAt = time.Time{}
+			a.mu.Unlock()
+			return
+		}
+		if a.ceasedAt.IsZero() {
+			a.ceasedAt = data.TimeStamp
+		}
+		if data.TimeStamp.Sub(a.ceasedAt) < a.stopDuration() {
+			a.mu.Unlock()
+			return
+		}
+
+		a.isRunning = false
+		a.mu.Unlock()
+
+		a.stop(data)
+		return
+	}
+
+	// Arm the timer once the weight returned to zero (i.e. after taring the scale), start
+	// it once the weight rises above the noise level (unless changing abruptly)
+	if math.Abs(data.Weight) <= startThreshold {
+		a.isArmed = true
+		a.mu.Unlock()
+		return
+	}
+	if !a.isArmed || data.Weight < 0 {
+		a.mu.Unlock()
+		return
+	}
+	if last == nil || isAbrupt(*last, data, &a.flow) {
+		a.isArmed = false
+		a.mu.Unlock()
+		return
+	}
+
+	a.isArmed, a.isRunning, a.ceasedAt = false, true, time.Time{}
+	a.mu.Unlock()
+
+	a.start(data)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (a *AutoTimer) start(data DataPoint) {
+	if a.Timer != nil {
+		if err := a.Timer.ResetTimer(); err != nil {
+			a.logf("failed to reset timer: %s", err)
+		}
+		if err := a.Timer.StartTimer(); err != nil {
+			a.logf("failed to start timer: %s", err)
+		}
+	}
+
+	a.notify(AutoTimerEvent{
+		Running:   true,
+		DataPoint: data,
+	})
+}
+
+func (a *AutoTimer) stop(data DataPoint) {
+	if a.Timer != nil {
+		if err := a.Timer.StopTimer(); err != nil {
+			a.logf("failed to stop timer: %s", err)
+		}
+	}
+
+	a.notify(AutoTimerEvent{
+		Running:   false,
+		DataPoint: data,
+	})
+}
+
+func (a *AutoTimer) notify(event AutoTimerEvent) {
+	a.mu.Lock()
+	handler := a.handler
+	a.mu.Unlock()
+
+	// Call handler function, if any
+	if handler != nil {
+		handler(event)
+	}
+}
+
+func (a *AutoTimer) logf(format string, args ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Warnf(format, args...)
+	}
+}
+
+// settings returns the start threshold and the stop flow rate converted to a unit
+func (a *AutoTimer) settings(unit Unit) (float64, float64) {
+	startThreshold, stopFlowRate := a.StartThreshold, a.StopFlowRate
+	if startThreshold <= 0 {
+		startThreshold = defaultAutoTimerStartThreshold
+	}
+	if stopFlowRate <= 0 {
+		stopFlowRate = defaultAutoTimerStopFlowRate
+	}
+	if unit == UnitOz {
+		startThreshold, stopFlowRate = startThreshold/gramsPerOunce, stopFlowRate/gramsPerOunce
+	}
+
+	return startThreshold, stopFlowRate
+}
+
+func (a *AutoTimer) stopDuration() time.Duration {
+	if a.StopDuration <= 0 {
+		return defaultAutoTimerStopDuration
+	}
+
+	return a.StopDuration
+}
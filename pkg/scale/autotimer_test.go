@@ -0,0 +1,47 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTimer(t *testing.T) {
+	var (
+		timer  testTimer
+		events []AutoTimerEvent
+		start  = time.Now()
+	)
+	a := AutoTimer{Timer: &timer}
+	a.SetHandler(func(event AutoTimerEvent) {
+		events = append(events, event)
+	})
+
+	// Placing a cup on the scale must not start the timer, a subsequent increase of weight
+	// neither (since the scale was not tared)
+	a.Process(DataPoint{TimeStamp: start.Add(-time.Second), Weight: 0, Unit: UnitGrams})
+	for _, data := range shotDataPoints(start, 250., 2., time.Second, 2*time.Second) {
+		a.Process(data)
+	}
+	if len(events) != 0 || timer.started != 0 {
+		t.Fatalf("unexpected timer events without tare: %+v", events)
+	}
+
+	// After taring the scale, the first drops must start the timer and the ceasing flow
+	// must stop it again
+	start = start.Add(time.Minute)
+	for _, data := range shotDataPoints(start, 0., 2., 3*time.Second, 10*time.Second) {
+		a.Process(data)
+		if offset := data.TimeStamp.Sub(start); offset == 5*time.Second && !a.IsRunning() {
+			t.Fatalf("timer not running during flow")
+		}
+	}
+	if len(events) != 2 || timer.reset != 1 || timer.started != 1 || timer.stopped != 1 {
+		t.Fatalf("unexpected timer events / calls: %+v, %+v", events, timer)
+	}
+	if !events[0].Running || !events[0].DataPoint.TimeStamp.Equal(start.Add(3200*time.Millisecond)) {
+		t.Fatalf("unexpected start event: %+v", events[0])
+	}
+	if offset := events[1].DataPoint.TimeStamp.Sub(start); events[1].Running || offset < 13*time.Second || offset > 16*time.Second {
+		t.Fatalf("unexpected stop event: %+v (offset %v)", events[1], offset)
+	}
+}
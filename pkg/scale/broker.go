@@ -0,0 +1,347 @@
+package scale
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const defaultSubscriptionBufferSize = 64
+
+// Broker distributes the data points and state changes of a scale to any number of
+// subscribers (each subscription receiving all events on its own channels according
+// to its delivery policy). It also serves the single handler function / channel per event
+// type that can be set via the legacy setters of a scale. The zero value is ready to use
+type Broker struct {
+
+	// DataPolicy denotes the delivery policy for data points (for the legacy data channel
+	// and the default for subscriptions, DeliveryDefault: dropping the oldest data point)
+	DataPolicy DeliveryPolicy
+
+	// StatePolicy denotes the delivery policy for state changes (for the legacy state
+	// change channel and the default for subscriptions, DeliveryDefault: dropping the new
+	// state change for the legacy channel, dropping the oldest one for subscriptions)
+	StatePolicy DeliveryPolicy
+
+	subscriptions map[*Subscription]struct{}
+	isClosed      bool
+	done          chan struct{}
+	initOnce      sync.Once
+
+	dataHandler  func(data DataPoint)
+	dataChan     chan DataPoint
+	stateHandler func(status ConnectionStatus)
+	stateChan    chan ConnectionStatus
+
+	droppedData   atomic.Uint64
+	droppedStates atomic.Uint64
+
+	mu sync.RWMutex
+}
+
+// Subscribe registers a new subscription, executing functional options, if any. Once the
+// broker is closed, the channels of all subscriptions are closed
+func (b *Broker) Subscribe(options ...func(*Subscription)) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{
+		dataPolicy:  b.DataPolicy,
+		statePolicy: b.StatePolicy,
+		bufferSize:  defaultSubscriptionBufferSize,
+		done:        make(chan struct{}),
+		broker:      b,
+	}
+
+	// Execute functional options (if any)
+	for _, option := range options {
+		option(s)
+	}
+	s.dataPolicy = s.dataPolicy.or(DeliveryDropOldest)
+	s.statePolicy = s.statePolicy.or(DeliveryDropOldest)
+
+	s.data = make(chan DataPoint, s.bufferSize)
+	s.states = make(chan ConnectionStatus, s.bufferSize)
+
+	// Subscriptions to a closed broker are closed right away
+	if b.isClosed {
+		s.close()
+		return s
+	}
+
+	if b.subscriptions == nil {
+		b.subscriptions = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[s] = struct{}{}
+
+	return s
+}
+
+// SetDataHandler defines a handler function that is called upon retrieval of data
+func (b *Broker) SetDataHandler(fn func(data DataPoint)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dataHandler = fn
+}
+
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless a different delivery
+// policy is set)
+func (b *Broker) SetDataChannel(ch chan DataPoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dataChan = ch
+}
+
+// SetStateChangeHandler defines a handler function that is called upon state change
+func (b *Broker) SetStateChangeHandler(fn func(status ConnectionStatus)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stateHandler = fn
+}
+
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the new
+// state change if the consumer does not keep up, unless a different delivery policy is
+// set)
+func (b *Broker) SetStateChangeChannel(ch chan ConnectionStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stateChan = ch
+}
+
+// PublishData distributes a data point to the handler function, channel and all
+// subscriptions (if any)
+func (b *Broker) PublishData(data DataPoint) {
+	b.mu.RLock()
+	dataHandler, dataChan := b.dataHandler, b.dataChan
+	b.mu.RUnlock()
+
+	// Call handler function, if any (without holding the lock, allowing the handler to
+	// interact with the broker)
+	if dataHandler != nil {
+		dataHandler(data)
+	}
+
+	// Put data point on channel, if any (according to the delivery policy, a blocking
+	// delivery is aborted once the broker is closed)
+	if dataChan != nil {
+		b.droppedData.Add(deliver(dataChan, data, b.DataPolicy.or(DeliveryDropOldest), b.closed()))
+	}
+
+	// Deliver to all subscriptions without holding the lock, allowing subscriptions to be
+	// added / cancelled while a (blocking) delivery is in progress
+	for _, s := range b.subscriptionList() {
+		b.droppedData.Add(s.publishData(data))
+	}
+}
+
+// PublishState distributes a state change to the handler function, channel and all
+// subscriptions (if any)
+func (b *Broker) PublishState(status ConnectionStatus) {
+	b.mu.RLock()
+	stateHandler, stateChan := b.stateHandler, b.stateChan
+	b.mu.RUnlock()
+
+	// Call handler function, if any (without holding the lock, allowing the handler to
+	// interact with the broker)
+	if stateHandler != nil {
+		stateHandler(status)
+	}
+
+	// Put state change on channel, if any (according to the delivery policy, a blocking
+	// delivery is aborted once the broker is closed)
+	if stateChan != nil {
+		b.droppedStates.Add(deliver(stateChan, status, b.StatePolicy.or(DeliveryDropNewest), b.closed()))
+	}
+
+	// Deliver to all subscriptions without holding the lock, allowing subscriptions to be
+	// added / cancelled while a (blocking) delivery is in progress
+	for _, s := range b.subscriptionList() {
+		b.droppedStates.Add(s.publishState(status))
+	}
+}
+
+// Stats returns the total number of events dropped so far (across the legacy channels
+// and all subscriptions, including past ones)
+func (b *Broker) Stats() DeliveryStats {
+	return DeliveryStats{
+		DroppedData:   b.droppedData.Load(),
+		DroppedStates: b.droppedStates.Load(),
+	}
+}
+
+// Close closes all subscriptions (subsequent subscriptions are closed right away) and
+// aborts any blocking delivery to the legacy channels
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.isClosed {
+		return
+	}
+	b.isClosed = true
+	close(b.closed())
+
+	for s := range b.subscriptions {
+		delete(b.subscriptions, s)
+		s.close()
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// closed returns the channel that is closed once the broker is closed
+func (b *Broker) closed() chan struct{} {
+	b.initOnce.Do(func() {
+		b.done = make(chan struct{})
+	})
+
+	return b.done
+}
+
+// subscriptionList returns a snapshot of the current subscriptions
+func (b *Broker) subscriptionList() []*Subscription {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	res := make([]*Subscription, 0, len(b.subscriptions))
+	for s := range b.subscriptions {
+		res = append(res, s)
+	}
+
+	return res
+}
+
+func (b *Broker) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	_, exists := b.subscriptions[s]
+	delete(b.subscriptions, s)
+	b.mu.Unlock()
+
+	if exists {
+		s.close()
+	}
+}
+
+// Subscription denotes a subscription to the data points and state changes of a scale
+type Subscription struct {
+	data   chan DataPoint
+	states chan ConnectionStatus
+	done   chan struct{}
+
+	dataPolicy  DeliveryPolicy
+	statePolicy DeliveryPolicy
+	bufferSize  int
+
+	droppedData   atomic.Uint64
+	droppedStates atomic.Uint64
+
+	broker    *Broker
+	abortOnce sync.Once
+	isClosed  bool
+
+	mu sync.RWMutex
+}
+
+// WithBufferSize sets the buffer size of the channels of a subscription (default: 64)
+func WithBufferSize(size int) func(*Subscription) {
+	return func(s *Subscription) {
+		if size >= 0 {
+			s.bufferSize = size
+		}
+	}
+}
+
+// WithDeliveryPolicy sets the delivery policies of a subscription (default: the ones of
+// the scale)
+func WithDeliveryPolicy(data, state DeliveryPolicy) func(*Subscription) {
+	return func(s *Subscription) {
+		s.dataPolicy = data
+		s.statePolicy = state
+	}
+}
+
+// Data returns the channel providing the data points (closed upon Unsubscribe() or once
+// the scale is closed)
+func (s *Subscription) Data() <-chan DataPoint {
+	return s.data
+}
+
+// States returns the channel providing the state changes (closed upon Unsubscribe() or
+// once the scale is closed)
+func (s *Subscription) States() <-chan ConnectionStatus {
+	return s.states
+}
+
+// DeliveryStats returns the number of events dropped for this subscription so far
+func (s *Subscription) DeliveryStats() DeliveryStats {
+	return DeliveryStats{
+		DroppedData:   s.droppedData.Load(),
+		DroppedStates: s.droppedStates.Load(),
+	}
+}
+
+// Unsubscribe cancels the subscription and closes its channels (subsequent calls are a
+// no-op)
+func (s *Subscription) Unsubscribe() {
+	s.broker.unsubscribe(s)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// publishData puts a data point on the channel of the subscription (unless it is closed)
+// and returns the number of data points dropped in the process
+func (s *Subscription) publishData(data DataPoint) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.isClosed {
+		return 0
+	}
+
+	dropped := deliver(s.data, data, s.dataPolicy, s.done)
+	s.droppedData.Add(dropped)
+
+	return dropped
+}
+
+// publishState puts a state change on the channel of the subscription (unless it is
+// closed) and returns the number of state changes dropped in the process
+func (s *Subscription) publishState(status ConnectionStatus) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.isClosed {
+		return 0
+	}
+
+	dropped := deliver(s.states, status, s.statePolicy, s.done)
+	s.droppedStates.Add(dropped)
+
+	return dropped
+}
+
+func (s *Subscription) abort() {
+	s.abortOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// close closes the channels of the subscription (aborting any blocking delivery first,
+// which would otherwise hold the lock indefinitely)
+func (s *Subscription) close() {
+	s.abort()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed {
+		return
+	}
+	s.isClosed = true
+	close(s.data)
+	close(s.states)
+}
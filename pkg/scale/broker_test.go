@@ -0,0 +1,235 @@
+package scale
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBrokerDeliveryPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy          DeliveryPolicy
+		expectedWeights []float64
+		expectedDropped uint64
+	}{
+		{DeliveryDropOldest, []float64{3, 4}, 2},
+		{DeliveryDropNewest, []float64{1, 2}, 2},
+	} {
+		t.Run(c.policy.String(), func(t *testing.T) {
+			b := Broker{
+				DataPolicy:  c.policy,
+				StatePolicy: c.policy,
+			}
+
+			dataChan := make(chan DataPoint, 2)
+			stateChan := make(chan ConnectionStatus, 1)
+			b.SetDataChannel(dataChan)
+			b.SetStateChangeChannel(stateChan)
+			s := b.Subscribe(WithBufferSize(2))
+
+			for i := 1; i <= 4; i++ {
+				b.PublishData(DataPoint{Weight: float64(i)})
+				b.PublishState(ConnectionStatus{Attempt: i})
+			}
+
+			for _, ch := range []<-chan DataPoint{dataChan, s.Data()} {
+				for _, expected := range c.expectedWeights {
+					if data := <-ch; data.Weight != expected {
+						t.Fatalf("unexpected data point, want weight %v, have %v", expected, data.Weight)
+					}
+				}
+			}
+
+			expectedStats := DeliveryStats{
+				DroppedData:   c.expectedDropped,
+				DroppedStates: 2,
+			}
+			if stats := s.DeliveryStats(); stats != expectedStats {
+				t.Fatalf("unexpected subscription delivery statistics, want %+v, have %+v", expectedStats, stats)
+			}
+			expectedStats = DeliveryStats{
+				DroppedData:   2 * c.expectedDropped,
+				DroppedStates: 5,
+			}
+			if stats := b.Stats(); stats != expectedStats {
+				t.Fatalf("unexpected delivery statistics, want %+v, have %+v", expectedStats, stats)
+			}
+		})
+	}
+}
+
+func TestBrokerDefaultDeliveryPolicy(t *testing.T) {
+	var b Broker
+	dataChan := make(chan DataPoint, 1)
+	stateChan := make(chan ConnectionStatus, 1)
+	b.SetDataChannel(dataChan)
+	b.SetStateChangeChannel(stateChan)
+	s := b.Subscribe(WithBufferSize(1))
+
+	// Channels that are never read must not block publishing: the legacy state change
+	// channel retains the first state change, all other channels the most recent event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 3; i++ {
+			b.PublishData(DataPoint{Weight: float64(i)})
+			b.PublishState(ConnectionStatus{Attempt: i})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("publishing was blocked by unread channels")
+	}
+
+	if status := <-stateChan; status.Attempt != 1 {
+		t.Fatalf("unexpected state change on legacy channel: %+v", status)
+	}
+	if status := <-s.States(); status.Attempt != 3 {
+		t.Fatalf("unexpected state change on subscription: %+v", status)
+	}
+	for _, ch := range []<-chan DataPoint{dataChan, s.Data()} {
+		if data := <-ch; data.Weight != 3 {
+			t.Fatalf("unexpected data point: %+v", data)
+		}
+	}
+	if stats := b.Stats(); stats != (DeliveryStats{DroppedData: 4, DroppedStates: 4}) {
+		t.Fatalf("unexpected delivery statistics: %+v", stats)
+	}
+}
+
+func TestBrokerSubscriptions(t *testing.T) {
+	var (
+		b              Broker
+		handlerWeights []float64
+		mu             sync.Mutex
+	)
+
+	// Legacy handler and any number of subscriptions receive all events
+	b.SetDataHandler(func(data DataPoint) {
+		mu.Lock()
+		defer mu.Unlock()
+		handlerWeights = append(handlerWeights, data.Weight)
+	})
+	s1, s2 := b.Subscribe(), b.Subscribe(WithDeliveryPolicy(DeliveryBlock, DeliveryBlock))
+
+	b.PublishData(DataPoint{Weight: 1})
+	b.PublishState(ConnectionStatus{State: StateConnected})
+	for _, s := range []*Subscription{s1, s2} {
+		if data := <-s.Data(); data.Weight != 1 {
+			t.Fatalf("unexpected data point: %+v", data)
+		}
+		if status := <-s.States(); status.State != StateConnected {
+			t.Fatalf("unexpected state change: %+v", status)
+		}
+	}
+	mu.Lock()
+	if len(handlerWeights) != 1 || handlerWeights[0] != 1 {
+		t.Fatalf("unexpected data points passed to handler: %v", handlerWeights)
+	}
+	mu.Unlock()
+
+	// Unsubscribing closes the channels of the subscription (only)
+	s1.Unsubscribe()
+	s1.Unsubscribe()
+	if _, ok := <-s1.Data(); ok {
+		t.Fatalf("data channel still open after unsubscribing")
+	}
+	b.PublishData(DataPoint{Weight: 2})
+	if data := <-s2.Data(); data.Weight != 2 {
+		t.Fatalf("unexpected data point: %+v", data)
+	}
+
+	// Closing the broker closes all remaining (and future) subscriptions
+	b.Close()
+	for _, s := range []*Subscription{s2, b.Subscribe()} {
+		if _, ok := <-s.Data(); ok {
+			t.Fatalf("data channel still open after closing broker")
+		}
+		if _, ok := <-s.States(); ok {
+			t.Fatalf("state channel still open after closing broker")
+		}
+	}
+}
+
+func TestBrokerUnsubscribeBlocked(t *testing.T) {
+	var b Broker
+	s := b.Subscribe(WithBufferSize(0), WithDeliveryPolicy(DeliveryBlock, DeliveryBlock))
+
+	// A blocking delivery to a subscription that is never read must be aborted once the
+	// subscription is cancelled
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.PublishData(DataPoint{Weight: 1})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Unsubscribe()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("blocking delivery was not aborted upon unsubscribing")
+	}
+	if stats := s.DeliveryStats(); stats.DroppedData != 1 {
+		t.Fatalf("unexpected delivery statistics: %+v", stats)
+	}
+}
+
+func TestBrokerSubscribeWhileBlocked(t *testing.T) {
+	var b Broker
+	b.Subscribe(WithBufferSize(0), WithDeliveryPolicy(DeliveryBlock, DeliveryBlock))
+
+	// A blocking delivery to a subscription that is never read must neither prevent new
+	// subscriptions nor closing the broker
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.PublishData(DataPoint{Weight: 1})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		b.Subscribe()
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatalf("subscribing was blocked by pending delivery")
+	}
+
+	b.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("blocking delivery was not aborted upon closing the broker")
+	}
+}
+
+func TestBrokerCloseBlockedChannel(t *testing.T) {
+	b := Broker{DataPolicy: DeliveryBlock}
+	b.SetDataChannel(make(chan DataPoint))
+
+	// A blocking delivery to a legacy channel that is never read must be aborted once the
+	// broker is closed
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.PublishData(DataPoint{Weight: 1})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("blocking delivery was not aborted upon closing the broker")
+	}
+	if stats := b.Stats(); stats.DroppedData != 1 {
+		t.Fatalf("unexpected delivery statistics: %+v", stats)
+	}
+}
@@ -0,0 +1,209 @@
+package scale
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultFirstDropThreshold = 0.5
+
+// ErrUnknownUnit denotes that a weight cannot be converted from / to a unit
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// In returns the data point with its weight converted to a unit
+func (d DataPoint) In(unit Unit) (DataPoint, error) {
+	if d.Unit == unit && unit != UnitUnknown {
+		return d, nil
+	}
+
+	from, ok := gramsPerUnit(d.Unit)
+	if !ok {
+		return DataPoint{}, fmt.Errorf("failed to convert weight from `%s`: %w", d.Unit, ErrUnknownUnit)
+	}
+	to, ok := gramsPerUnit(unit)
+	if !ok {
+		return DataPoint{}, fmt.Errorf("failed to convert weight to `%s`: %w", unit, ErrUnknownUnit)
+	}
+
+	d.Weight = d.Weight * from / to
+	d.RawWeight = d.RawWeight * from / to
+	d.Unit = unit
+
+	return d, nil
+}
+
+// Normalize returns a copy of the data points with all weights converted to a unit
+func (d DataPoints) Normalize(unit Unit) (DataPoints, error) {
+	res := make(DataPoints, 0, len(d))
+	for _, data := range d {
+		converted, err := data.In(unit)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, converted)
+	}
+
+	return res, nil
+}
+
+// Duration returns the time span between the first and the last data point
+func (d DataPoints) Duration() time.Duration {
+	if len(d) < 2 {
+		return 0
+	}
+
+	return d[len(d)-1].TimeStamp.Sub(d[0].TimeStamp)
+}
+
+// First returns the first data point (if any)
+func (d DataPoints) First() (DataPoint, bool) {
+	if len(d) == 0 {
+		return DataPoint{}, false
+	}
+
+	return d[0], true
+}
+
+// Last returns the last data point (if any)
+func (d DataPoints) Last() (DataPoint, bool) {
+	if len(d) == 0 {
+		return DataPoint{}, false
+	}
+
+	return d[len(d)-1], true
+}
+
+// Min returns the data point with the lowest weight (if any), taking into account the unit
+// of each data point (data points with an unknown unit are disregarded)
+func (d DataPoints) Min() (DataPoint, bool) {
+	return d.extreme(func(a, b float64) bool {
+		return a < b
+	})
+}
+
+// Max returns the data point with the highest weight (if any), taking into account the
+// unit of each data point (data points with an unknown unit are disregarded)
+func (d DataPoints) Max() (DataPoint, bool) {
+	return d.extreme(func(a, b float64) bool {
+		return a > b
+	})
+}
+
+// Between returns all data points within a time range (including its boundaries)
+func (d DataPoints) Between(start, end time.Time) DataPoints {
+	var res DataPoints
+	for _, data := range d {
+		if !data.TimeStamp.Before(start) && !data.TimeStamp.After(end) {
+			res = append(res, data)
+		}
+	}
+
+	return res
+}
+
+// Resample returns the data points at a fixed interval (starting at the first data point),
+// linearly interpolating the weight between adjacent data points. The data points are
+// expected to be ordered by time. Across a change of unit, the weight of the preceding data
+// point is retained
+func (d DataPoints) Resample(interval time.Duration) DataPoints {
+	if len(d) == 0 || interval <= 0 {
+		return nil
+	}
+
+	first, last := d[0].TimeStamp, d[len(d)-1].TimeStamp
+	res := make(DataPoints, 0, int(last.Sub(first)/interval)+1)
+
+	i := 0
+	for ts := first; !ts.After(last); ts = ts.Add(interval) {
+
+		// Find the last data point at or before the timestamp
+		for i+1 < len(d) && !d[i+1].TimeStamp.After(ts) {
+			i++
+		}
+
+		data := d[i]
+		if i+1 < len(d) && !data.TimeStamp.Equal(ts) && d[i+1].Unit == data.Unit {
+			next := d[i+1]
+			if span := next.TimeStamp.Sub(data.TimeStamp); span > 0 {
+				fraction := float64(ts.Sub(data.TimeStamp)) / float64(span)
+				data.Weight += fraction * (next.Weight - data.Weight)
+				data.Stable = data.Stable && next.Stable
+			}
+		}
+		data.TimeStamp = ts
+
+		res = append(res, data)
+	}
+
+	return res
+}
+
+// TimeToFirstDrop returns the time from the first data point until the weight first
+// exceeded the lowest weight observed before by more than a threshold (in grams, converted
+// to the unit of the data points, 0: 0.5g), i.e. the time until the first drops arrived.
+// A change of unit restarts the observation
+func (d DataPoints) TimeToFirstDrop(threshold float64) (time.Duration, bool) {
+	if len(d) == 0 {
+		return 0, false
+	}
+	if threshold <= 0 {
+		threshold = defaultFirstDropThreshold
+	}
+
+	baseline := d[0]
+	for _, data := range d[1:] {
+		if data.Unit != baseline.Unit {
+			baseline = data
+			continue
+		}
+
+		limit := threshold
+		if data.Unit == UnitOz {
+			limit /= gramsPerOunce
+		}
+		if data.Weight-baseline.Weight > limit {
+			return data.TimeStamp.Sub(d[0].TimeStamp), true
+		}
+		if data.Weight < baseline.Weight {
+			baseline = data
+		}
+	}
+
+	return 0, false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// extreme returns the data point whose weight (in grams) is preferred over all others
+// according to a comparison function
+func (d DataPoints) extreme(isPreferred func(a, b float64) bool) (DataPoint, bool) {
+	var (
+		res    DataPoint
+		weight float64
+		found  bool
+	)
+	for _, data := range d {
+		factor, ok := gramsPerUnit(data.Unit)
+		if !ok {
+			continue
+		}
+		if !found || isPreferred(data.Weight*factor, weight) {
+			res, weight, found = data, data.Weight*factor, true
+		}
+	}
+
+	return res, found
+}
+
+// gramsPerUnit returns the number of grams per unit of weight
+func gramsPerUnit(unit Unit) (float64, bool) {
+	switch unit {
+	case UnitGrams:
+		return 1, true
+	case UnitOz:
+		return gramsPerOunce, true
+	}
+
+	return 0, false
+}
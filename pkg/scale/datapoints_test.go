@@ -0,0 +1,96 @@
+package scale
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDataPointConversion(t *testing.T) {
+	start := time.Now()
+
+	data := DataPoint{TimeStamp: start, Unit: UnitOz, Weight: 1}
+	converted, err := data.In(UnitGrams)
+	if err != nil {
+		t.Fatalf("failed to convert data point: %s", err)
+	}
+	if converted.Unit != UnitGrams || math.Abs(converted.Weight-gramsPerOunce) > 1e-9 || !converted.TimeStamp.Equal(start) {
+		t.Fatalf("unexpected converted data point: %+v", converted)
+	}
+
+	if _, err := (DataPoint{Unit: UnitUnknown}).In(UnitGrams); !errors.Is(err, ErrUnknownUnit) {
+		t.Fatalf("unexpected error converting from unknown unit: %v", err)
+	}
+	if _, err := (DataPoint{Unit: UnitUnknown}).In(UnitUnknown); !errors.Is(err, ErrUnknownUnit) {
+		t.Fatalf("unexpected error converting to unknown unit: %v", err)
+	}
+
+	normalized, err := DataPoints{
+		{Unit: UnitGrams, Weight: 56.69904625},
+		{Unit: UnitOz, Weight: 1},
+	}.Normalize(UnitOz)
+	if err != nil {
+		t.Fatalf("failed to normalize data points: %s", err)
+	}
+	for i, want := range []float64{2, 1} {
+		if normalized[i].Unit != UnitOz || math.Abs(normalized[i].Weight-want) > 1e-9 {
+			t.Fatalf("unexpected normalized data point %d: %+v", i, normalized[i])
+		}
+	}
+}
+
+func TestDataPointsAnalysis(t *testing.T) {
+	start := time.Now()
+	data := DataPoints{
+		{TimeStamp: start, Unit: UnitGrams, Weight: 0.2},
+		{TimeStamp: start.Add(200 * time.Millisecond), Unit: UnitGrams, Weight: -0.1},
+		{TimeStamp: start.Add(500 * time.Millisecond), Unit: UnitGrams, Weight: 0.3},
+		{TimeStamp: start.Add(time.Second), Unit: UnitGrams, Weight: 1.5},
+		{TimeStamp: start.Add(2 * time.Second), Unit: UnitGrams, Weight: 3.5},
+	}
+
+	if d := data.Duration(); d != 2*time.Second {
+		t.Fatalf("unexpected duration: %v", d)
+	}
+	if first, ok := data.First(); !ok || first.Weight != 0.2 {
+		t.Fatalf("unexpected first data point: %+v", first)
+	}
+	if last, ok := data.Last(); !ok || last.Weight != 3.5 {
+		t.Fatalf("unexpected last data point: %+v", last)
+	}
+	if min, ok := data.Min(); !ok || min.Weight != -0.1 {
+		t.Fatalf("unexpected minimum data point: %+v", min)
+	}
+	if max, ok := data.Max(); !ok || max.Weight != 3.5 {
+		t.Fatalf("unexpected maximum data point: %+v", max)
+	}
+	if _, ok := (DataPoints{}).Max(); ok {
+		t.Fatalf("unexpected maximum of empty data points")
+	}
+
+	// The first drop is detected relative to the lowest weight observed before
+	if d, ok := data.TimeToFirstDrop(0); !ok || d != time.Second {
+		t.Fatalf("unexpected time to first drop: %v (%v)", d, ok)
+	}
+	if _, ok := data.TimeToFirstDrop(10); ok {
+		t.Fatalf("unexpected first drop above threshold")
+	}
+
+	if between := data.Between(start.Add(200*time.Millisecond), start.Add(time.Second)); len(between) != 3 || between[0].Weight != -0.1 {
+		t.Fatalf("unexpected data points in time range: %+v", between)
+	}
+
+	resampled := data.Resample(250 * time.Millisecond)
+	if len(resampled) != 9 {
+		t.Fatalf("unexpected number of resampled data points: %d", len(resampled))
+	}
+	for i, want := range []float64{0.2, -0.1 + 0.4/6, 0.3, 0.9, 1.5, 2, 2.5, 3, 3.5} {
+		if !resampled[i].TimeStamp.Equal(start.Add(time.Duration(i) * 250 * time.Millisecond)) {
+			t.Fatalf("unexpected timestamp of resampled data point %d: %v", i, resampled[i].TimeStamp)
+		}
+		if math.Abs(resampled[i].Weight-want) > 1e-9 {
+			t.Fatalf("unexpected weight of resampled data point %d: %v (want %v)", i, resampled[i].Weight, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package scale
+
+import "fmt"
+
+// DeliveryPolicy denotes the behavior when delivering an event (data point or state
+// change) to a channel whose consumer does not keep up
+type DeliveryPolicy int
+
+const (
+
+	// DeliveryDefault denotes the default behavior, which never blocks: state changes are
+	// delivered to the channel set via the legacy setter according to DeliveryDropNewest
+	// (as done originally), all other events according to DeliveryDropOldest
+	DeliveryDefault DeliveryPolicy = iota
+
+	// DeliveryDropOldest discards the oldest buffered event to make room for the new one
+	// (for unbuffered channels, this is equivalent to DeliveryDropNewest)
+	DeliveryDropOldest
+
+	// DeliveryDropNewest discards the new event if the channel is full
+	DeliveryDropNewest
+
+	// DeliveryBlock blocks until the consumer accepts the event. Note that this stalls
+	// the bluetooth event loop (and hence all further events) while the consumer is busy
+	DeliveryBlock
+)
+
+// String returns a string representation of the delivery policy
+func (p DeliveryPolicy) String() string {
+	switch p {
+	case DeliveryDefault:
+		return "default"
+	case DeliveryDropOldest:
+		return "drop-oldest"
+	case DeliveryDropNewest:
+		return "drop-newest"
+	case DeliveryBlock:
+		return "block"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(p))
+}
+
+// DeliveryStats denotes the number of events dropped since the scale was instantiated
+type DeliveryStats struct {
+
+	// DroppedData denotes the number of data points dropped
+	DroppedData uint64 `json:"dropped_data"`
+
+	// DroppedStates denotes the number of state changes dropped
+	DroppedStates uint64 `json:"dropped_states"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// or returns the delivery policy, falling back to another one for DeliveryDefault
+func (p DeliveryPolicy) or(fallback DeliveryPolicy) DeliveryPolicy {
+	if p == DeliveryDefault {
+		return fallback
+	}
+
+	return p
+}
+
+// deliver puts an event on a channel according to a delivery policy and returns the
+// number of events dropped in the process (a blocking delivery is aborted and the event
+// dropped once done is closed)
+func deliver[T any](ch chan T, event T, policy DeliveryPolicy, done chan struct{}) uint64 {
+	if policy == DeliveryBlock {
+		select {
+		case ch <- event:
+			return 0
+		case <-done:
+			return 1
+		}
+	}
+
+	select {
+	case ch <- event:
+		return 0
+	default:
+	}
+
+	if policy == DeliveryDropNewest {
+		return 1
+	}
+
+	// Evict the oldest event (if any, it may have been consumed in the meantime) and
+	// retry once, dropping the new event if another producer took the freed slot
+	var dropped uint64
+	select {
+	case <-ch:
+		dropped++
+	default:
+	}
+	select {
+	case ch <- event:
+	default:
+		dropped++
+	}
+
+	return dropped
+}
@@ -0,0 +1,109 @@
+package scale
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/fako1024/gatt"
+)
+
+const (
+
+	// DeviceInformationService denotes the UUID of the standard Device Information service
+	DeviceInformationService = "180a"
+
+	systemIDCharacteristic         = "2a23"
+	modelNumberCharacteristic      = "2a24"
+	serialNumberCharacteristic     = "2a25"
+	firmwareRevisionCharacteristic = "2a26"
+	hardwareRevisionCharacteristic = "2a27"
+	softwareRevisionCharacteristic = "2a28"
+	manufacturerNameCharacteristic = "2a29"
+	pnpIDCharacteristic            = "2a50"
+
+	pnpIDLen = 7
+)
+
+// DeviceInfo denotes the information provided by the Device Information service of a scale
+// (fields not provided by the device are left empty)
+type DeviceInfo struct {
+	ManufacturerName string `json:"manufacturer_name,omitempty"`
+	ModelNumber      string `json:"model_number,omitempty"`
+	SerialNumber     string `json:"serial_number,omitempty"`
+	FirmwareRevision string `json:"firmware_revision,omitempty"`
+	HardwareRevision string `json:"hardware_revision,omitempty"`
+	SoftwareRevision string `json:"software_revision,omitempty"`
+	SystemID         string `json:"system_id,omitempty"`
+	PnPID            *PnPID `json:"pnp_id,omitempty"`
+}
+
+// PnPID denotes the Plug and Play ID of a device
+type PnPID struct {
+	VendorIDSource byte   `json:"vendor_id_source"`
+	VendorID       uint16 `json:"vendor_id"`
+	ProductID      uint16 `json:"product_id"`
+	ProductVersion uint16 `json:"product_version"`
+}
+
+// ReadDeviceInfo reads all characteristics of the Device Information service of a
+// peripheral (characteristics that cannot be read are skipped)
+func ReadDeviceInfo(p gatt.Peripheral, s *gatt.Service) (DeviceInfo, error) {
+
+	var info DeviceInfo
+
+	cs, err := p.DiscoverCharacteristics(nil, s)
+	if err != nil {
+		return info, fmt.Errorf("failed to discover device information characteristics: %w", err)
+	}
+	for _, c := range cs {
+		if c.Properties()&gatt.CharRead == 0 {
+			continue
+		}
+
+		value, err := p.ReadCharacteristic(c)
+		if err != nil {
+			continue
+		}
+		info.parse(c.UUID().String(), value)
+	}
+
+	return info, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (info *DeviceInfo) parse(uuid string, value []byte) {
+	switch uuid {
+	case manufacturerNameCharacteristic:
+		info.ManufacturerName = parseDeviceInfoString(value)
+	case modelNumberCharacteristic:
+		info.ModelNumber = parseDeviceInfoString(value)
+	case serialNumberCharacteristic:
+		info.SerialNumber = parseDeviceInfoString(value)
+	case firmwareRevisionCharacteristic:
+		info.FirmwareRevision = parseDeviceInfoString(value)
+	case hardwareRevisionCharacteristic:
+		info.HardwareRevision = parseDeviceInfoString(value)
+	case softwareRevisionCharacteristic:
+		info.SoftwareRevision = parseDeviceInfoString(value)
+	case systemIDCharacteristic:
+		info.SystemID = hex.EncodeToString(value)
+	case pnpIDCharacteristic:
+		if len(value) < pnpIDLen {
+			return
+		}
+		info.PnPID = &PnPID{
+			VendorIDSource: value[0],
+			VendorID:       binary.LittleEndian.Uint16(value[1:3]),
+			ProductID:      binary.LittleEndian.Uint16(value[3:5]),
+			ProductVersion: binary.LittleEndian.Uint16(value[5:7]),
+		}
+	}
+}
+
+// parseDeviceInfoString parses a (potentially zero-terminated) string characteristic
+func parseDeviceInfoString(value []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+}
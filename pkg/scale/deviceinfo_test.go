@@ -0,0 +1,48 @@
+package scale
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceInfo(t *testing.T) {
+	var info DeviceInfo
+	for uuid, value := range map[string][]byte{
+		manufacturerNameCharacteristic: []byte("Manufacturer Name\x00"),
+		modelNumberCharacteristic:      []byte("Model Number\x00"),
+		serialNumberCharacteristic:     []byte("Serial Number\x00"),
+		firmwareRevisionCharacteristic: []byte("Firmware Revision\x00"),
+		hardwareRevisionCharacteristic: []byte("Hardware Revision\x00"),
+		softwareRevisionCharacteristic: []byte("Software Revision"),
+		systemIDCharacteristic:         {0x41, 0x47, 0x00, 0x00, 0x00, 0x83, 0x15, 0x00},
+		pnpIDCharacteristic:            {0x01, 0x0d, 0x00, 0x00, 0x00, 0x10, 0x01},
+		"2a2a":                         []byte("\xfe\x00experimental"),
+	} {
+		info.parse(uuid, value)
+	}
+
+	expected := DeviceInfo{
+		ManufacturerName: "Manufacturer Name",
+		ModelNumber:      "Model Number",
+		SerialNumber:     "Serial Number",
+		FirmwareRevision: "Firmware Revision",
+		HardwareRevision: "Hardware Revision",
+		SoftwareRevision: "Software Revision",
+		SystemID:         "4147000000831500",
+		PnPID: &PnPID{
+			VendorIDSource: 1,
+			VendorID:       0x000d,
+			ProductID:      0,
+			ProductVersion: 0x0110,
+		},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("unexpected device information, want %+v, have %+v", expected, info)
+	}
+
+	// Truncated PnP IDs are ignored
+	info = DeviceInfo{}
+	if info.parse(pnpIDCharacteristic, []byte{0x01, 0x0d}); info.PnPID != nil {
+		t.Fatalf("unexpected PnP ID from truncated data: %+v", info.PnPID)
+	}
+}
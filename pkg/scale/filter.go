@@ -0,0 +1,221 @@
+package scale
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMedianFilterSize     = 5
+	defaultMovingAverageWindow  = 500 * time.Millisecond
+	defaultExponentialTimeConst = 500 * time.Millisecond
+	defaultOutlierMaxSlope      = 50.
+	defaultOutlierMaxRejections = 3
+)
+
+// Filter denotes a stage of a filter pipeline for weight readings. Filters are stateful,
+// hence an instance must only be used for a single stream of data points
+type Filter interface {
+
+	// Filter processes a data point and returns the filtered one (or false if the data
+	// point is discarded)
+	Filter(data DataPoint) (DataPoint, bool)
+}
+
+// Pipeline denotes a composition of filters applied in order (a data point discarded by
+// a filter is not passed on to subsequent ones). The original weight of each data point
+// is retained in its RawWeight field
+type Pipeline []Filter
+
+// Filter processes a data point by all filters of the pipeline and returns the filtered
+// one (or false if the data point is discarded)
+func (p Pipeline) Filter(data DataPoint) (DataPoint, bool) {
+	data.RawWeight = data.Weight
+	for _, filter := range p {
+		var ok bool
+		if data, ok = filter.Filter(data); !ok {
+			return data, false
+		}
+	}
+
+	return data, true
+}
+
+// MedianFilter replaces the weight by the median of the most recent data points,
+// suppressing short spikes (e.g. caused by bumping the scale). A change of unit restarts
+// the filter. The zero value is ready to use (with default settings)
+type MedianFilter struct {
+
+	// Size denotes the number of data points to determine the median of (0: 5)
+	Size int
+
+	history DataPoints
+
+	mu sync.Mutex
+}
+
+// Filter processes a data point and returns the filtered one
+func (f *MedianFilter) Filter(data DataPoint) (DataPoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	size := f.Size
+	if size <= 0 {
+		size = defaultMedianFilterSize
+	}
+
+	f.history = appendHistory(f.history, data)
+	if len(f.history) > size {
+		f.history = f.history[len(f.history)-size:]
+	}
+
+	weights := make([]float64, 0, len(f.history))
+	for _, dp := range f.history {
+		weights = append(weights, dp.Weight)
+	}
+	sort.Float64s(weights)
+
+	if n := len(weights); n%2 == 1 {
+		data.Weight = weights[n/2]
+	} else {
+		data.Weight = (weights[n/2-1] + weights[n/2]) / 2
+	}
+
+	return data, true
+}
+
+// MovingAverageFilter replaces the weight by the average weight within a time window. A
+// change of unit restarts the filter. The zero value is ready to use (with default
+// settings)
+type MovingAverageFilter struct {
+
+	// Window denotes the time window to average the weight across (0: 500ms)
+	Window time.Duration
+
+	history DataPoints
+
+	mu sync.Mutex
+}
+
+// Filter processes a data point and returns the filtered one
+func (f *MovingAverageFilter) Filter(data DataPoint) (DataPoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	window := f.Window
+	if window <= 0 {
+		window = defaultMovingAverageWindow
+	}
+
+	f.history = appendHistory(f.history, data)
+	for len(f.history) > 1 && data.TimeStamp.Sub(f.history[0].TimeStamp) >= window {
+		f.history = f.history[1:]
+	}
+
+	var sum float64
+	for _, dp := range f.history {
+		sum += dp.Weight
+	}
+	data.Weight = sum / float64(len(f.history))
+
+	return data, true
+}
+
+// ExponentialFilter exponentially smoothes the weight (taking into account the time
+// between data points). A change of unit restarts the filter. The zero value is ready to
+// use (with default settings)
+type ExponentialFilter struct {
+
+	// TimeConstant denotes the time constant of the exponential smoothing (0: 500ms)
+	TimeConstant time.Duration
+
+	last *DataPoint
+
+	mu sync.Mutex
+}
+
+// Filter processes a data point and returns the filtered one
+func (f *ExponentialFilter) Filter(data DataPoint) (DataPoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tau := f.TimeConstant
+	if tau <= 0 {
+		tau = defaultExponentialTimeConst
+	}
+
+	if f.last != nil && f.last.Unit == data.Unit {
+		if dt := data.TimeStamp.Sub(f.last.TimeStamp); dt > 0 {
+			alpha := 1 - math.Exp(-dt.Seconds()/tau.Seconds())
+			data.Weight = f.last.Weight + alpha*(data.Weight-f.last.Weight)
+		} else {
+			data.Weight = f.last.Weight
+		}
+	}
+	f.last = &data
+
+	return data, true
+}
+
+// OutlierFilter discards data points whose weight changed faster than physically
+// plausible compared to the last accepted one (e.g. spikes caused by bumping the scale).
+// Since placing an object on the scale causes a genuine abrupt change, a data point is
+// accepted once several consecutive data points were rejected. A change of unit restarts
+// the filter. The zero value is ready to use (with default settings)
+type OutlierFilter struct {
+
+	// MaxSlope denotes the maximum plausible rate of weight change (in grams per second,
+	// converted to the unit of the data points, 0: 50g/s)
+	MaxSlope float64
+
+	// MaxRejections denotes the number of consecutive rejected data points after which
+	// a change of weight is regarded as genuine (0: 3)
+	MaxRejections int
+
+	last       *DataPoint
+	rejections int
+
+	mu sync.Mutex
+}
+
+// Filter processes a data point and returns it (or false if it is discarded as outlier)
+func (f *OutlierFilter) Filter(data DataPoint) (DataPoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	maxSlope, maxRejections := f.MaxSlope, f.MaxRejections
+	if maxSlope <= 0 {
+		maxSlope = defaultOutlierMaxSlope
+	}
+	if maxRejections <= 0 {
+		maxRejections = defaultOutlierMaxRejections
+	}
+	if data.Unit == UnitOz {
+		maxSlope /= gramsPerOunce
+	}
+
+	if f.last != nil && f.last.Unit == data.Unit && f.rejections < maxRejections {
+		dt := data.TimeStamp.Sub(f.last.TimeStamp).Seconds()
+		if dt <= 0 || math.Abs(data.Weight-f.last.Weight)/dt > maxSlope {
+			f.rejections++
+			return data, false
+		}
+	}
+
+	f.last, f.rejections = &data, 0
+
+	return data, true
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// appendHistory appends a data point to a history, restarting it upon a change of unit
+func appendHistory(history DataPoints, data DataPoint) DataPoints {
+	if len(history) > 0 && history[len(history)-1].Unit != data.Unit {
+		history = history[:0]
+	}
+
+	return append(history, data)
+}
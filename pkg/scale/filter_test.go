@@ -0,0 +1,65 @@
+package scale
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	start := time.Now()
+	weights := []float64{10., 10.2, 10.1, 30., 10.3, 10.2, 10.4}
+
+	for _, c := range []struct {
+		name     string
+		filter   Filter
+		expected []float64
+	}{
+		{"median", &MedianFilter{Size: 3}, []float64{10., 10.1, 10.1, 10.2, 10.3, 10.3, 10.3}},
+		{"moving-average", &MovingAverageFilter{Window: 200 * time.Millisecond}, []float64{10., 10.1, 10.15, 20.05, 20.15, 10.25, 10.3}},
+		{"exponential", &ExponentialFilter{TimeConstant: time.Hour}, []float64{10., 10., 10., 10., 10., 10., 10.}},
+		{"outlier", &OutlierFilter{MaxSlope: 10.}, []float64{10., 10.2, 10.1, -1, 10.3, 10.2, 10.4}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			pipeline := Pipeline{c.filter}
+			for i, weight := range weights {
+				data, ok := pipeline.Filter(DataPoint{
+					TimeStamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+					Weight:    weight,
+					Unit:      UnitGrams,
+				})
+				if c.expected[i] < 0 {
+					if ok {
+						t.Fatalf("data point %d unexpectedly passed the filter: %+v", i, data)
+					}
+					continue
+				}
+				if !ok || math.Abs(data.Weight-c.expected[i]) > 1e-3 || data.RawWeight != weight {
+					t.Fatalf("unexpected filtered data point %d, want %v, have %+v", i, c.expected[i], data)
+				}
+			}
+		})
+	}
+}
+
+func TestOutlierFilterStep(t *testing.T) {
+	var (
+		start  = time.Now()
+		f      OutlierFilter
+		passed []float64
+	)
+
+	// A genuine step (e.g. placing a cup on the scale) must eventually pass the filter
+	for i, weight := range []float64{0., 0., 250., 250., 250., 250., 250.} {
+		if data, ok := f.Filter(DataPoint{
+			TimeStamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+			Weight:    weight,
+			Unit:      UnitGrams,
+		}); ok {
+			passed = append(passed, data.Weight)
+		}
+	}
+	if len(passed) != 4 || passed[2] != 250. {
+		t.Fatalf("unexpected data points passing the filter: %v", passed)
+	}
+}
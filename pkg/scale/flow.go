@@ -0,0 +1,296 @@
+package scale
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlowWindow  = time.Second
+	defaultFlowMaxRate = 30.
+)
+
+// Smoothing denotes the method used to smooth the flow rate
+type Smoothing int
+
+const (
+
+	// SmoothingMovingAverage averages the flow rate over the window
+	SmoothingMovingAverage Smoothing = iota
+
+	// SmoothingExponential exponentially weights the flow rate (using the window as time
+	// constant)
+	SmoothingExponential
+
+	// SmoothingSavitzkyGolay derives the flow rate from a local quadratic least squares fit
+	// of the weight over the window (Savitzky-Golay-style, supporting irregular timestamps)
+	SmoothingSavitzkyGolay
+)
+
+// String returns a string representation of the smoothing method
+func (s Smoothing) String() string {
+	switch s {
+	case SmoothingMovingAverage:
+		return "moving-average"
+	case SmoothingExponential:
+		return "exponential"
+	case SmoothingSavitzkyGolay:
+		return "savitzky-golay"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// FlowRate denotes the rate of weight change at a certain point in time
+type FlowRate struct {
+	TimeStamp time.Time `json:"timestamp"`
+
+	// Unit denotes the unit of the weight (the rate being measured in Unit per second)
+	Unit Unit `json:"unit"`
+
+	// Rate denotes the (smoothed) rate of weight change per second
+	Rate float64 `json:"rate"`
+}
+
+// FlowProcessor computes the (smoothed) flow rate from a stream of data points. Abrupt
+// weight changes (e.g. taring the scale or placing a cup on it) and changes of the unit
+// restart the computation. The zero value is ready to use (with default settings)
+type FlowProcessor struct {
+
+	// Smoothing denotes the smoothing method (default: moving average)
+	Smoothing Smoothing
+
+	// Window denotes the time window (or time constant for exponential smoothing) used
+	// for smoothing (0: 1s)
+	Window time.Duration
+
+	// MaxRate denotes the rate (in grams per second, converted to the unit of the data
+	// points) above which a change of weight is regarded as abrupt (0: 30g/s)
+	MaxRate float64
+
+	history DataPoints
+	current FlowRate
+
+	mu sync.Mutex
+}
+
+// Process computes the flow rate including a new data point (data points not advancing
+// in time are ignored) and returns it
+func (p *FlowProcessor) Process(data DataPoint) FlowRate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	window, maxRate := p.settings(data.Unit)
+
+	if len(p.history) > 0 {
+		last := p.history[len(p.history)-1]
+		dt := data.TimeStamp.Sub(last.TimeStamp).Seconds()
+		if dt <= 0 {
+			return p.current
+		}
+
+		// Restart upon a change of unit or an abrupt change of weight
+		if last.Unit != data.Unit || math.Abs(data.Weight-last.Weight)/dt > maxRate {
+			p.reset()
+		}
+	}
+
+	// Retain only the data points within the window (plus the last one before it,
+	// allowing to derive the rate across the full window)
+	p.history = append(p.history, data)
+	for len(p.history) > 2 && data.TimeStamp.Sub(p.history[1].TimeStamp) >= window {
+		p.history = p.history[1:]
+	}
+
+	p.current = FlowRate{
+		TimeStamp: data.TimeStamp,
+		Unit:      data.Unit,
+		Rate:      p.smooth(window),
+	}
+
+	return p.current
+}
+
+// Rate returns the current flow rate
+func (p *FlowProcessor) Rate() FlowRate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current
+}
+
+// Reset restarts the computation of the flow rate
+func (p *FlowProcessor) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+}
+
+// FlowRates returns the (smoothed) flow rate for each data point
+func (d DataPoints) FlowRates(smoothing Smoothing, window time.Duration) []FlowRate {
+	p := FlowProcessor{
+		Smoothing: smoothing,
+		Window:    window,
+	}
+
+	rates := make([]FlowRate, 0, len(d))
+	for _, data := range d {
+		rates = append(rates, p.Process(data))
+	}
+
+	return rates
+}
+
+// PeakFlow returns the maximum (smoothed) flow rate
+func (d DataPoints) PeakFlow(smoothing Smoothing, window time.Duration) (peak FlowRate) {
+	for _, rate := range d.FlowRates(smoothing, window) {
+		if rate.Rate > peak.Rate {
+			peak = rate
+		}
+	}
+
+	return
+}
+
+// AverageFlow returns the average flow rate between two points in time (intervals with
+// abrupt changes of weight, e.g. taring the scale, are disregarded)
+func (d DataPoints) AverageFlow(start, end time.Time) float64 {
+	var (
+		settings FlowProcessor
+		last     *DataPoint
+		delta    float64
+		duration float64
+	)
+
+	for i := range d {
+		data := &d[i]
+		if data.TimeStamp.Before(start) || data.TimeStamp.After(end) {
+			continue
+		}
+		if last != nil && data.Unit == last.Unit {
+			dt := data.TimeStamp.Sub(last.TimeStamp).Seconds()
+			if _, maxRate := settings.settings(data.Unit); dt > 0 && math.Abs(data.Weight-last.Weight)/dt <= maxRate {
+				delta += data.Weight - last.Weight
+				duration += dt
+			}
+		}
+		last = data
+	}
+
+	if duration == 0 {
+		return 0
+	}
+
+	return delta / duration
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// settings returns the window and the maximum rate converted to a unit
+func (p *FlowProcessor) settings(unit Unit) (time.Duration, float64) {
+	window, maxRate := p.Window, p.MaxRate
+	if window <= 0 {
+		window = defaultFlowWindow
+	}
+	if maxRate <= 0 {
+		maxRate = defaultFlowMaxRate
+	}
+	if unit == UnitOz {
+		maxRate /= gramsPerOunce
+	}
+
+	return window, maxRate
+}
+
+// reset restarts the computation (requires the lock to be held)
+func (p *FlowProcessor) reset() {
+	p.history = p.history[:0]
+	p.current.Rate = 0
+}
+
+// smooth computes the flow rate from the history (requires the lock to be held)
+func (p *FlowProcessor) smooth(window time.Duration) float64 {
+	n := len(p.history)
+	if n < 2 {
+		return 0
+	}
+	last, previous := p.history[n-1], p.history[n-2]
+
+	switch p.Smoothing {
+	case SmoothingExponential:
+		dt := last.TimeStamp.Sub(previous.TimeStamp).Seconds()
+		alpha := 1 - math.Exp(-dt/window.Seconds())
+		return p.current.Rate + alpha*((last.Weight-previous.Weight)/dt-p.current.Rate)
+	case SmoothingSavitzkyGolay:
+		if slope, ok := fitSlope(p.history); ok {
+			return slope
+		}
+	}
+
+	// Moving average (i.e. the average rate across the window)
+	first := p.history[0]
+	return (last.Weight - first.Weight) / last.TimeStamp.Sub(first.TimeStamp).Seconds()
+}
+
+// fitSlope fits a quadratic polynomial to the weight (falling back to a linear one for
+// less than four data points) via least squares and returns its slope at the last data
+// point
+func fitSlope(d DataPoints) (float64, bool) {
+	if len(d) < 3 {
+		return 0, false
+	}
+
+	degree := 2
+	if len(d) < 4 {
+		degree = 1
+	}
+
+	// Set up the normal equations (with time relative to the last data point)
+	var (
+		ref    = d[len(d)-1].TimeStamp
+		size   = degree + 1
+		matrix = make([][]float64, size)
+	)
+	for i := range matrix {
+		matrix[i] = make([]float64, size+1)
+	}
+	for _, data := range d {
+		x := data.TimeStamp.Sub(ref).Seconds()
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				matrix[i][j] += math.Pow(x, float64(i+j))
+			}
+			matrix[i][size] += math.Pow(x, float64(i)) * data.Weight
+		}
+	}
+
+	// Solve via Gaussian elimination with partial pivoting
+	for col := 0; col < size; col++ {
+		pivot := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(matrix[pivot][col]) < 1e-12 {
+			return 0, false
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+
+		for row := 0; row < size; row++ {
+			if row == col {
+				continue
+			}
+			factor := matrix[row][col] / matrix[col][col]
+			for k := col; k <= size; k++ {
+				matrix[row][k] -= factor * matrix[col][k]
+			}
+		}
+	}
+
+	// The slope at the last data point (x = 0) equals the linear coefficient
+	return matrix[1][size] / matrix[1][1], true
+}
@@ -0,0 +1,145 @@
+package scale
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const flowTestTolerance = 1e-6
+
+// rampDataPoints generates a weight increasing at a constant rate, with irregular
+// timestamps and a tare (i.e. the weight jumping back to zero) halfway through
+func rampDataPoints(start time.Time, rate float64) (DataPoints, time.Time) {
+	var (
+		data   DataPoints
+		offset time.Duration
+		weight float64
+		tareAt time.Time
+	)
+	for i := 0; i < 60; i++ {
+		if i == 30 {
+			weight, tareAt = 0, start.Add(offset)
+		}
+		data = append(data, DataPoint{
+			TimeStamp: start.Add(offset),
+			Weight:    weight,
+			Unit:      UnitGrams,
+		})
+
+		dt := time.Duration(50+50*(i%3)) * time.Millisecond
+		offset += dt
+		weight += rate * dt.Seconds()
+	}
+
+	return data, tareAt
+}
+
+func TestFlowProcessor(t *testing.T) {
+	start := time.Now()
+	data, tareAt := rampDataPoints(start, 2.)
+
+	for _, smoothing := range []Smoothing{SmoothingMovingAverage, SmoothingExponential, SmoothingSavitzkyGolay} {
+		t.Run(smoothing.String(), func(t *testing.T) {
+			p := FlowProcessor{
+				Smoothing: smoothing,
+				Window:    500 * time.Millisecond,
+			}
+
+			for i, dp := range data {
+				rate := p.Process(dp)
+				if !rate.TimeStamp.Equal(dp.TimeStamp) || rate.Unit != UnitGrams {
+					t.Fatalf("unexpected flow rate for data point %d: %+v", i, rate)
+				}
+
+				// The tare must restart the computation
+				if dp.TimeStamp.Equal(tareAt) && rate.Rate != 0 {
+					t.Fatalf("unexpected flow rate upon tare: %+v", rate)
+				}
+
+				// Data points not advancing in time must be ignored
+				if repeated := p.Process(dp); repeated != rate {
+					t.Fatalf("unexpected flow rate for repeated data point %d: %+v", i, repeated)
+				}
+			}
+
+			// The exponential smoothing converges to the actual rate, the others match
+			// it exactly (for a constant rate)
+			tolerance := flowTestTolerance
+			if smoothing == SmoothingExponential {
+				tolerance = 0.05
+			}
+			if rate := p.Rate(); math.Abs(rate.Rate-2.) > tolerance {
+				t.Fatalf("unexpected flow rate: %v", rate.Rate)
+			}
+
+			p.Reset()
+			if rate := p.Rate(); rate.Rate != 0 {
+				t.Fatalf("unexpected flow rate after reset: %v", rate.Rate)
+			}
+		})
+	}
+}
+
+func TestFlowProcessorUnit(t *testing.T) {
+	var (
+		p     FlowProcessor
+		start = time.Now()
+	)
+
+	// 2 oz/s exceed the default maximum rate of 30g/s
+	p.Process(DataPoint{TimeStamp: start, Weight: 1, Unit: UnitOz})
+	p.Process(DataPoint{TimeStamp: start.Add(500 * time.Millisecond), Weight: 1.25, Unit: UnitOz})
+	if rate := p.Process(DataPoint{TimeStamp: start.Add(time.Second), Weight: 1.5, Unit: UnitOz}); math.Abs(rate.Rate-0.5) > flowTestTolerance {
+		t.Fatalf("unexpected flow rate: %+v", rate)
+	}
+	if rate := p.Process(DataPoint{TimeStamp: start.Add(1500 * time.Millisecond), Weight: 2.5, Unit: UnitOz}); rate.Rate != 0 {
+		t.Fatalf("unexpected flow rate after abrupt change: %+v", rate)
+	}
+}
+
+func TestSavitzkyGolay(t *testing.T) {
+	var (
+		data  DataPoints
+		start = time.Now()
+	)
+
+	// For a quadratic weight curve (w = t^2), the slope is derived exactly
+	for _, ms := range []int{0, 100, 150, 300, 350, 500} {
+		x := float64(ms) / 1000.
+		data = append(data, DataPoint{TimeStamp: start.Add(time.Duration(ms) * time.Millisecond), Weight: x * x})
+	}
+	slope, ok := fitSlope(data)
+	if !ok || math.Abs(slope-1.) > flowTestTolerance {
+		t.Fatalf("unexpected slope: %v (%v)", slope, ok)
+	}
+
+	if _, ok := fitSlope(data[:2]); ok {
+		t.Fatalf("unexpectedly fitted slope for two data points")
+	}
+}
+
+func TestDataPointsFlow(t *testing.T) {
+	start := time.Now()
+	data, tareAt := rampDataPoints(start, 2.)
+
+	rates := data.FlowRates(SmoothingMovingAverage, time.Second)
+	if len(rates) != len(data) {
+		t.Fatalf("unexpected number of flow rates: %d", len(rates))
+	}
+	if peak := data.PeakFlow(SmoothingMovingAverage, time.Second); math.Abs(peak.Rate-2.) > flowTestTolerance {
+		t.Fatalf("unexpected peak flow: %+v", peak)
+	}
+
+	// The average flow disregards the tare
+	end := data[len(data)-1].TimeStamp
+	if avg := data.AverageFlow(start, end); math.Abs(avg-2.) > flowTestTolerance {
+		t.Fatalf("unexpected average flow: %v", avg)
+	}
+	if avg := data.AverageFlow(tareAt, end); math.Abs(avg-2.) > flowTestTolerance {
+		t.Fatalf("unexpected average flow after tare: %v", avg)
+	}
+	if avg := data.AverageFlow(end.Add(time.Second), end.Add(2*time.Second)); avg != 0 {
+		t.Fatalf("unexpected average flow outside of data: %v", avg)
+	}
+}
@@ -0,0 +1,207 @@
+package scale
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultRSSIThreshold   = -85
+	defaultRSSIHistorySize = 60
+
+	// Number of most recent RSSI samples averaged to assess the link quality
+	rssiAveragingSamples = 3
+
+	// Hysteresis (in dBm) required to regard a degraded link as recovered
+	rssiRecoveryHysteresis = 5
+
+	// RSSI values at or above this level are regarded as invalid (e.g. the underlying
+	// bluetooth library reports -1 if reading the RSSI is not supported)
+	invalidRSSI = -1
+)
+
+// RSSISample denotes a received signal strength measurement
+type RSSISample struct {
+	TimeStamp time.Time `json:"timestamp"`
+	RSSI      int       `json:"rssi"`
+}
+
+// FrameStats denotes statistics on the data frames received from a scale
+type FrameStats struct {
+
+	// Received denotes the number of valid frames received
+	Received uint64 `json:"received"`
+
+	// Invalid denotes the number of frames that could not be decoded
+	Invalid uint64 `json:"invalid"`
+
+	// Dropped denotes the number of notifications that failed on the bluetooth layer
+	Dropped uint64 `json:"dropped"`
+}
+
+// LinkMonitor keeps track of the link quality of a scale connection, logging a warning
+// once the (averaged) signal strength drops below a threshold. The zero value is ready
+// to use (with default settings)
+type LinkMonitor struct {
+
+	// Threshold denotes the RSSI (in dBm) below which the link is regarded as degraded
+	// (0: -85 dBm)
+	Threshold int
+
+	// HistorySize denotes the number of RSSI samples retained (0: 60)
+	HistorySize int
+
+	// Logger denotes the logger to emit link degradation warnings to (nil: none)
+	Logger Logger
+
+	history           []RSSISample
+	stats             FrameStats
+	isDegraded        bool
+	isRSSIUnsupported bool
+
+	mu sync.Mutex
+}
+
+// AddRSSI records an RSSI sample (invalid values, e.g. if reading the RSSI is not
+// supported on the current platform, are ignored) and returns if it was recorded
+func (m *LinkMonitor) AddRSSI(rssi int) bool {
+	if rssi >= invalidRSSI {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRSSIUnsupported {
+		return false
+	}
+
+	historySize := m.HistorySize
+	if historySize <= 0 {
+		historySize = defaultRSSIHistorySize
+	}
+	m.history = append(m.history, RSSISample{
+		TimeStamp: time.Now(),
+		RSSI:      rssi,
+	})
+	if len(m.history) > historySize {
+		m.history = append(m.history[:0], m.history[len(m.history)-historySize:]...)
+	}
+
+	m.assess()
+
+	return true
+}
+
+// SetRSSIUnsupported marks the signal strength as unobtainable while connected (e.g. on
+// platforms not supporting to read it), discarding all samples recorded so far (since
+// they would not reflect the current link quality) and ignoring any further ones
+func (m *LinkMonitor) SetRSSIUnsupported() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.history, m.isDegraded, m.isRSSIUnsupported = nil, false, true
+}
+
+// IsRSSISupported returns if the signal strength can be obtained while connected
+func (m *LinkMonitor) IsRSSISupported() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return !m.isRSSIUnsupported
+}
+
+// RSSI returns the most recent RSSI sample (in dBm, 0 if unknown or unsupported)
+func (m *LinkMonitor) RSSI() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.history) == 0 {
+		return 0
+	}
+
+	return m.history[len(m.history)-1].RSSI
+}
+
+// RSSIHistory returns a copy of the recorded RSSI samples (oldest first, empty if
+// unsupported)
+func (m *LinkMonitor) RSSIHistory() []RSSISample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RSSISample{}, m.history...)
+}
+
+// IsDegraded returns if the link is currently regarded as degraded
+func (m *LinkMonitor) IsDegraded() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.isDegraded
+}
+
+// FrameStats returns the current frame statistics
+func (m *LinkMonitor) FrameStats() FrameStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stats
+}
+
+// CountReceived increments the number of valid frames received
+func (m *LinkMonitor) CountReceived() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Received++
+}
+
+// CountInvalid increments the number of frames that could not be decoded
+func (m *LinkMonitor) CountInvalid() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Invalid++
+}
+
+// CountDropped increments the number of notifications that failed on the bluetooth layer
+func (m *LinkMonitor) CountDropped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Dropped++
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// assess updates the degradation state based on the average of the most recent samples
+// (requires the lock to be held)
+func (m *LinkMonitor) assess() {
+	threshold := m.Threshold
+	if threshold == 0 {
+		threshold = defaultRSSIThreshold
+	}
+
+	n := rssiAveragingSamples
+	if len(m.history) < n {
+		n = len(m.history)
+	}
+	sum := 0
+	for _, s := range m.history[len(m.history)-n:] {
+		sum += s.RSSI
+	}
+	avg := sum / n
+
+	switch {
+	case !m.isDegraded && avg < threshold:
+		m.isDegraded = true
+		if m.Logger != nil {
+			m.Logger.Warnf("bluetooth link degraded: RSSI %d dBm below threshold of %d dBm, readings may be lost", avg, threshold)
+		}
+	case m.isDegraded && avg >= threshold+rssiRecoveryHysteresis:
+		m.isDegraded = false
+		if m.Logger != nil {
+			m.Logger.Infof("bluetooth link recovered: RSSI %d dBm", avg)
+		}
+	}
+}
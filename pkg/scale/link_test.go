@@ -0,0 +1,73 @@
+package scale
+
+import (
+	"testing"
+)
+
+func TestLinkMonitor(t *testing.T) {
+	m := LinkMonitor{
+		Threshold:   -80,
+		HistorySize: 4,
+	}
+
+	if m.RSSI() != 0 || len(m.RSSIHistory()) != 0 {
+		t.Fatalf("unexpected initial RSSI: %d / %v", m.RSSI(), m.RSSIHistory())
+	}
+
+	// Invalid values (as reported if reading the RSSI is unsupported) should be ignored
+	for _, rssi := range []int{-1, 0, 10} {
+		if m.AddRSSI(rssi) {
+			t.Fatalf("unexpectedly recorded invalid RSSI %d", rssi)
+		}
+	}
+
+	for i, c := range []struct {
+		rssi       int
+		isDegraded bool
+	}{
+		{-60, false},
+		{-70, false},
+		{-95, false}, // average -75
+		{-95, true},  // average -86
+		{-70, true},  // average -86
+		{-65, true},  // average -76 (within hysteresis)
+		{-60, false}, // average -65
+	} {
+		if !m.AddRSSI(c.rssi) {
+			t.Fatalf("failed to record RSSI %d", c.rssi)
+		}
+		if m.IsDegraded() != c.isDegraded {
+			t.Fatalf("unexpected degradation state after sample %d: want %v, have %v", i, c.isDegraded, m.IsDegraded())
+		}
+	}
+
+	if m.RSSI() != -60 {
+		t.Fatalf("unexpected current RSSI: %d", m.RSSI())
+	}
+	history := m.RSSIHistory()
+	if len(history) != 4 || history[0].RSSI != -95 || history[3].RSSI != -60 {
+		t.Fatalf("unexpected RSSI history: %v", history)
+	}
+
+	// Once reading the RSSI turns out to be unsupported, no (stale) values are reported
+	m.AddRSSI(-95)
+	m.AddRSSI(-95)
+	if !m.IsDegraded() || !m.IsRSSISupported() {
+		t.Fatalf("unexpected link state: degraded %v, RSSI supported %v", m.IsDegraded(), m.IsRSSISupported())
+	}
+	m.SetRSSIUnsupported()
+	if m.AddRSSI(-60) {
+		t.Fatalf("unexpectedly recorded RSSI while unsupported")
+	}
+	if m.IsRSSISupported() || m.IsDegraded() || m.RSSI() != 0 || len(m.RSSIHistory()) != 0 {
+		t.Fatalf("unexpected link state while RSSI unsupported: degraded %v, RSSI %d / %v", m.IsDegraded(), m.RSSI(), m.RSSIHistory())
+	}
+
+	m.CountReceived()
+	m.CountReceived()
+	m.CountInvalid()
+	m.CountDropped()
+	if stats := m.FrameStats(); stats != (FrameStats{Received: 2, Invalid: 1, Dropped: 1}) {
+		t.Fatalf("unexpected frame statistics: %+v", stats)
+	}
+}
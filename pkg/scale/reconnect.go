@@ -0,0 +1,211 @@
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/fako1024/gatt"
+)
+
+const (
+	defaultReconnectInitialDelay = 100 * time.Millisecond
+	defaultReconnectMaxDelay     = 30 * time.Second
+	defaultReconnectMultiplier   = 2.
+	defaultReconnectScanTimeout  = 30 * time.Second
+)
+
+// ErrReconnectExhausted denotes that no further attempts are made to reconnect to a scale
+var ErrReconnectExhausted = errors.New("giving up reconnecting to scale")
+
+// NeverReconnect denotes a reconnect policy that never attempts to reconnect to a scale
+var NeverReconnect = ReconnectPolicy{MaxAttempts: -1}
+
+// ReconnectPolicy denotes the strategy to reconnect to a scale after the connection was
+// lost. Zero values are replaced by sensible defaults (i.e. the zero value denotes an
+// unlimited number of attempts with exponential backoff from 100ms up to 30s, scanning
+// for up to 30s per attempt)
+type ReconnectPolicy struct {
+
+	// InitialDelay denotes the delay before the first attempt
+	InitialDelay time.Duration
+
+	// MaxDelay denotes the upper limit for the delay between attempts
+	MaxDelay time.Duration
+
+	// Multiplier denotes the factor the delay grows by after each attempt
+	Multiplier float64
+
+	// Jitter denotes the maximum relative deviation from the delay (0: none, 1: up to 100%)
+	Jitter float64
+
+	// MaxAttempts limits the number of attempts (0: unlimited, negative: never reconnect)
+	MaxAttempts int
+
+	// ScanTimeout limits the time spent scanning for the scale per attempt, after which
+	// scanning is suspended until the next attempt (0: 30s, negative: scan until the scale
+	// is found, i.e. MaxAttempts and the backoff only apply to failed connections)
+	ScanTimeout time.Duration
+}
+
+// Delay returns the delay before a reconnection attempt (starting at 1) and if the
+// attempt should be made at all
+func (p ReconnectPolicy) Delay(attempt int) (time.Duration, bool) {
+	if p.MaxAttempts < 0 || p.MaxAttempts > 0 && attempt > p.MaxAttempts {
+		return 0, false
+	}
+
+	initialDelay, maxDelay, multiplier := p.InitialDelay, p.MaxDelay, p.Multiplier
+	if initialDelay <= 0 {
+		initialDelay = defaultReconnectInitialDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+	if multiplier <= 0 {
+		multiplier = defaultReconnectMultiplier
+	}
+
+	delay := math.Min(float64(initialDelay)*math.Pow(multiplier, float64(attempt-1)), float64(maxDelay))
+	if p.Jitter > 0 {
+		delay += delay * math.Min(p.Jitter, 1) * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay), true
+}
+
+// Reconnector keeps track of the attempts to reconnect to a scale according to a
+// ReconnectPolicy. The zero value is ready to use (with the default policy)
+type Reconnector struct {
+	Policy ReconnectPolicy
+
+	attempt   int
+	isRunning bool
+	lastErr   error
+
+	mu sync.Mutex
+}
+
+// Attempt returns the current reconnection attempt (0 if connected / not reconnecting)
+func (r *Reconnector) Attempt() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.attempt
+}
+
+// Status returns a connection status, reporting the current reconnection attempt only
+// while reconnecting
+func (r *Reconnector) Status(state State, err error) ConnectionStatus {
+	status := ConnectionStatus{
+		State: state,
+		Error: err,
+	}
+	if state == StateReconnecting {
+		status.Attempt = r.Attempt()
+	}
+
+	return status
+}
+
+// Reset resets the number of attempts (to be called once the scale is ready again)
+func (r *Reconnector) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempt = 0
+	r.lastErr = nil
+}
+
+// Run re-enables scanning for the scale according to the policy until the scale is
+// ready, no further attempts are to be made or the context ends. The status of each
+// attempt is reported via setStatus. If Run is called while it is already running (e.g.
+// upon a failed connection attempt), only the last error is updated
+func (r *Reconnector) Run(ctx context.Context, btDevice gatt.Device, ready *ReadySignal, lastErr error, setStatus func(state State, err error)) {
+
+	r.mu.Lock()
+	if lastErr != nil {
+		r.lastErr = lastErr
+	}
+	if r.isRunning {
+		r.mu.Unlock()
+		return
+	}
+	r.isRunning = true
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.isRunning = false
+		r.mu.Unlock()
+	}()
+
+	for ctx.Err() == nil {
+		r.mu.Lock()
+		r.attempt++
+		attempt, lastErr := r.attempt, r.lastErr
+		r.mu.Unlock()
+
+		delay, ok := r.Policy.Delay(attempt)
+		if !ok {
+			if lastErr != nil {
+				setStatus(StateDisconnected, fmt.Errorf("%w after %d attempt(s): %w", ErrReconnectExhausted, attempt-1, lastErr))
+			} else {
+				setStatus(StateDisconnected, fmt.Errorf("%w after %d attempt(s)", ErrReconnectExhausted, attempt-1))
+			}
+			return
+		}
+		setStatus(StateReconnecting, lastErr)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if err := btDevice.Scan([]gatt.UUID{}, false); err != nil {
+			r.setLastErr(fmt.Errorf("failed to re-enable scanning: %w", err))
+			continue
+		}
+
+		// Unless unlimited, suspend scanning if the scale is not found in time
+		scanTimeout := r.Policy.scanTimeout()
+		if scanTimeout < 0 {
+			return
+		}
+
+		scanCtx, cancel := context.WithTimeout(ctx, scanTimeout)
+		err := ready.Wait(scanCtx)
+		cancel()
+		if err == nil || errors.Is(err, ErrClosed) || ctx.Err() != nil {
+			return
+		}
+
+		if err := btDevice.StopScanning(); err != nil {
+			r.setLastErr(fmt.Errorf("failed to suspend scanning: %w", err))
+			continue
+		}
+		r.setLastErr(fmt.Errorf("scale not found within %v", scanTimeout))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (r *Reconnector) setLastErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastErr = err
+}
+
+func (p ReconnectPolicy) scanTimeout() time.Duration {
+	if p.ScanTimeout == 0 {
+		return defaultReconnectScanTimeout
+	}
+
+	return p.ScanTimeout
+}
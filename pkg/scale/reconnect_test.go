@@ -0,0 +1,65 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	for _, cs := range []struct {
+		policy   ReconnectPolicy
+		attempt  int
+		expected time.Duration
+		ok       bool
+	}{
+		{ReconnectPolicy{}, 1, 100 * time.Millisecond, true},
+		{ReconnectPolicy{}, 3, 400 * time.Millisecond, true},
+		{ReconnectPolicy{}, 100, 30 * time.Second, true},
+		{ReconnectPolicy{InitialDelay: time.Second, Multiplier: 3, MaxDelay: 5 * time.Second}, 2, 3 * time.Second, true},
+		{ReconnectPolicy{InitialDelay: time.Second, Multiplier: 3, MaxDelay: 5 * time.Second}, 3, 5 * time.Second, true},
+		{ReconnectPolicy{MaxAttempts: 2}, 2, 200 * time.Millisecond, true},
+		{ReconnectPolicy{MaxAttempts: 2}, 3, 0, false},
+		{NeverReconnect, 1, 0, false},
+	} {
+		delay, ok := cs.policy.Delay(cs.attempt)
+		if delay != cs.expected || ok != cs.ok {
+			t.Fatalf("unexpected delay for attempt %d of %+v, want %v/%v, have %v/%v", cs.attempt, cs.policy, cs.expected, cs.ok, delay, ok)
+		}
+	}
+}
+
+func TestReconnectPolicyJitter(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay, ok := policy.Delay(1)
+		if !ok || delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("unexpected delay with jitter: %v/%v", delay, ok)
+		}
+	}
+}
+
+func TestReconnectPolicyScanTimeout(t *testing.T) {
+	for _, cs := range []struct {
+		policy   ReconnectPolicy
+		expected time.Duration
+	}{
+		{ReconnectPolicy{}, 30 * time.Second},
+		{ReconnectPolicy{ScanTimeout: time.Second}, time.Second},
+		{ReconnectPolicy{ScanTimeout: -1}, -1},
+	} {
+		if timeout := cs.policy.scanTimeout(); timeout != cs.expected {
+			t.Fatalf("unexpected scan timeout for %+v, want %v, have %v", cs.policy, cs.expected, timeout)
+		}
+	}
+}
+
+func TestReconnectorStatus(t *testing.T) {
+	r := Reconnector{attempt: 3}
+
+	if status := r.Status(StateReconnecting, nil); status.Attempt != 3 {
+		t.Fatalf("unexpected attempt while reconnecting: %d", status.Attempt)
+	}
+	if status := r.Status(StateConnected, nil); status.Attempt != 0 || status.State != StateConnected {
+		t.Fatalf("unexpected status once connected: %+v", status)
+	}
+}
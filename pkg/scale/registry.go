@@ -0,0 +1,219 @@
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fako1024/gatt"
+)
+
+var (
+	registry   []Driver
+	registryMu sync.RWMutex
+
+	// ErrNoDrivers denotes that no (matching) driver has been registered
+	ErrNoDrivers = errors.New("no scale drivers registered")
+)
+
+// Driver denotes a scale driver that can be bound to a discovered peripheral
+type Driver struct {
+
+	// Name denotes the (unique) name of the driver
+	Name string
+
+	// Match determines if a discovered peripheral is supported by the driver
+	Match func(p gatt.Peripheral, a *gatt.Advertisement) bool
+
+	// New instantiates a new scale bound to the provided configuration
+	New func(cfg DriverConfig) (Basic, error)
+}
+
+// DriverConfig denotes the configuration passed to a driver when binding it to a
+// discovered peripheral
+type DriverConfig struct {
+	DeviceID        string
+	Device          gatt.Device
+	Logger          Logger
+	ReconnectPolicy ReconnectPolicy
+
+	DataDeliveryPolicy  DeliveryPolicy
+	StateDeliveryPolicy DeliveryPolicy
+
+	CanonicalUnit Unit
+	Filters       []Filter
+}
+
+// OpenOptions denotes the options for discovering and opening a scale
+type OpenOptions struct {
+
+	// Drivers restricts discovery to the named drivers (all registered drivers are
+	// considered if empty)
+	Drivers []string
+
+	// DeviceID restricts discovery to the peripheral with the given ID (e.g. a candidate
+	// picked from the results of Scan())
+	DeviceID string
+
+	// NewDevice provides the bluetooth device(s) used for discovery and by the driver
+	// (a default device is used if nil)
+	NewDevice func() (gatt.Device, error)
+
+	// Logger denotes the logger passed on to the driver
+	Logger Logger
+
+	// ReconnectPolicy denotes the reconnect policy passed on to the driver
+	ReconnectPolicy ReconnectPolicy
+
+	// DataDeliveryPolicy / StateDeliveryPolicy denote the delivery policies for data
+	// points / state changes passed on to the driver
+	DataDeliveryPolicy  DeliveryPolicy
+	StateDeliveryPolicy DeliveryPolicy
+
+	// CanonicalUnit denotes the unit all data points are converted to, regardless of the
+	// unit selected on the scale (empty: no conversion)
+	CanonicalUnit Unit
+
+	// Filters denotes the filter pipeline for the weight readings passed on to the driver
+	// (empty: no filtering)
+	Filters []Filter
+}
+
+// Register registers a driver for automatic discovery (usually called from the init()
+// function of the driver package). Drivers are matched in order of registration
+func Register(driver Driver) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, d := range registry {
+		if d.Name == driver.Name {
+			panic(fmt.Sprintf("scale driver `%s` registered twice", driver.Name))
+		}
+	}
+	registry = append(registry, driver)
+}
+
+// Drivers returns the names of all registered drivers
+func Drivers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for _, d := range registry {
+		names = append(names, d.Name)
+	}
+
+	return names
+}
+
+// Open scans for peripherals until one of them is supported by a registered driver (and
+// matches the requested device ID, if any), then binds the driver to it and returns the
+// scale. Depending on the capabilities of the driver, the returned scale may be type
+// asserted to WithTimer, WithBuzzer or Scale (or any of the optional interfaces, e.g.
+// Publisher)
+func Open(ctx context.Context, opts OpenOptions) (Basic, error) {
+
+	if opts.Logger == nil {
+		opts.Logger = &NullLogger{}
+	}
+	if opts.NewDevice == nil {
+		opts.NewDevice = newDefaultDevice
+	}
+
+	drivers := selectDrivers(opts.Drivers)
+	if len(drivers) == 0 {
+		return nil, ErrNoDrivers
+	}
+
+	driver, deviceID, err := discover(ctx, drivers, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	btDevice, err := opts.NewDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Logger.Debugf("binding driver `%s` to device `%s`", driver.Name, deviceID)
+	s, err := driver.New(DriverConfig{
+		DeviceID:        deviceID,
+		Device:          btDevice,
+		Logger:          opts.Logger,
+		ReconnectPolicy: opts.ReconnectPolicy,
+
+		DataDeliveryPolicy:  opts.DataDeliveryPolicy,
+		StateDeliveryPolicy: opts.StateDeliveryPolicy,
+
+		CanonicalUnit: opts.CanonicalUnit,
+		Filters:       opts.Filters,
+	})
+	if err != nil {
+		if closeErr := btDevice.Close(); closeErr != nil {
+			opts.Logger.Warnf("failed to close device: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func discover(ctx context.Context, drivers []Driver, opts OpenOptions) (Driver, string, error) {
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	candidates, err := scan(scanCtx, drivers, opts)
+	if err != nil {
+		return Driver{}, "", err
+	}
+
+	// Ensure that the scanning device has been closed before returning
+	defer func() {
+		cancel()
+		for range candidates {
+		}
+	}()
+
+	for c := range candidates {
+		if !c.IsSupported() || !matchesDeviceID(c, opts.DeviceID) {
+			continue
+		}
+		for _, d := range drivers {
+			if d.Name == c.Driver {
+				return d, c.ID, nil
+			}
+		}
+	}
+
+	return Driver{}, "", ctx.Err()
+}
+
+func selectDrivers(names []string) []Driver {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if len(names) == 0 {
+		return append([]Driver{}, registry...)
+	}
+
+	var drivers []Driver
+	for _, d := range registry {
+		for _, name := range names {
+			if strings.EqualFold(d.Name, name) {
+				drivers = append(drivers, d)
+				break
+			}
+		}
+	}
+
+	return drivers
+}
+
+func newDefaultDevice() (gatt.Device, error) {
+	return gatt.NewDevice(defaultBTClientOptions...)
+}
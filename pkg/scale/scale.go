@@ -1,6 +1,9 @@
 package scale
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Basic denotes a basic coffee scale
 type Basic interface {
@@ -27,15 +30,22 @@ type Basic interface {
 	TogglePrecision() error
 
 	// SetStateChangeHandler defines a handler function that is called upon state change
+	// (replacing any previous one, see Publisher for multiple consumers)
 	SetStateChangeHandler(fn func(status ConnectionStatus))
 
-	// SetStateChangeChannel defines a handler function that is called upon state change
+	// SetStateChangeChannel defines a channel that state changes are put on (replacing
+	// any previous one, see Publisher for multiple consumers). By default, a state
+	// change is dropped if the consumer does not keep up (see Publisher.DeliveryStats())
 	SetStateChangeChannel(ch chan ConnectionStatus)
 
 	// SetDataHandler defines a handler function that is called upon retrieval of data
+	// (replacing any previous one, see Publisher for multiple consumers)
 	SetDataHandler(fn func(data DataPoint))
 
-	// SetDataChannel defines a handler function that is called upon retrieval of data
+	// SetDataChannel defines a channel that data points are put on (replacing any
+	// previous one, see Publisher for multiple consumers). By default, the oldest
+	// buffered data point is dropped if the consumer does not keep up (see
+	// Publisher.DeliveryStats())
 	SetDataChannel(ch chan DataPoint)
 
 	// Close terminates the connection to the device
@@ -71,6 +81,78 @@ type Timer interface {
 	ElapsedTime() time.Duration
 }
 
+// Stability denotes weight stability detection functionality (data points are annotated
+// with their stability accordingly)
+type Stability interface {
+
+	// IsStable returns if the weight is currently stable
+	IsStable() bool
+
+	// SetStabilityChangeHandler defines a handler function that is called once the weight
+	// becomes stable / unstable
+	SetStabilityChangeHandler(fn func(event StabilityEvent))
+}
+
+// LinkQuality denotes link quality reporting functionality (signal strength and
+// frame statistics). Note that on Linux the underlying bluetooth library cannot read the
+// signal strength of a connected peripheral, hence only the frame statistics are
+// available there (the library only implements reading it on macOS)
+type LinkQuality interface {
+
+	// IsRSSISupported returns if the received signal strength can be obtained while
+	// connected (if not, e.g. on Linux, RSSI() and RSSIHistory() do not report any values)
+	IsRSSISupported() bool
+
+	// RSSI returns the most recent received signal strength (in dBm, 0 if unknown or
+	// unsupported)
+	RSSI() int
+
+	// RSSIHistory returns the recent received signal strength samples (oldest first)
+	RSSIHistory() []RSSISample
+
+	// FrameStats returns statistics on the data frames received from the scale
+	FrameStats() FrameStats
+}
+
+// Publisher denotes functionality to deliver the data points and state changes of the
+// scale to any number of consumers
+type Publisher interface {
+
+	// Subscribe registers a new subscription to the data points and state changes of the
+	// scale (any number of subscriptions may exist simultaneously)
+	Subscribe(options ...func(*Subscription)) *Subscription
+
+	// DeliveryStats returns the number of data points / state changes dropped so far
+	// (according to the delivery policy)
+	DeliveryStats() DeliveryStats
+}
+
+// ConnectionWaiter denotes functionality to wait for the scale to become usable
+type ConnectionWaiter interface {
+
+	// WaitConnected blocks until the scale is connected and usable (or the context ends)
+	WaitConnected(ctx context.Context) error
+}
+
+// DeviceInformation denotes functionality to obtain information on the scale device
+type DeviceInformation interface {
+
+	// DeviceInfo returns the information provided by the Device Information service of
+	// the scale (populated upon connection)
+	DeviceInfo() DeviceInfo
+}
+
+// PrecisionControl denotes functionality to query / set the weight precision explicitly
+// (in addition to toggling it)
+type PrecisionControl interface {
+
+	// Precision returns the current weight precision
+	Precision() Precision
+
+	// SetPrecision sets the weight precision
+	SetPrecision(p Precision) error
+}
+
 // WithTimer denotes a scale with timer functionality
 type WithTimer interface {
 	Basic
@@ -0,0 +1,11 @@
+package scale
+
+import "github.com/fako1024/gatt"
+
+var (
+	defaultBTClientOptions = []gatt.Option{}
+)
+
+func adapterBTClientOptions(_ int) []gatt.Option {
+	return []gatt.Option{}
+}
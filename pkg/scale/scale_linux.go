@@ -0,0 +1,17 @@
+package scale
+
+import "github.com/fako1024/gatt"
+
+var (
+	defaultBTClientOptions = []gatt.Option{
+		gatt.LnxMaxConnections(1),
+		gatt.LnxDeviceID(-1, true),
+	}
+)
+
+func adapterBTClientOptions(maxConnections int) []gatt.Option {
+	return []gatt.Option{
+		gatt.LnxMaxConnections(maxConnections),
+		gatt.LnxDeviceID(-1, true),
+	}
+}
@@ -0,0 +1,166 @@
+package scale
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fako1024/gatt"
+)
+
+const candidateBufferSize = 64
+
+// Candidate denotes a peripheral discovered while scanning, potentially being a scale
+type Candidate struct {
+
+	// ID denotes the ID (on Linux: the MAC address) of the peripheral
+	ID string
+
+	// Name denotes the (local) name of the peripheral
+	Name string
+
+	// RSSI denotes the received signal strength (in dBm)
+	RSSI int
+
+	// TxPowerLevel denotes the advertised transmission power level (in dBm)
+	TxPowerLevel int
+
+	// ManufacturerData denotes the manufacturer specific advertisement data
+	ManufacturerData []byte
+
+	// Services denotes the advertised services
+	Services []string
+
+	// Connectable denotes if the peripheral accepts connections
+	Connectable bool
+
+	// Driver denotes the name of the first registered driver supporting the peripheral
+	// (empty if unsupported)
+	Driver string
+
+	// TimeStamp denotes the time the peripheral was discovered
+	TimeStamp time.Time
+}
+
+// IsSupported returns if the candidate is supported by any registered driver
+func (c Candidate) IsSupported() bool {
+	return c.Driver != ""
+}
+
+// Scan scans for peripherals and streams each discovered one as candidate (flagging
+// whether a registered driver supports it) until the context ends, upon which the returned
+// channel is closed. A peripheral may be reported repeatedly (e.g. with updated RSSI). To
+// connect to a candidate, pass its ID via OpenOptions.DeviceID to Open()
+func Scan(ctx context.Context, opts OpenOptions) (<-chan Candidate, error) {
+
+	if opts.Logger == nil {
+		opts.Logger = &NullLogger{}
+	}
+	if opts.NewDevice == nil {
+		opts.NewDevice = newDefaultDevice
+	}
+
+	return scan(ctx, selectDrivers(opts.Drivers), opts)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func scan(ctx context.Context, drivers []Driver, opts OpenOptions) (<-chan Candidate, error) {
+
+	btDevice, err := opts.NewDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		candidates = make(chan Candidate, candidateBufferSize)
+		isClosed   bool
+		mu         sync.Mutex
+	)
+
+	// Candidates are dropped (instead of blocking the bluetooth device) if the consumer
+	// does not keep up
+	HandlePeripherals(btDevice, func(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+		opts.Logger.Debugf("discovered device `%s/%s`", p.Name(), p.ID())
+
+		c := newCandidate(p, a, rssi, drivers)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if isClosed {
+			return
+		}
+		select {
+		case candidates <- c:
+		default:
+			opts.Logger.Debugf("dropping candidate `%s/%s`", c.Name, c.ID)
+		}
+	}, nil, nil)
+
+	if err := btDevice.Init(func(d gatt.Device, s gatt.State) {
+		if s != gatt.StatePoweredOn {
+			return
+		}
+		if err := d.Scan([]gatt.UUID{}, false); err != nil {
+			opts.Logger.Warnf("failed to enable scanning: %s", err)
+		}
+	}); err != nil {
+		if closeErr := btDevice.Close(); closeErr != nil {
+			opts.Logger.Warnf("failed to close scanning device: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		_ = btDevice.StopScanning()
+		if err := btDevice.Close(); err != nil {
+			opts.Logger.Warnf("failed to close scanning device: %s", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		isClosed = true
+		close(candidates)
+	}()
+
+	return candidates, nil
+}
+
+func newCandidate(p gatt.Peripheral, a *gatt.Advertisement, rssi int, drivers []Driver) Candidate {
+	c := Candidate{
+		ID:        p.ID(),
+		Name:      p.Name(),
+		RSSI:      rssi,
+		TimeStamp: time.Now(),
+	}
+
+	if a != nil {
+		if c.Name == "" {
+			c.Name = a.LocalName
+		}
+		c.TxPowerLevel = a.TxPowerLevel
+		c.ManufacturerData = append([]byte{}, a.ManufacturerData...)
+		c.Connectable = a.Connectable
+		for _, s := range a.Services {
+			c.Services = append(c.Services, s.String())
+		}
+	}
+
+	for _, d := range drivers {
+		if d.Match(p, a) {
+			c.Driver = d.Name
+			break
+		}
+	}
+
+	return c
+}
+
+func matchesDeviceID(c Candidate, deviceID string) bool {
+	return deviceID == "" || strings.EqualFold(c.ID, deviceID)
+}
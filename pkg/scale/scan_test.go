@@ -0,0 +1,133 @@
+package scale
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fako1024/gatt"
+)
+
+const testDriverName = "test"
+
+var errTestDriver = errors.New("test driver instantiated")
+
+func init() {
+	Register(Driver{
+		Name: testDriverName,
+		Match: func(p gatt.Peripheral, _ *gatt.Advertisement) bool {
+			return strings.HasPrefix(p.Name(), "SCALE")
+		},
+		New: func(cfg DriverConfig) (Basic, error) {
+			return nil, &testDriverError{deviceID: cfg.DeviceID}
+		},
+	})
+}
+
+type testDriverError struct {
+	deviceID string
+}
+
+func (e *testDriverError) Error() string        { return errTestDriver.Error() }
+func (e *testDriverError) Is(target error) bool { return target == errTestDriver }
+
+func TestScan(t *testing.T) {
+	a := NewAdapterFromDevice(&fakeDevice{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	candidates, err := Scan(ctx, OpenOptions{
+		Drivers: []string{testDriverName},
+		NewDevice: func() (gatt.Device, error) {
+			return a.Device(), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to scan: %s", err)
+	}
+
+	a.onPeriphDiscovered(&fakePeripheral{id: "SCALE-1"}, &gatt.Advertisement{
+		TxPowerLevel:     4,
+		ManufacturerData: []byte{44, 29, 136, 160},
+		Connectable:      true,
+	}, -42)
+	a.onPeriphDiscovered(&fakePeripheral{id: "HEADPHONES"}, &gatt.Advertisement{}, -70)
+
+	c := <-candidates
+	if c.ID != "SCALE-1" || c.RSSI != -42 || c.TxPowerLevel != 4 || !c.Connectable ||
+		!bytes.Equal(c.ManufacturerData, []byte{44, 29, 136, 160}) || c.Driver != testDriverName {
+		t.Fatalf("unexpected supported candidate: %+v", c)
+	}
+	if c = <-candidates; c.ID != "HEADPHONES" || c.IsSupported() {
+		t.Fatalf("unexpected unsupported candidate: %+v", c)
+	}
+
+	cancel()
+	for range candidates {
+	}
+}
+
+// failingDevice denotes a device that cannot be initialized
+type failingDevice struct {
+	fakeDevice
+	isClosed bool
+}
+
+func (d *failingDevice) Init(stateChanged func(gatt.Device, gatt.State)) error {
+	return errors.New("failed to initialize device")
+}
+
+func (d *failingDevice) Close() error {
+	d.isClosed = true
+	return nil
+}
+
+func TestScanInitFailure(t *testing.T) {
+	btDevice := &failingDevice{}
+
+	if _, err := Scan(context.Background(), OpenOptions{
+		NewDevice: func() (gatt.Device, error) {
+			return btDevice, nil
+		},
+	}); err == nil {
+		t.Fatalf("expected error scanning with a failing device")
+	}
+	if !btDevice.isClosed {
+		t.Fatalf("device not closed after failing to initialize it")
+	}
+}
+
+func TestOpenDeviceID(t *testing.T) {
+	btDevice := &fakeDevice{}
+	a := NewAdapterFromDevice(btDevice)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errChan := make(chan error)
+	go func() {
+		_, err := Open(ctx, OpenOptions{
+			Drivers:  []string{testDriverName},
+			DeviceID: "scale-2",
+			NewDevice: func() (gatt.Device, error) {
+				return a.Device(), nil
+			},
+		})
+		errChan <- err
+	}()
+
+	for !btDevice.scanning() {
+		time.Sleep(time.Millisecond)
+	}
+	a.onPeriphDiscovered(&fakePeripheral{id: "SCALE-1"}, &gatt.Advertisement{}, -42)
+	a.onPeriphDiscovered(&fakePeripheral{id: "SCALE-2"}, &gatt.Advertisement{}, -50)
+
+	var driverErr *testDriverError
+	if err := <-errChan; !errors.As(err, &driverErr) || driverErr.deviceID != "SCALE-2" {
+		t.Fatalf("unexpected result of opening scale by ID: %v", err)
+	}
+}
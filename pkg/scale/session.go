@@ -0,0 +1,314 @@
+package scale
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionStartThreshold = 1.
+	defaultSessionStartDuration  = 500 * time.Millisecond
+	defaultSessionStopFlowRate   = 0.1
+)
+
+var (
+
+	// ErrSessionActive denotes that a session is already being recorded
+	ErrSessionActive = errors.New("session already active")
+
+	// ErrNoSession denotes that no session is being recorded
+	ErrNoSession = errors.New("no active session")
+
+	// ErrNoData denotes that no data has been received yet
+	ErrNoData = errors.New("no data received yet")
+)
+
+// Session denotes a recorded brew process (e.g. an espresso shot or a pour-over)
+type Session struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Unit     Unit          `json:"unit"`
+
+	// Dose denotes the weight on the scale when the session started (e.g. the coffee dose
+	// if the scale was tared with the empty brewer only)
+	Dose float64 `json:"dose"`
+
+	// Yield denotes the weight gained during the session
+	Yield float64 `json:"yield"`
+
+	// DataPoints denotes all data points captured during the session
+	DataPoints DataPoints `json:"data_points"`
+}
+
+// Recorder records brew sessions from the data points of a scale. A session starts
+// automatically once the weight increased above a threshold for some time and ends once
+// the flow stopped and the weight stabilized (manually started sessions only end upon
+// Stop()). The zero value is ready to use (with default settings)
+type Recorder struct {
+
+	// StartThreshold denotes the weight increase (in grams, converted to the unit of the
+	// data points) required to start a session (0: 1g)
+	StartThreshold float64
+
+	// StartDuration denotes the time the weight increase has to be sustained to start a
+	// session (0: 500ms)
+	StartDuration time.Duration
+
+	// StopFlowRate denotes the flow rate (in grams per second, converted to the unit of the
+	// data points) below which the flow is regarded as stopped (0: 0.1g/s)
+	StopFlowRate float64
+
+	// Timer denotes the timer started / stopped along with a session (nil: none)
+	Timer Timer
+
+	// Logger denotes the logger to emit timer errors to (nil: none)
+	Logger Logger
+
+	handler   func(session Session)
+	flow      FlowProcessor
+	stability StabilityDetector
+
+	last     *DataPoint
+	baseline *DataPoint
+	pending  DataPoints
+	session  *Session
+	isManual bool
+
+	mu sync.Mutex
+}
+
+// SetHandler defines a handler function that is called upon completion of a session
+func (r *Recorder) SetHandler(fn func(session Session)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handler = fn
+}
+
+// IsRecording returns if a session is currently being recorded
+func (r *Recorder) IsRecording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.session != nil
+}
+
+// Run processes all data points of a subscription until its channel is closed
+func (r *Recorder) Run(sub *Subscription) {
+	for data := range sub.Data() {
+		r.Process(data)
+	}
+}
+
+// Process processes a data point, starting / ending a session automatically (if
+// applicable)
+func (r *Recorder) Process(data DataPoint) {
+	r.mu.Lock()
+
+	rate := r.flow.Process(data)
+	data.Stable = r.stability.Process(data).Stable
+	last := r.last
+	r.last = &data
+
+	// Capture data while recording, ending the session once the flow stopped and the
+	// weight stabilized (unless started manually)
+	if r.session != nil {
+		r.session.DataPoints = append(r.session.DataPoints, data)
+		if r.isManual || !data.Stable || math.Abs(rate.Rate) >= r.stopFlowRate(data.Unit) {
+			r.mu.Unlock()
+			return
+		}
+
+		session := r.finish(data)
+		r.mu.Unlock()
+
+		r.complete(session)
+		return
+	}
+
+	// Track the baseline weight, restarting upon abrupt changes (e.g. placing a cup on the
+	// scale or taring it) or a change of unit
+	if r.baseline == nil || last == nil || isAbrupt(*last, data, &r.flow) || data.Unit != r.baseline.Unit {
+		r.baseline, r.pending = &data, nil
+		r.mu.Unlock()
+		return
+	}
+
+	// Start a session once the weight increase has been sustained for long enough
+	if data.Weight-r.baseline.Weight <= r.startThreshold(data.Unit) {
+		r.pending = nil
+		if data.Stable || data.Weight < r.baseline.Weight {
+			r.baseline = &data
+		}
+		r.mu.Unlock()
+		return
+	}
+	r.pending = append(r.pending, data)
+	if data.TimeStamp.Sub(r.pending[0].TimeStamp) < r.startDuration() {
+		r.mu.Unlock()
+		return
+	}
+
+	r.begin(*r.baseline, false)
+	r.mu.Unlock()
+
+	r.startTimer()
+}
+
+// Start manually starts a session (which only ends upon Stop())
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+
+	if r.session != nil {
+		r.mu.Unlock()
+		return ErrSessionActive
+	}
+	if r.last == nil {
+		r.mu.Unlock()
+		return ErrNoData
+	}
+
+	r.pending = nil
+	r.begin(*r.last, true)
+	r.mu.Unlock()
+
+	r.startTimer()
+
+	return nil
+}
+
+// Stop ends the current session, returning it (the handler function, if any, is called
+// as well)
+func (r *Recorder) Stop() (Session, error) {
+	r.mu.Lock()
+
+	if r.session == nil {
+		r.mu.Unlock()
+		return Session{}, ErrNoSession
+	}
+
+	session := r.finish(*r.last)
+	r.mu.Unlock()
+
+	r.complete(session)
+
+	return session, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// begin starts a new session from a baseline data point, including any pending data
+// points (requires the lock to be held)
+func (r *Recorder) begin(baseline DataPoint, isManual bool) {
+	r.session = &Session{
+		Start:      baseline.TimeStamp,
+		Unit:       baseline.Unit,
+		Dose:       baseline.Weight,
+		DataPoints: append(DataPoints{baseline}, r.pending...),
+	}
+	r.isManual = isManual
+	r.pending = nil
+}
+
+// finish ends the current session at a data point and returns it (requires the lock to
+// be held)
+func (r *Recorder) finish(data DataPoint) Session {
+	session := *r.session
+	session.End = data.TimeStamp
+	session.Duration = session.End.Sub(session.Start)
+	session.Yield = data.Weight - session.Dose
+
+	r.session, r.isManual = nil, false
+	r.baseline, r.pending = &data, nil
+
+	return session
+}
+
+func (r *Recorder) complete(session Session) {
+	r.stopTimer()
+
+	r.mu.Lock()
+	handler := r.handler
+	r.mu.Unlock()
+
+	// Call handler function, if any
+	if handler != nil {
+		handler(session)
+	}
+}
+
+func (r *Recorder) startTimer() {
+	if r.Timer == nil {
+		return
+	}
+
+	if err := r.Timer.ResetTimer(); err != nil {
+		r.logf("failed to reset timer: %s", err)
+	}
+	if err := r.Timer.StartTimer(); err != nil {
+		r.logf("failed to start timer: %s", err)
+	}
+}
+
+func (r *Recorder) stopTimer() {
+	if r.Timer == nil {
+		return
+	}
+
+	if err := r.Timer.StopTimer(); err != nil {
+		r.logf("failed to stop timer: %s", err)
+	}
+}
+
+func (r *Recorder) logf(format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Warnf(format, args...)
+	}
+}
+
+func (r *Recorder) startThreshold(unit Unit) float64 {
+	threshold := r.StartThreshold
+	if threshold <= 0 {
+		threshold = defaultSessionStartThreshold
+	}
+	if unit == UnitOz {
+		threshold /= gramsPerOunce
+	}
+
+	return threshold
+}
+
+func (r *Recorder) startDuration() time.Duration {
+	if r.StartDuration <= 0 {
+		return defaultSessionStartDuration
+	}
+
+	return r.StartDuration
+}
+
+func (r *Recorder) stopFlowRate(unit Unit) float64 {
+	rate := r.StopFlowRate
+	if rate <= 0 {
+		rate = defaultSessionStopFlowRate
+	}
+	if unit == UnitOz {
+		rate /= gramsPerOunce
+	}
+
+	return rate
+}
+
+// isAbrupt determines if the weight changed abruptly between two data points (according
+// to the maximum rate of a flow processor)
+func isAbrupt(previous, current DataPoint, p *FlowProcessor) bool {
+	dt := current.TimeStamp.Sub(previous.TimeStamp).Seconds()
+	if dt <= 0 {
+		return false
+	}
+	_, maxRate := p.settings(current.Unit)
+
+	return math.Abs(current.Weight-previous.Weight)/dt > maxRate
+}
@@ -0,0 +1,112 @@
+package scale
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type testTimer struct {
+	started, stopped, reset int
+}
+
+func (t *testTimer) StartTimer() error          { t.started++; return nil }
+func (t *testTimer) StopTimer() error           { t.stopped++; return nil }
+func (t *testTimer) ResetTimer() error          { t.reset++; return nil }
+func (t *testTimer) ElapsedTime() time.Duration { return 0 }
+
+// shotDataPoints generates an idle phase, a (sustained) increase of weight at a constant
+// rate and a final idle phase (sampled at 10Hz)
+func shotDataPoints(start time.Time, dose, rate float64, idle, brew time.Duration) DataPoints {
+	var (
+		data   DataPoints
+		weight = dose
+	)
+	for offset := time.Duration(0); offset <= 2*idle+brew; offset += 100 * time.Millisecond {
+		if offset > idle && offset <= idle+brew {
+			weight += rate * 0.1
+		}
+		data = append(data, DataPoint{
+			TimeStamp: start.Add(offset),
+			Weight:    weight,
+			Unit:      UnitGrams,
+		})
+	}
+
+	return data
+}
+
+func TestRecorderAutomatic(t *testing.T) {
+	var (
+		timer    testTimer
+		sessions []Session
+		start    = time.Now()
+	)
+	r := Recorder{Timer: &timer}
+	r.SetHandler(func(session Session) {
+		sessions = append(sessions, session)
+	})
+
+	// Placing the brewer on the scale must not start a session
+	r.Process(DataPoint{TimeStamp: start.Add(-100 * time.Millisecond), Weight: 0, Unit: UnitGrams})
+	for _, data := range shotDataPoints(start, 18., 3., 2*time.Second, 5*time.Second) {
+		r.Process(data)
+		if data.TimeStamp.Sub(start) == 4*time.Second && !r.IsRecording() {
+			t.Fatalf("session was not started during sustained weight increase")
+		}
+	}
+
+	if len(sessions) != 1 {
+		t.Fatalf("unexpected number of sessions: %d", len(sessions))
+	}
+	session := sessions[0]
+	if session.Start != start.Add(2*time.Second) || session.Dose != 18. || math.Abs(session.Yield-15.) > 1e-6 {
+		t.Fatalf("unexpected session: start %v, dose %v, yield %v", session.Start.Sub(start), session.Dose, session.Yield)
+	}
+	if session.End.Before(start.Add(7*time.Second)) || session.Duration != session.End.Sub(session.Start) || session.Unit != UnitGrams {
+		t.Fatalf("unexpected session end: %v (duration %v)", session.End.Sub(start), session.Duration)
+	}
+	if len(session.DataPoints) == 0 || session.DataPoints[0].TimeStamp != session.Start ||
+		session.DataPoints[len(session.DataPoints)-1].TimeStamp != session.End {
+		t.Fatalf("unexpected session data points: %v", session.DataPoints)
+	}
+	if timer != (testTimer{started: 1, stopped: 1, reset: 1}) {
+		t.Fatalf("unexpected timer interaction: %+v", timer)
+	}
+	if r.IsRecording() {
+		t.Fatalf("session still recording after completion")
+	}
+}
+
+func TestRecorderManual(t *testing.T) {
+	var r Recorder
+
+	if err := r.Start(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("unexpected error starting session without data: %v", err)
+	}
+	if _, err := r.Stop(); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("unexpected error stopping without session: %v", err)
+	}
+
+	start := time.Now()
+	r.Process(DataPoint{TimeStamp: start, Weight: 5, Unit: UnitGrams})
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start session: %s", err)
+	}
+	if err := r.Start(); !errors.Is(err, ErrSessionActive) {
+		t.Fatalf("unexpected error starting session twice: %v", err)
+	}
+
+	// A manual session must not end automatically
+	for i := 1; i <= 30; i++ {
+		r.Process(DataPoint{TimeStamp: start.Add(time.Duration(i) * 100 * time.Millisecond), Weight: 7, Unit: UnitGrams})
+	}
+	session, err := r.Stop()
+	if err != nil {
+		t.Fatalf("failed to stop session: %s", err)
+	}
+	if session.Dose != 5 || session.Yield != 2 || session.Duration != 3*time.Second || len(session.DataPoints) != 31 {
+		t.Fatalf("unexpected session: %+v", session)
+	}
+}
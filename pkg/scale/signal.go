@@ -0,0 +1,165 @@
+package scale
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/fako1024/gatt"
+)
+
+// ErrClosed denotes that the scale has been closed
+var ErrClosed = errors.New("scale has been closed")
+
+// ReadySignal allows to wait for a scale to become usable (i.e. connected and ready to
+// receive commands). The zero value is ready to use
+type ReadySignal struct {
+	isReady   bool
+	isClosed  bool
+	readyChan chan struct{}
+	closeChan chan struct{}
+
+	mu sync.Mutex
+}
+
+// Set marks the scale as ready / not ready
+func (r *ReadySignal) Set(ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.init()
+	if ready && !r.isReady {
+		close(r.readyChan)
+	} else if !ready && r.isReady {
+		r.readyChan = make(chan struct{})
+	}
+	r.isReady = ready
+}
+
+// IsReady returns if the scale is currently ready
+func (r *ReadySignal) IsReady() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.isReady
+}
+
+// Close permanently marks the scale as closed, releasing all waiting callers
+func (r *ReadySignal) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.init()
+	if !r.isClosed {
+		close(r.closeChan)
+		r.isClosed = true
+	}
+}
+
+// Wait blocks until the scale is ready, has been closed or the context ends
+func (r *ReadySignal) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	r.init()
+	readyChan, closeChan := r.readyChan, r.closeChan
+	r.mu.Unlock()
+
+	select {
+	case <-closeChan:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case <-readyChan:
+		return nil
+	case <-closeChan:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReleaseSignal signals the release (i.e. the disconnect) of connections to peripherals,
+// providing a channel per connection that is closed exactly once. Since the bluetooth
+// library may report a disconnect before the connection is handled (or after handling it
+// has finished), a connection is tracked until it has been both released and handled. The
+// zero value is ready to use
+type ReleaseSignal struct {
+	connections map[gatt.Peripheral]*connection
+
+	mu sync.Mutex
+}
+
+// Released returns the channel that is closed once the connection to a peripheral has
+// been released (to be called once the connection is handled)
+func (r *ReleaseSignal) Released(p gatt.Peripheral) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.get(p).released
+}
+
+// Release marks the connection to a peripheral as released
+func (r *ReleaseSignal) Release(p gatt.Peripheral) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(p)
+	if !c.isReleased {
+		close(c.released)
+		c.isReleased = true
+	}
+	r.cleanup(p, c)
+}
+
+// Done marks the connection to a peripheral as handled
+func (r *ReleaseSignal) Done(p gatt.Peripheral) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(p)
+	c.isDone = true
+	r.cleanup(p, c)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (r *ReadySignal) init() {
+	if r.readyChan == nil {
+		r.readyChan = make(chan struct{})
+	}
+	if r.closeChan == nil {
+		r.closeChan = make(chan struct{})
+	}
+}
+
+// connection denotes the state of a connection tracked by a ReleaseSignal
+type connection struct {
+	released   chan struct{}
+	isReleased bool
+	isDone     bool
+}
+
+// get returns the connection to a peripheral, creating it if required (requires the lock
+// to be held)
+func (r *ReleaseSignal) get(p gatt.Peripheral) *connection {
+	if r.connections == nil {
+		r.connections = make(map[gatt.Peripheral]*connection)
+	}
+	c, exists := r.connections[p]
+	if !exists {
+		c = &connection{released: make(chan struct{})}
+		r.connections[p] = c
+	}
+
+	return c
+}
+
+// cleanup stops tracking a connection once it has been both released and handled, such
+// that a subsequent connection to the same peripheral is tracked anew (requires the lock
+// to be held)
+func (r *ReleaseSignal) cleanup(p gatt.Peripheral, c *connection) {
+	if c.isReleased && c.isDone {
+		delete(r.connections, p)
+	}
+}
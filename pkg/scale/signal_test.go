@@ -0,0 +1,53 @@
+package scale
+
+import (
+	"testing"
+)
+
+func TestReleaseSignal(t *testing.T) {
+	var (
+		r          ReleaseSignal
+		p1, p2, p3 = &fakePeripheral{id: "p1"}, &fakePeripheral{id: "p2"}, &fakePeripheral{id: "p3"}
+	)
+	isReleased := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// A release while handling the connection closes its channel (exactly once)
+	released := r.Released(p1)
+	if isReleased(released) {
+		t.Fatalf("connection unexpectedly released")
+	}
+	r.Release(p1)
+	r.Release(p1)
+	if !isReleased(released) {
+		t.Fatalf("connection not released")
+	}
+	r.Done(p1)
+
+	// A release reported before the connection is handled must not be lost
+	r.Release(p2)
+	if !isReleased(r.Released(p2)) {
+		t.Fatalf("connection released before being handled not released")
+	}
+	r.Done(p2)
+
+	// A release reported after the connection has been handled must be accepted
+	r.Released(p3)
+	r.Done(p3)
+	r.Release(p3)
+
+	// Connections that have been both released and handled are no longer tracked, hence
+	// subsequent connections to the same peripheral are tracked anew
+	if len(r.connections) != 0 {
+		t.Fatalf("unexpected number of tracked connections: %d", len(r.connections))
+	}
+	if isReleased(r.Released(p1)) {
+		t.Fatalf("new connection unexpectedly released")
+	}
+}
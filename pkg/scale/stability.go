@@ -0,0 +1,150 @@
+package scale
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStabilityWindow    = time.Second
+	defaultStabilityTolerance = 0.2
+
+	// Margin compensating floating point errors when comparing against the tolerance
+	stabilityEpsilon = 1e-9
+)
+
+// StabilityEvent denotes a change of the stability of the weight readings
+type StabilityEvent struct {
+
+	// Stable denotes if the weight became stable (or unstable)
+	Stable bool
+
+	// DataPoint denotes the data point that caused the change
+	DataPoint DataPoint
+}
+
+// StabilityDetector determines if the weight readings have settled, i.e. if all data points
+// within a time window deviate by no more than a tolerance. The zero value is ready to
+// use (with default settings)
+type StabilityDetector struct {
+
+	// Window denotes the time span the weight has to remain within the tolerance to be
+	// regarded as stable (0: 1s)
+	Window time.Duration
+
+	// Tolerance denotes the maximum deviation (in grams, converted to the unit of the
+	// data points) within the window (0: 0.2g)
+	Tolerance float64
+
+	handler  func(event StabilityEvent)
+	history  DataPoints
+	since    time.Time
+	isStable bool
+
+	mu sync.Mutex
+}
+
+// SetHandler defines a handler function that is called once the weight becomes stable /
+// unstable
+func (d *StabilityDetector) SetHandler(fn func(event StabilityEvent)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handler = fn
+}
+
+// IsStable returns if the weight is currently stable
+func (d *StabilityDetector) IsStable() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.isStable
+}
+
+// Process annotates a data point with its stability (calling the handler function, if
+// any, if the stability changed) and returns it
+func (d *StabilityDetector) Process(data DataPoint) DataPoint {
+	d.mu.Lock()
+	window, tolerance := d.settings(data.Unit)
+
+	// Restart the observation upon a change of unit or a gap in the data (e.g. after
+	// reconnecting to the scale)
+	if len(d.history) > 0 {
+		last := d.history[len(d.history)-1]
+		if last.Unit != data.Unit || data.TimeStamp.Sub(last.TimeStamp) > window {
+			d.history = d.history[:0]
+		}
+	}
+	if len(d.history) == 0 {
+		d.since = data.TimeStamp
+	}
+
+	// Retain only the data points within the window
+	d.history = append(d.history, data)
+	for len(d.history) > 1 && data.TimeStamp.Sub(d.history[0].TimeStamp) > window {
+		d.history = d.history[1:]
+	}
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, dp := range d.history {
+		lowest, highest = math.Min(lowest, dp.Weight), math.Max(highest, dp.Weight)
+	}
+	data.Stable = data.TimeStamp.Sub(d.since) >= window && highest-lowest <= tolerance+stabilityEpsilon
+	notify := d.update(data)
+	d.mu.Unlock()
+
+	notify()
+
+	return data
+}
+
+// Report processes a data point whose stability has been determined elsewhere (e.g. by
+// scales reporting it themselves), calling the handler function, if any, if the stability
+// changed, and returns it
+func (d *StabilityDetector) Report(data DataPoint) DataPoint {
+	d.mu.Lock()
+	notify := d.update(data)
+	d.mu.Unlock()
+
+	notify()
+
+	return data
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// settings returns the window and the tolerance converted to a unit (requires the lock
+// to be held)
+func (d *StabilityDetector) settings(unit Unit) (time.Duration, float64) {
+	window, tolerance := d.Window, d.Tolerance
+	if window <= 0 {
+		window = defaultStabilityWindow
+	}
+	if tolerance <= 0 {
+		tolerance = defaultStabilityTolerance
+	}
+	if unit == UnitOz {
+		tolerance /= gramsPerOunce
+	}
+
+	return window, tolerance
+}
+
+// update sets the current stability from a data point and returns a function calling the
+// handler function, if any, if the stability changed (requires the lock to be held, while
+// the returned function must be called without holding it)
+func (d *StabilityDetector) update(data DataPoint) func() {
+	hasChanged := data.Stable != d.isStable
+	d.isStable = data.Stable
+	handler := d.handler
+
+	return func() {
+		if hasChanged && handler != nil {
+			handler(StabilityEvent{
+				Stable:    data.Stable,
+				DataPoint: data,
+			})
+		}
+	}
+}
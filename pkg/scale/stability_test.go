@@ -0,0 +1,65 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStabilityDetector(t *testing.T) {
+	d := StabilityDetector{
+		Window:    time.Second,
+		Tolerance: 0.2,
+	}
+
+	var events []StabilityEvent
+	d.SetHandler(func(event StabilityEvent) {
+		events = append(events, event)
+	})
+
+	start := time.Now()
+	for i, c := range []struct {
+		offset time.Duration
+		weight float64
+		unit   Unit
+		stable bool
+	}{
+		{0, 10.0, UnitGrams, false},
+		{500 * time.Millisecond, 10.1, UnitGrams, false},
+		{1000 * time.Millisecond, 10.2, UnitGrams, true}, // window covered, within tolerance
+		{1500 * time.Millisecond, 10.5, UnitGrams, false},
+		{2000 * time.Millisecond, 10.5, UnitGrams, false},
+		{2600 * time.Millisecond, 10.5, UnitGrams, true}, // 10.2 / 10.5 left the window
+		{2700 * time.Millisecond, 0.370, UnitOz, false},  // change of unit restarts observation
+		{3200 * time.Millisecond, 0.372, UnitOz, false},
+		{3700 * time.Millisecond, 0.374, UnitOz, true}, // tolerance ~0.007oz
+		{3800 * time.Millisecond, 0.380, UnitOz, false},
+		{6000 * time.Millisecond, 0.380, UnitOz, false}, // gap in data restarts observation
+		{7000 * time.Millisecond, 0.380, UnitOz, true},
+	} {
+		data := d.Process(DataPoint{
+			TimeStamp: start.Add(c.offset),
+			Weight:    c.weight,
+			Unit:      c.unit,
+		})
+		if data.Stable != c.stable || d.IsStable() != c.stable {
+			t.Fatalf("unexpected stability for data point %d, want %v, have %v", i, c.stable, data.Stable)
+		}
+	}
+
+	expected := []bool{true, false, true, false, true, false, true}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected number of stability events, want %d, have %d", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.Stable != expected[i] || event.DataPoint.Stable != expected[i] {
+			t.Fatalf("unexpected stability event %d: %+v", i, event)
+		}
+	}
+
+	// Stability reported by the scale itself must only trigger events upon change
+	d.Report(DataPoint{Stable: true})
+	d.Report(DataPoint{Stable: false})
+	if len(events) != len(expected)+1 || events[len(events)-1].Stable {
+		t.Fatalf("unexpected stability events after reporting: %+v", events[len(expected):])
+	}
+}
@@ -0,0 +1,66 @@
+package scale
+
+import (
+	"sync"
+	"time"
+)
+
+// Stopwatch denotes a host-side stopwatch mirroring the timer display of a scale, i.e.
+// starting a running or stopping a stopped stopwatch has no effect, a stopped stopwatch
+// resumes upon start and resetting it stops it at zero. The zero value is a reset
+// stopwatch
+type Stopwatch struct {
+	elapsed time.Duration
+	started time.Time
+
+	mu sync.Mutex
+}
+
+// Start starts / resumes the stopwatch (unless it is already running)
+func (s *Stopwatch) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started.IsZero() {
+		s.started = time.Now()
+	}
+}
+
+// Stop stops the stopwatch (unless it is not running)
+func (s *Stopwatch) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started.IsZero() {
+		s.elapsed += time.Since(s.started)
+		s.started = time.Time{}
+	}
+}
+
+// Reset stops the stopwatch and resets it to zero
+func (s *Stopwatch) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.elapsed, s.started = 0, time.Time{}
+}
+
+// IsRunning returns if the stopwatch is running
+func (s *Stopwatch) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.started.IsZero()
+}
+
+// ElapsedTime returns the current stopwatch value
+func (s *Stopwatch) ElapsedTime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started.IsZero() {
+		return s.elapsed
+	}
+
+	return s.elapsed + time.Since(s.started)
+}
@@ -0,0 +1,47 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopwatch(t *testing.T) {
+	var s Stopwatch
+	if s.IsRunning() || s.ElapsedTime() != 0 {
+		t.Fatalf("unexpected initial stopwatch state")
+	}
+
+	// Stopping a reset stopwatch must not have any effect
+	s.Stop()
+	if s.IsRunning() || s.ElapsedTime() != 0 {
+		t.Fatalf("unexpected stopwatch state after stopping reset stopwatch")
+	}
+
+	s.Start()
+	time.Sleep(20 * time.Millisecond)
+	s.Start()
+	s.Stop()
+	elapsed := s.ElapsedTime()
+	if s.IsRunning() || elapsed < 20*time.Millisecond {
+		t.Fatalf("unexpected elapsed time after stopping: %v", elapsed)
+	}
+
+	// A stopped stopwatch must neither advance nor be affected by stopping it again
+	time.Sleep(20 * time.Millisecond)
+	s.Stop()
+	if current := s.ElapsedTime(); current != elapsed {
+		t.Fatalf("stopped stopwatch advanced from %v to %v", elapsed, current)
+	}
+
+	// Resuming the stopwatch must continue from the elapsed time
+	s.Start()
+	time.Sleep(20 * time.Millisecond)
+	if current := s.ElapsedTime(); !s.IsRunning() || current < elapsed+20*time.Millisecond || current > elapsed+time.Second {
+		t.Fatalf("unexpected elapsed time after resuming: %v", current)
+	}
+
+	s.Reset()
+	if s.IsRunning() || s.ElapsedTime() != 0 {
+		t.Fatalf("unexpected stopwatch state after reset")
+	}
+}
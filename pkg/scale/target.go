@@ -0,0 +1,263 @@
+package scale
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTargetLag        = 1500 * time.Millisecond
+	defaultTargetAdaptation = 0.5
+	defaultTargetBuzzes     = 1
+
+	// Maximum lag that can be learned from a session (longer lags are regarded as bogus)
+	maxTargetLag = 10 * time.Second
+
+	// Weight (in grams) below which a triggered watcher is armed again (e.g. after taring
+	// the scale or removing the cup)
+	targetRearmWeight = 1.
+)
+
+// TargetEvent denotes the (predicted) attainment of the target weight
+type TargetEvent struct {
+
+	// Target denotes the target weight (in the unit of the data point)
+	Target float64 `json:"target"`
+
+	// Predicted denotes the final weight predicted from the current weight, flow rate and lag
+	Predicted float64 `json:"predicted"`
+
+	// Lag denotes the lag used for the prediction
+	Lag time.Duration `json:"lag"`
+
+	// FlowRate denotes the flow rate used for the prediction
+	FlowRate FlowRate `json:"flow_rate"`
+
+	// DataPoint denotes the data point that triggered the event
+	DataPoint DataPoint `json:"data_point"`
+}
+
+// TargetWatcher signals that a target weight (e.g. the yield of an espresso shot) is about
+// to be reached. The final weight is predicted from the current weight, the flow rate and
+// a lag (comprising the time it takes to stop the machine and any dripping afterwards),
+// allowing to stop the flow in time. The lag is learned from the final weight observed
+// after each signal. The zero value is ready to use (with default settings, the target
+// has to be set via SetTarget())
+type TargetWatcher struct {
+
+	// InitialLag denotes the lag used until it has been learned from a session (0: 1.5s)
+	InitialLag time.Duration
+
+	// Adaptation denotes the weight (between 0 and 1) of the most recent session when
+	// learning the lag (0: 0.5)
+	Adaptation float64
+
+	// Buzzer denotes the buzzer to signal the attainment of the target with (nil: none)
+	Buzzer Buzzer
+
+	// Buzzes denotes the number of times to buzz (0: 1)
+	Buzzes int
+
+	// Logger denotes the logger to emit buzzer errors to (nil: none)
+	Logger Logger
+
+	handler   func(event TargetEvent)
+	flow      FlowProcessor
+	stability StabilityDetector
+
+	target    float64
+	lag       time.Duration
+	isArmed   bool
+	triggered *TargetEvent
+	last      *DataPoint
+
+	mu sync.Mutex
+}
+
+// SetHandler defines a handler function that is called once the target weight is about to
+// be reached
+func (w *TargetWatcher) SetHandler(fn func(event TargetEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handler = fn
+}
+
+// SetTarget sets the target weight (in grams, converted to the unit of the data points)
+// and arms the watcher (0: disable)
+func (w *TargetWatcher) SetTarget(target float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.target = math.Max(target, 0)
+	w.isArmed = w.target > 0
+	w.triggered = nil
+}
+
+// Target returns the target weight (in grams, 0 if disabled)
+func (w *TargetWatcher) Target() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.target
+}
+
+// IsArmed returns if the watcher is going to signal the attainment of the target weight
+// (being re-armed after each signal once the weight returned to zero)
+func (w *TargetWatcher) IsArmed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.isArmed
+}
+
+// Lag returns the current (learned) lag
+func (w *TargetWatcher) Lag() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.currentLag()
+}
+
+// SetLag overrides the current (learned) lag (0: revert to the initial lag)
+func (w *TargetWatcher) SetLag(lag time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lag = lag
+}
+
+// Run processes all data points of a subscription until its channel is closed
+func (w *TargetWatcher) Run(sub *Subscription) {
+	for data := range sub.Data() {
+		w.Process(data)
+	}
+}
+
+// Process processes a data point, signaling the attainment of the target weight (if
+// applicable)
+func (w *TargetWatcher) Process(data DataPoint) {
+	w.mu.Lock()
+
+	rate := w.flow.Process(data)
+	data.Stable = w.stability.Process(data).Stable
+	last := w.last
+	w.last = &data
+
+	if w.target <= 0 {
+		w.mu.Unlock()
+		return
+	}
+	target, rearmWeight := w.target, targetRearmWeight
+	if data.Unit == UnitOz {
+		target, rearmWeight = target/gramsPerOunce, rearmWeight/gramsPerOunce
+	}
+
+	// Re-arm the watcher once the weight returned to zero
+	if !w.isArmed {
+		w.learn(data, last, rate)
+		if data.Weight < rearmWeight {
+			w.isArmed, w.triggered = true, nil
+		}
+		w.mu.Unlock()
+		return
+	}
+
+	// Predict the final weight (as long as the weight is increasing)
+	lag := w.currentLag()
+	predicted := data.Weight + rate.Rate*lag.Seconds()
+	if rate.Rate <= 0 || predicted < target {
+		w.mu.Unlock()
+		return
+	}
+
+	event := TargetEvent{
+		Target:    target,
+		Predicted: predicted,
+		Lag:       lag,
+		FlowRate:  rate,
+		DataPoint: data,
+	}
+	w.isArmed, w.triggered = false, &event
+	handler := w.handler
+	w.mu.Unlock()
+
+	w.signal(event, handler)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// learn updates the lag from the final weight once the flow stopped and the weight
+// stabilized after a signal (requires the lock to be held)
+func (w *TargetWatcher) learn(data DataPoint, last *DataPoint, rate FlowRate) {
+	if w.triggered == nil {
+		return
+	}
+
+	// Abrupt changes (e.g. removing the cup) or a change of unit render the final weight
+	// meaningless
+	if last == nil || isAbrupt(*last, data, &w.flow) || data.Unit != w.triggered.DataPoint.Unit {
+		w.triggered = nil
+		return
+	}
+
+	stopFlowRate := defaultSessionStopFlowRate
+	if data.Unit == UnitOz {
+		stopFlowRate /= gramsPerOunce
+	}
+	if !data.Stable || math.Abs(rate.Rate) >= stopFlowRate {
+		return
+	}
+
+	// Derive the lag that would have predicted the final weight exactly
+	trigger := w.triggered
+	w.triggered = nil
+	actual := time.Duration((data.Weight - trigger.DataPoint.Weight) / trigger.FlowRate.Rate * float64(time.Second))
+	if actual < 0 || actual > maxTargetLag {
+		return
+	}
+
+	adaptation := w.Adaptation
+	if adaptation <= 0 || adaptation > 1 {
+		adaptation = defaultTargetAdaptation
+	}
+	lag := w.currentLag()
+	w.lag = lag + time.Duration(adaptation*float64(actual-lag))
+}
+
+// signal calls the handler function and buzzes the buzzer (if any, without waiting for it
+// to complete)
+func (w *TargetWatcher) signal(event TargetEvent, handler func(event TargetEvent)) {
+	if handler != nil {
+		handler(event)
+	}
+
+	if w.Buzzer == nil {
+		return
+	}
+	buzzes := w.Buzzes
+	if buzzes <= 0 {
+		buzzes = defaultTargetBuzzes
+	}
+
+	// Buzz asynchronously, since sending the command to the scale may block (stalling the
+	// processing of further data points in the meantime)
+	go func() {
+		if err := w.Buzzer.Buzz(buzzes); err != nil && w.Logger != nil {
+			w.Logger.Warnf("failed to signal target weight: %s", err)
+		}
+	}()
+}
+
+// currentLag returns the current lag (requires the lock to be held)
+func (w *TargetWatcher) currentLag() time.Duration {
+	if w.lag > 0 {
+		return w.lag
+	}
+	if w.InitialLag > 0 {
+		return w.InitialLag
+	}
+
+	return defaultTargetLag
+}
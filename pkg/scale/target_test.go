@@ -0,0 +1,80 @@
+package scale
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type testBuzzer struct {
+	buzzes chan int
+}
+
+func (t *testBuzzer) IsBuzzingOnTouch() bool      { return false }
+func (t *testBuzzer) ToggleBuzzingOnTouch() error { return nil }
+func (t *testBuzzer) Buzz(n int) error            { t.buzzes <- n; return nil }
+
+func TestTargetWatcher(t *testing.T) {
+	var (
+		buzzer = testBuzzer{buzzes: make(chan int, 16)}
+		events []TargetEvent
+		start  = time.Now()
+	)
+	w := TargetWatcher{Buzzer: &buzzer, Buzzes: 2}
+	w.SetHandler(func(event TargetEvent) {
+		events = append(events, event)
+	})
+
+	// A disabled watcher must not signal anything
+	for _, data := range shotDataPoints(start, 0., 2., 2*time.Second, 17500*time.Millisecond) {
+		w.Process(data)
+	}
+	if len(events) != 0 || w.IsArmed() {
+		t.Fatalf("unexpected events from disabled watcher: %+v", events)
+	}
+
+	// With the default lag of 1.5s, the target must be signaled 3g (at 2g/s) in advance
+	w.SetTarget(36.)
+	start = start.Add(time.Minute)
+	w.Process(DataPoint{TimeStamp: start.Add(-100 * time.Millisecond), Weight: 0, Unit: UnitGrams})
+	for _, data := range shotDataPoints(start, 0., 2., 2*time.Second, 17500*time.Millisecond) {
+		w.Process(data)
+	}
+	if len(events) != 1 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// The buzzer is signaled asynchronously
+	select {
+	case n := <-buzzer.buzzes:
+		if n != 2 || len(buzzer.buzzes) != 0 {
+			t.Fatalf("unexpected buzzes: %d (%d pending)", n, len(buzzer.buzzes))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("buzzer was not signaled")
+	}
+	if weight := events[0].DataPoint.Weight; weight < 32.9 || weight > 33.3 || events[0].Predicted < 36. {
+		t.Fatalf("unexpected event: %+v", events[0])
+	}
+
+	// The flow continued for 1s after the signal, hence the lag must have been adapted
+	// halfway from 1.5s towards 1s
+	if lag := w.Lag(); math.Abs(lag.Seconds()-(1.5+0.5*((35.-events[0].DataPoint.Weight)/2.-1.5))) > 1e-3 {
+		t.Fatalf("unexpected learned lag: %v", lag)
+	}
+	if w.IsArmed() {
+		t.Fatalf("watcher unexpectedly armed before the weight returned to zero")
+	}
+
+	// Taring the scale must re-arm the watcher
+	w.Process(DataPoint{TimeStamp: start.Add(time.Minute), Weight: 0, Unit: UnitGrams})
+	if !w.IsArmed() {
+		t.Fatalf("watcher not re-armed after taring the scale")
+	}
+
+	// Disabling the watcher must disarm it
+	w.SetTarget(0)
+	if w.IsArmed() || w.Target() != 0 {
+		t.Fatalf("watcher unexpectedly armed after disabling it")
+	}
+}
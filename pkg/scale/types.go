@@ -1,6 +1,42 @@
 package scale
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+
+	// ErrNotSupported denotes that an operation is not supported by the scale device
+	ErrNotSupported = errors.New("operation not supported by device")
+
+	// ErrPrecisionUnknown denotes that the precision cannot be set since the current one
+	// is unknown (and the scale only supports toggling it)
+	ErrPrecisionUnknown = errors.New("current precision unknown")
+)
+
+// TimeoutError denotes that a command was not confirmed by the scale in time, i.e. the
+// requested change was not reflected by the data received from the device
+type TimeoutError struct {
+	Command string
+	Err     error
+}
+
+// Error returns a string representation of the error
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("command `%s` was not confirmed by the scale: %s", e.Command, e.Err)
+}
+
+// Unwrap returns the underlying (context) error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout denotes that the error is caused by a timeout
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
 
 // Unit denotes the unit of the weight measurement
 type Unit string
@@ -15,6 +51,29 @@ const (
 
 	// UnitOz denotes imperial units
 	UnitOz = "oz"
+
+	// Number of grams per (avoirdupois) ounce
+	gramsPerOunce = 28.349523125
+)
+
+// IsValid returns if the unit denotes a known unit of weight (i.e. grams or ounces)
+func (u Unit) IsValid() bool {
+	return u == UnitGrams || u == UnitOz
+}
+
+// Precision denotes the resolution of the weight measurement
+type Precision float64
+
+const (
+
+	// PrecisionUnknown denotes an unknown / invalid precision
+	PrecisionUnknown Precision = 0
+
+	// PrecisionLow denotes a resolution of 0.1
+	PrecisionLow Precision = 0.1
+
+	// PrecisionHigh denotes a resolution of 0.01
+	PrecisionHigh Precision = 0.01
 )
 
 // State denotes a connection state
@@ -30,11 +89,21 @@ const (
 
 	// StateDisconnected is active after being disconnected from the scale
 	StateDisconnected
+
+	// StateReconnecting is active while waiting for / attempting to reconnect to the
+	// scale after the connection was lost
+	StateReconnecting
 )
 
 // ConnectionStatus denotes the current status of the bluetooth device
 type ConnectionStatus struct {
+
+	// Error denotes the (last) error that occurred, if any
 	Error error
+
+	// Attempt denotes the current reconnection attempt (0 if not reconnecting)
+	Attempt int
+
 	State
 }
 
@@ -43,6 +112,12 @@ type DataPoint struct {
 	TimeStamp time.Time
 	Unit      Unit
 	Weight    float64
+
+	// Stable denotes if the weight has settled (as determined by a StabilityDetector)
+	Stable bool
+
+	// RawWeight denotes the weight prior to filtering (as set by a filter Pipeline)
+	RawWeight float64
 }
 
 // Value provides a method to retrieve the current value (for interface use)
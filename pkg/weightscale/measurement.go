@@ -0,0 +1,143 @@
+package weightscale
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+const (
+	flagImperial  = 0x01
+	flagTimeStamp = 0x02
+	flagUserID    = 0x04
+	flagBMIHeight = 0x08
+
+	resolutionWeightSI       = 0.005 // kg
+	resolutionWeightImperial = 0.01  // lb
+	resolutionHeightSI       = 0.001 // m
+	resolutionHeightImperial = 0.1   // in
+	resolutionBMI            = 0.1
+
+	gramsPerKilogram = 1000.
+	ouncesPerPound   = 16.
+
+	weightUnsuccessful = 0xffff
+
+	// UserIDUnknown denotes that the user of a measurement is unknown
+	UserIDUnknown = 0xff
+)
+
+// ErrMeasurementUnsuccessful denotes that the scale reported an unsuccessful measurement
+var ErrMeasurementUnsuccessful = errors.New("measurement unsuccessful")
+
+// Measurement denotes a decoded Weight Measurement (0x2A9D) characteristic value
+type Measurement struct {
+
+	// Weight denotes the measured weight, converted to grams (SI) or ounces (imperial)
+	Weight float64
+	Unit   scale.Unit
+
+	// TimeStamp denotes the time of the measurement as reported by the scale (if
+	// HasTimeStamp is set)
+	TimeStamp    time.Time
+	HasTimeStamp bool
+
+	// UserID denotes the user index (if HasUserID is set, UserIDUnknown if unknown)
+	UserID    uint8
+	HasUserID bool
+
+	// BMI and Height (in m or in, depending on Unit) are provided if HasBMIAndHeight is set
+	BMI             float64
+	Height          float64
+	HasBMIAndHeight bool
+}
+
+// DataPoint converts the measurement into a generic data point
+func (m Measurement) DataPoint() scale.DataPoint {
+	return scale.DataPoint{
+		TimeStamp: m.TimeStamp,
+		Weight:    m.Weight,
+		Unit:      m.Unit,
+	}
+}
+
+// parseMeasurement decodes a Weight Measurement characteristic value as defined by the
+// Bluetooth SIG Weight Scale Service specification
+func parseMeasurement(data []byte) (Measurement, error) {
+	if len(data) < 3 {
+		return Measurement{}, fmt.Errorf("invalid measurement length %d", len(data))
+	}
+
+	flags := data[0]
+	expectedLen := 3
+	if flags&flagTimeStamp != 0 {
+		expectedLen += 7
+	}
+	if flags&flagUserID != 0 {
+		expectedLen++
+	}
+	if flags&flagBMIHeight != 0 {
+		expectedLen += 4
+	}
+	if len(data) < expectedLen {
+		return Measurement{}, fmt.Errorf("invalid measurement length %d for flags %#x (expected %d)", len(data), flags, expectedLen)
+	}
+
+	rawWeight := binary.LittleEndian.Uint16(data[1:3])
+	if rawWeight == weightUnsuccessful {
+		return Measurement{}, ErrMeasurementUnsuccessful
+	}
+
+	var m Measurement
+	if flags&flagImperial != 0 {
+		m.Weight = float64(rawWeight) * resolutionWeightImperial * ouncesPerPound
+		m.Unit = scale.UnitOz
+	} else {
+		m.Weight = float64(rawWeight) * resolutionWeightSI * gramsPerKilogram
+		m.Unit = scale.UnitGrams
+	}
+
+	pos := 3
+	if flags&flagTimeStamp != 0 {
+		m.TimeStamp = parseTimeStamp(data[pos : pos+7])
+		m.HasTimeStamp = !m.TimeStamp.IsZero()
+		pos += 7
+	}
+	if flags&flagUserID != 0 {
+		m.UserID = data[pos]
+		m.HasUserID = true
+		pos++
+	}
+	if flags&flagBMIHeight != 0 {
+		m.BMI = float64(binary.LittleEndian.Uint16(data[pos:pos+2])) * resolutionBMI
+		rawHeight := float64(binary.LittleEndian.Uint16(data[pos+2 : pos+4]))
+		if flags&flagImperial != 0 {
+			m.Height = rawHeight * resolutionHeightImperial
+		} else {
+			m.Height = rawHeight * resolutionHeightSI
+		}
+		m.HasBMIAndHeight = true
+	}
+
+	// Use the time of reception if the scale did not provide a (valid) timestamp
+	if !m.HasTimeStamp {
+		m.TimeStamp = time.Now()
+	}
+
+	return m, nil
+}
+
+// parseTimeStamp decodes a Date Time characteristic value (returning a zero time if
+// the date is unknown)
+func parseTimeStamp(data []byte) time.Time {
+	year := int(binary.LittleEndian.Uint16(data[0:2]))
+	month, day := int(data[2]), int(data[3])
+	if year == 0 || month == 0 || day == 0 {
+		return time.Time{}
+	}
+
+	return time.Date(year, time.Month(month), day, int(data[4]), int(data[5]), int(data[6]), 0, time.Local)
+}
@@ -0,0 +1,98 @@
+package weightscale
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+)
+
+func TestParseMeasurement(t *testing.T) {
+	timeStamp := time.Date(2024, time.March, 15, 8, 30, 5, 0, time.Local)
+
+	for _, cs := range []struct {
+		name     string
+		data     []byte
+		expected Measurement
+		err      bool
+	}{
+		{"SI", []byte{0x00, 0xb0, 0x36},
+			Measurement{Weight: 70000., Unit: scale.UnitGrams}, false},
+		{"imperial", []byte{0x01, 0x48, 0x3c},
+			Measurement{Weight: 2469.12, Unit: scale.UnitOz}, false},
+		{"SI with timestamp", []byte{0x02, 0xb0, 0x36, 0xe8, 0x07, 0x03, 0x0f, 0x08, 0x1e, 0x05},
+			Measurement{Weight: 70000., Unit: scale.UnitGrams, TimeStamp: timeStamp, HasTimeStamp: true}, false},
+		{"imperial with timestamp", []byte{0x03, 0x48, 0x3c, 0xe8, 0x07, 0x03, 0x0f, 0x08, 0x1e, 0x05},
+			Measurement{Weight: 2469.12, Unit: scale.UnitOz, TimeStamp: timeStamp, HasTimeStamp: true}, false},
+		{"unknown timestamp", []byte{0x02, 0xb0, 0x36, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Measurement{Weight: 70000., Unit: scale.UnitGrams}, false},
+		{"SI with user ID, BMI and height", []byte{0x0c, 0xb0, 0x36, 0x01, 0xfa, 0x00, 0xd6, 0x06},
+			Measurement{Weight: 70000., Unit: scale.UnitGrams, UserID: 1, HasUserID: true, BMI: 25., Height: 1.75, HasBMIAndHeight: true}, false},
+		{"imperial with all fields", []byte{0x0f, 0x48, 0x3c, 0xe8, 0x07, 0x03, 0x0f, 0x08, 0x1e, 0x05, 0xff, 0xfa, 0x00, 0xbc, 0x02},
+			Measurement{Weight: 2469.12, Unit: scale.UnitOz, TimeStamp: timeStamp, HasTimeStamp: true, UserID: UserIDUnknown, HasUserID: true, BMI: 25., Height: 70., HasBMIAndHeight: true}, false},
+		{"empty", nil, Measurement{}, true},
+		{"short weight", []byte{0x00, 0xb0}, Measurement{}, true},
+		{"short timestamp", []byte{0x02, 0xb0, 0x36, 0xe8, 0x07, 0x03}, Measurement{}, true},
+		{"missing user ID", []byte{0x06, 0xb0, 0x36, 0xe8, 0x07, 0x03, 0x0f, 0x08, 0x1e, 0x05}, Measurement{}, true},
+		{"short BMI and height", []byte{0x08, 0xb0, 0x36, 0xfa, 0x00, 0xd6}, Measurement{}, true},
+	} {
+		t.Run(cs.name, func(t *testing.T) {
+			before := time.Now()
+			m, err := parseMeasurement(cs.data)
+			if cs.err {
+				if err == nil {
+					t.Fatalf("expected error parsing %x, have %+v", cs.data, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse %x: %s", cs.data, err)
+			}
+
+			// Without a (valid) timestamp, the time of reception is used
+			if !cs.expected.HasTimeStamp {
+				if m.TimeStamp.Before(before) || m.TimeStamp.After(time.Now()) {
+					t.Fatalf("unexpected time of reception: %v", m.TimeStamp)
+				}
+				m.TimeStamp = time.Time{}
+			}
+
+			if math.Abs(m.Weight-cs.expected.Weight) > 1e-9 || math.Abs(m.BMI-cs.expected.BMI) > 1e-9 || math.Abs(m.Height-cs.expected.Height) > 1e-9 {
+				t.Fatalf("unexpected measurement, want %+v, have %+v", cs.expected, m)
+			}
+			m.Weight, m.BMI, m.Height = cs.expected.Weight, cs.expected.BMI, cs.expected.Height
+			if !m.TimeStamp.Equal(cs.expected.TimeStamp) {
+				t.Fatalf("unexpected timestamp, want %v, have %v", cs.expected.TimeStamp, m.TimeStamp)
+			}
+			m.TimeStamp = cs.expected.TimeStamp
+			if m != cs.expected {
+				t.Fatalf("unexpected measurement, want %+v, have %+v", cs.expected, m)
+			}
+		})
+	}
+}
+
+func TestParseMeasurementUnsuccessful(t *testing.T) {
+	if _, err := parseMeasurement([]byte{0x01, 0xff, 0xff}); !errors.Is(err, ErrMeasurementUnsuccessful) {
+		t.Fatalf("unexpected error for unsuccessful measurement: %v", err)
+	}
+}
+
+func TestParseTimeStamp(t *testing.T) {
+	for _, cs := range []struct {
+		data     []byte
+		expected time.Time
+	}{
+		{[]byte{0xe8, 0x07, 0x03, 0x0f, 0x08, 0x1e, 0x05}, time.Date(2024, time.March, 15, 8, 30, 5, 0, time.Local)},
+		{[]byte{0xe8, 0x07, 0x0c, 0x1f, 0x17, 0x3b, 0x3b}, time.Date(2024, time.December, 31, 23, 59, 59, 0, time.Local)},
+		{[]byte{0x00, 0x00, 0x03, 0x0f, 0x08, 0x1e, 0x05}, time.Time{}},
+		{[]byte{0xe8, 0x07, 0x00, 0x0f, 0x08, 0x1e, 0x05}, time.Time{}},
+		{[]byte{0xe8, 0x07, 0x03, 0x00, 0x08, 0x1e, 0x05}, time.Time{}},
+	} {
+		if ts := parseTimeStamp(cs.data); !ts.Equal(cs.expected) {
+			t.Fatalf("unexpected timestamp for %x, want %v, have %v", cs.data, cs.expected, ts)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package weightscale
+
+import (
+	"context"
+	"time"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+// WithDeviceID sets the Bluetooth device ID (if set, only the peripheral with this ID is
+// connected to, regardless of its name)
+func WithDeviceID(deviceID string) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.deviceID = deviceID
+	}
+}
+
+// WithDeviceName sets the Bluetooth device name (by default, any device advertising
+// the Weight Scale Service is accepted)
+func WithDeviceName(deviceName string) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.deviceName = deviceName
+	}
+}
+
+// WithDevice sets the Bluetooth device
+func WithDevice(btDevice gatt.Device) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.btDevice = btDevice
+	}
+}
+
+// WithLogger sets a logger
+func WithLogger(logger scale.Logger) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.logger = logger
+	}
+}
+
+// WithContext sets a context, terminating the connection to the device (including any
+// ongoing scan) once it ends
+func WithContext(ctx context.Context) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.ctx = ctx
+	}
+}
+
+// WithReconnectPolicy sets the strategy to reconnect to the scale after the connection
+// was lost (see scale.ReconnectPolicy for the default behavior)
+func WithReconnectPolicy(policy scale.ReconnectPolicy) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.reconnector.Policy = policy
+	}
+}
+
+// WithDeliveryPolicy sets the behavior when putting data points / state changes on a
+// channel whose consumer does not keep up. It applies to the channels set via
+// SetDataChannel() / SetStateChangeChannel() and serves as default for subscriptions (by
+// default, delivery never blocks, see scale.DeliveryDefault)
+func WithDeliveryPolicy(data, state scale.DeliveryPolicy) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.broker.DataPolicy = data
+		w.broker.StatePolicy = state
+	}
+}
+
+// WithStabilityDetection sets the time window and the tolerance (in grams) used to determine
+// if the weight is stable (by default, a deviation of up to 0.2g within 1s)
+func WithStabilityDetection(window time.Duration, tolerance float64) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.stability.Window = window
+		w.stability.Tolerance = tolerance
+	}
+}
+
+// WithCanonicalUnit ensures that all data points are delivered in a certain unit, regardless
+// of the unit selected on the scale (data points in an unknown unit are dropped)
+func WithCanonicalUnit(unit scale.Unit) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.canonicalUnit = unit
+	}
+}
+
+// WithFilter installs a filter pipeline for the weight readings, i.e. all data points
+// are passed through the given filters in order before being delivered (the unfiltered
+// weight being retained in their RawWeight field)
+func WithFilter(filters ...scale.Filter) func(*WeightScale) {
+	return func(w *WeightScale) {
+		w.filter = append(w.filter, filters...)
+	}
+}
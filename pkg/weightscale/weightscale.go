@@ -0,0 +1,552 @@
+package weightscale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fako1024/btscale/pkg/scale"
+	"github.com/fako1024/gatt"
+)
+
+const (
+	weightScaleService              = "181d"
+	weightMeasurementCharacteristic = "2a9d"
+
+	batteryService             = "180f"
+	batteryLevelCharacteristic = "2a19"
+)
+
+// WeightScale denotes a generic bluetooth scale providing the standard Weight Scale
+// Service (0x181D)
+type WeightScale struct {
+	connectionStatus scale.ConnectionStatus
+	batteryLevel     byte
+	unit             scale.Unit
+	canonicalUnit    scale.Unit
+	filter           scale.Pipeline
+	lastMeasurement  Measurement
+	deviceInfo       scale.DeviceInfo
+
+	deviceID     string
+	deviceName   string
+	discoveredID string
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	ready       scale.ReadySignal
+	release     scale.ReleaseSignal
+	reconnector scale.Reconnector
+	broker      scale.Broker
+	stability   scale.StabilityDetector
+	connWG      sync.WaitGroup
+
+	btDevice gatt.Device
+
+	logger scale.Logger
+
+	mu sync.RWMutex
+}
+
+// New instantiates a new WeightScale struct, executing functional options, if any
+func New(options ...func(*WeightScale)) (*WeightScale, error) {
+
+	// Initialize a new instance of a generic weight scale
+	w := &WeightScale{
+		unit:   scale.UnitUnknown,
+		ctx:    context.Background(),
+		logger: &scale.NullLogger{},
+	}
+
+	// Execute functional options (if any), see options.go for implementation
+	for _, option := range options {
+		option(w)
+	}
+	if w.canonicalUnit != "" && !w.canonicalUnit.IsValid() {
+		return nil, fmt.Errorf("invalid canonical unit: %s", w.canonicalUnit)
+	}
+
+	// Initialize a new GATT device (if not provided as option)
+	if w.btDevice == nil {
+		btDevice, err := gatt.NewDevice(defaultBTClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		w.btDevice = btDevice
+	}
+
+	// Terminate the connection to the device once the context ends
+	w.ctx, w.cancel = context.WithCancel(w.ctx)
+	go func() {
+		<-w.ctx.Done()
+		if err := w.Close(); err != nil {
+			w.logger.Warnf("failed to close device upon termination of context: %s", err)
+		}
+	}()
+
+	// Release the device (terminating the context) if it cannot be initialized
+	if err := w.subscribe(); err != nil {
+		if closeErr := w.Close(); closeErr != nil {
+			w.logger.Warnf("failed to close device after failed initialization: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// ConnectionStatus returns the current status of the bluetooth device
+func (w *WeightScale) ConnectionStatus() scale.ConnectionStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.connectionStatus
+}
+
+// WaitConnected blocks until the scale is connected and usable, returning an error if the
+// context ends or the scale is closed before
+func (w *WeightScale) WaitConnected(ctx context.Context) error {
+	return w.ready.Wait(ctx)
+}
+
+// DeviceInfo returns the information provided by the Device Information service of the
+// scale (populated upon connection)
+func (w *WeightScale) DeviceInfo() scale.DeviceInfo {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.deviceInfo
+}
+
+// DeliveryStats returns the number of data points / state changes dropped so far
+// (according to the delivery policy)
+func (w *WeightScale) DeliveryStats() scale.DeliveryStats {
+	return w.broker.Stats()
+}
+
+// BatteryLevel returns the current battery level (if provided by the device)
+func (w *WeightScale) BatteryLevel() float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.batteryLevel > 100 {
+		return 1.
+	}
+	return float64(w.batteryLevel) / 100.
+}
+
+// BatteryLevelRaw returns the current battery level in its raw form
+func (w *WeightScale) BatteryLevelRaw() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return int(w.batteryLevel)
+}
+
+// Unit returns the current weight unit
+func (w *WeightScale) Unit() scale.Unit {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.unit
+}
+
+// LastMeasurement returns the last measurement (including optional fields such as
+// user ID or BMI) received from the scale
+func (w *WeightScale) LastMeasurement() Measurement {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.lastMeasurement
+}
+
+// Subscribe registers a new subscription to the data points and state changes of the
+// scale, executing functional options, if any
+func (w *WeightScale) Subscribe(options ...func(*scale.Subscription)) *scale.Subscription {
+	return w.broker.Subscribe(options...)
+}
+
+// IsStable returns if the weight is currently stable
+func (w *WeightScale) IsStable() bool {
+	return w.stability.IsStable()
+}
+
+// SetStabilityChangeHandler defines a handler function that is called once the weight
+// becomes stable / unstable
+func (w *WeightScale) SetStabilityChangeHandler(fn func(event scale.StabilityEvent)) {
+	w.stability.SetHandler(fn)
+}
+
+// SetStateChangeHandler defines a handler function that is called upon state change
+func (w *WeightScale) SetStateChangeHandler(fn func(status scale.ConnectionStatus)) {
+	w.broker.SetStateChangeHandler(fn)
+}
+
+// SetStateChangeChannel defines a channel that state changes are put on (dropping the
+// new state change if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (w *WeightScale) SetStateChangeChannel(ch chan scale.ConnectionStatus) {
+	w.broker.SetStateChangeChannel(ch)
+}
+
+// SetDataHandler defines a handler function that is called upon retrieval of data
+func (w *WeightScale) SetDataHandler(fn func(data scale.DataPoint)) {
+	w.broker.SetDataHandler(fn)
+}
+
+// SetDataChannel defines a channel that data points are put on (dropping the oldest
+// buffered data point if the consumer does not keep up, unless set otherwise via
+// WithDeliveryPolicy())
+func (w *WeightScale) SetDataChannel(ch chan scale.DataPoint) {
+	w.broker.SetDataChannel(ch)
+}
+
+// Tare tares the scale (not supported by the Weight Scale Service)
+func (w *WeightScale) Tare() error {
+	return scale.ErrNotSupported
+}
+
+// SetUnit sets the weight unit (not supported by the Weight Scale Service, the unit is
+// determined by the device itself)
+func (w *WeightScale) SetUnit(unit scale.Unit) error {
+
+	// Check if the unit is already set to the expected value
+	if current := w.Unit(); current != scale.UnitUnknown && current == unit {
+		return nil
+	}
+
+	return scale.ErrNotSupported
+}
+
+// Precision returns the current weight precision (the Weight Scale Service does not
+// provide this information, hence it is always unknown)
+func (w *WeightScale) Precision() scale.Precision {
+	return scale.PrecisionUnknown
+}
+
+// SetPrecision sets the weight precision (not supported by the Weight Scale Service)
+func (w *WeightScale) SetPrecision(p scale.Precision) error {
+	return scale.ErrNotSupported
+}
+
+// TogglePrecision toggles the weight precision (not supported by the Weight Scale Service)
+func (w *WeightScale) TogglePrecision() error {
+	return scale.ErrNotSupported
+}
+
+// Close terminates the connection to the device (subsequent calls are a no-op)
+func (w *WeightScale) Close() (err error) {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		w.ready.Close()
+		w.broker.Close()
+
+		_ = w.btDevice.StopScanning()
+		err = w.btDevice.RemoveAllServices()
+
+		// Close the device (releasing it if shared via an adapter)
+		if closeErr := w.btDevice.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (w *WeightScale) subscribe() error {
+
+	// Register handlers (routed to this instance if the device is shared via an adapter)
+	scale.HandlePeripherals(w.btDevice, w.genOnPeriphDiscovered(), w.onPeriphConnected, w.onPeriphDisconnected)
+
+	// Initialize the device
+	return w.btDevice.Init(w.onStateChanged)
+}
+
+func (w *WeightScale) setStatus(state scale.State, err error) {
+	status := w.reconnector.Status(state, err)
+
+	w.mu.Lock()
+	w.connectionStatus = status
+	w.mu.Unlock()
+
+	// Distribute state change to all consumers
+	w.broker.PublishState(status)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (w *WeightScale) onStateChanged(d gatt.Device, s gatt.State) {
+	switch s {
+	case gatt.StatePoweredOn:
+		w.setStatus(scale.StateScanning, nil)
+		if err := d.Scan([]gatt.UUID{}, false); err != nil {
+			w.logger.Warnf("failed to enable initial scanning: %s", err)
+		}
+		return
+	case gatt.StatePoweredOff:
+		w.setStatus(scale.StateDisconnected, nil)
+		return
+	default:
+		if err := d.StopScanning(); err != nil {
+			w.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+	}
+}
+
+func (w *WeightScale) genOnPeriphDiscovered() func(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+	return func(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+
+		w.logger.Debugf("discovered device `%s/%s`", p.Name(), p.ID())
+
+		if !w.matchesAdvertisement(p, a) {
+			return
+		}
+		w.mu.Lock()
+		w.discoveredID = p.ID()
+		w.mu.Unlock()
+
+		w.logger.Debugf("connecting device `%s/%s`", p.Name(), p.ID())
+
+		// Stop scanning once we've got the peripheral we're looking for.
+		if err := p.Device().StopScanning(); err != nil {
+			w.logger.Warnf("failed to stop initial scanning: %s", err)
+		}
+		if err := p.Device().Connect(p); err != nil {
+			w.logger.Errorf("Failed to connect device `%s/%s`: %s", p.Name(), p.ID(), err)
+		}
+
+		w.logger.Debugf("connected device `%s/%s`", p.Name(), p.ID())
+	}
+}
+
+func (w *WeightScale) onPeriphConnected(p gatt.Peripheral, connErr error) {
+
+	if !w.thisDevice(p) {
+		return
+	}
+
+	w.logger.Debugf("connected peripheral `%s/%s`", p.Name(), p.ID())
+
+	w.connWG.Add(1)
+	released := w.release.Released(p)
+	w.setStatus(scale.StateConnected, nil)
+	defer func() {
+		w.ready.Set(false)
+		_ = p.Device().CancelConnection(p)
+		w.setStatus(scale.StateDisconnected, connErr)
+		w.release.Done(p)
+		w.connWG.Done()
+	}()
+
+	// Discover services
+	ss, err := p.DiscoverServices(nil)
+	if err != nil {
+		connErr = fmt.Errorf("failed to discover services: %w", err)
+		return
+	}
+
+	var foundMeasurement bool
+	for _, s := range ss {
+		switch s.UUID().String() {
+		case weightScaleService:
+			if foundMeasurement, err = w.subscribeCharacteristic(p, s, weightMeasurementCharacteristic, w.receiveMeasurement); err != nil {
+				connErr = err
+				return
+			}
+		case batteryService:
+
+			// The Battery Service is optional, hence failures are only logged
+			if _, err := w.subscribeCharacteristic(p, s, batteryLevelCharacteristic, w.receiveBatteryLevel); err != nil {
+				w.logger.Warnf("failed to subscribe to battery level: %s", err)
+			}
+		case scale.DeviceInformationService:
+
+			// The Device Information service is optional as well
+			info, err := scale.ReadDeviceInfo(p, s)
+			if err != nil {
+				w.logger.Warnf("failed to read device information: %s", err)
+				continue
+			}
+			w.mu.Lock()
+			w.deviceInfo = info
+			w.mu.Unlock()
+		}
+	}
+	if !foundMeasurement {
+		connErr = fmt.Errorf("failed to find weight measurement characteristic")
+		return
+	}
+
+	w.ready.Set(true)
+	w.reconnector.Reset()
+
+	// Wait until the peripheral is released upon disconnect (which may have happened at any
+	// point during the connection setup) or once the scale is closed
+	w.logger.Debugf("waiting to release peripheral `%s/%s`", p.Name(), p.ID())
+	select {
+	case <-released:
+	case <-w.ctx.Done():
+	}
+	w.logger.Debugf("released peripheral `%s/%s`", p.Name(), p.ID())
+}
+
+func (w *WeightScale) onPeriphDisconnected(p gatt.Peripheral, err error) {
+
+	if !w.thisDevice(p) {
+		return
+	}
+
+	w.release.Release(p)
+	w.logger.Debugf("disconnected peripheral `%s/%s`", p.Name(), p.ID())
+
+	// Once the connection has been released, attempt to reconnect according to the
+	// reconnect policy (reporting the cause of the disconnect, if any)
+	go func() {
+		w.connWG.Wait()
+		if err == nil {
+			err = w.ConnectionStatus().Error
+		}
+		w.reconnector.Run(w.ctx, w.btDevice, &w.ready, err, w.setStatus)
+	}()
+}
+
+// subscribeCharacteristic discovers the requested characteristic of a service and
+// subscribes to its indications / notifications, reading its initial value (if
+// supported)
+func (w *WeightScale) subscribeCharacteristic(p gatt.Peripheral, s *gatt.Service, uuid string, fn func(*gatt.Characteristic, []byte, error)) (bool, error) {
+
+	// Discover characteristics
+	cs, err := p.DiscoverCharacteristics(nil, s)
+	if err != nil {
+		return false, fmt.Errorf("failed to discover characteristics: %w", err)
+	}
+	for _, c := range cs {
+		if c.UUID().String() != uuid {
+			continue
+		}
+
+		// Discover descriptors
+		if _, err := p.DiscoverDescriptors(nil, c); err != nil {
+			return false, fmt.Errorf("failed to discover descriptors: %w", err)
+		}
+
+		if c.Properties()&gatt.CharRead != 0 {
+			val, err := p.ReadCharacteristic(c)
+			fn(c, val, err)
+		}
+
+		switch {
+		case c.Properties()&gatt.CharIndicate != 0:
+			err = p.SetIndicateValue(c, fn)
+		case c.Properties()&gatt.CharNotify != 0:
+			err = p.SetNotifyValue(c, fn)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to subscribe characteristic: %w", err)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (w *WeightScale) matchesAdvertisement(p gatt.Peripheral, a *gatt.Advertisement) bool {
+
+	// Check if name and / or device ID have been overridden
+	if w.deviceID != "" || w.deviceName != "" {
+		return w.thisDevice(p)
+	}
+
+	// Otherwise, accept any device advertising the Weight Scale Service
+	return advertisesWeightScaleService(a)
+}
+
+func (w *WeightScale) thisDevice(p gatt.Peripheral) bool {
+
+	// If a device ID has been set, only the peripheral with this ID is accepted (several
+	// scales may share the same name), otherwise match by name
+	if w.deviceID != "" {
+		return strings.EqualFold(p.ID(), w.deviceID)
+	}
+	if w.deviceName != "" {
+		return strings.EqualFold(p.Name(), w.deviceName)
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.discoveredID != "" && strings.EqualFold(p.ID(), w.discoveredID)
+}
+
+func (w *WeightScale) receiveBatteryLevel(_ *gatt.Characteristic, req []byte, err error) {
+	if err != nil || len(req) != 1 {
+		return
+	}
+
+	w.mu.Lock()
+	w.batteryLevel = req[0]
+	w.mu.Unlock()
+}
+
+func (w *WeightScale) receiveMeasurement(_ *gatt.Characteristic, req []byte, err error) {
+
+	if err != nil {
+		return
+	}
+
+	measurement, err := parseMeasurement(req)
+	if err != nil {
+		w.logger.Debugf("failed to parse weight measurement: %s", err)
+		return
+	}
+	w.mu.Lock()
+	w.lastMeasurement = measurement
+	w.unit = measurement.Unit
+	w.mu.Unlock()
+
+	dataPoint := measurement.DataPoint()
+
+	// Convert data point to the canonical unit (if requested), dropping it if its unit
+	// is unknown
+	if w.canonicalUnit != "" {
+		converted, err := dataPoint.In(w.canonicalUnit)
+		if err != nil {
+			w.logger.Debugf("dropping data point: %s", err)
+			return
+		}
+		dataPoint = converted
+	}
+
+	// Filter data point (if filters are installed), dropping it if discarded by any filter
+	filtered, ok := w.filter.Filter(dataPoint)
+	if !ok {
+		return
+	}
+	dataPoint = filtered
+
+	// Annotate data point with the stability of the weight
+	dataPoint = w.stability.Process(dataPoint)
+
+	// Distribute data point to all consumers
+	w.broker.PublishData(dataPoint)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func advertisesWeightScaleService(a *gatt.Advertisement) bool {
+	if a == nil {
+		return false
+	}
+	for _, s := range a.Services {
+		if s.String() == weightScaleService {
+			return true
+		}
+	}
+
+	return false
+}